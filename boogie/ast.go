@@ -3,37 +3,214 @@
 
 package boogie
 
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
 // ========================
 // Program Structure
 // ========================
 
 type Program struct {
-	Procs []*Procedure
+	Types  []*TypeDecl
+	Fields []*FieldDecl
+	Funcs  []*Function
+	Procs  []*Procedure
+}
+
+// TypeDecl declares an uninterpreted type or type constructor:
+// "type Obj;" or "type Field _;", which takes Arity type arguments.
+type TypeDecl struct {
+	Name  string
+	Arity int
+	Pos   Pos
+}
+
+// FieldDecl is a heap field, declared as a constant of the heap's
+// field type: const f: Field int declares f, holding ints. Fields the
+// program does not declare get the type of the values written to them
+// (see ebs.Check).
+type FieldDecl struct {
+	Name string
+	Ty   Type // the type of the values the field holds
+	Pos  Pos
 }
 
+// Function is a Boogie function declaration. EBS v1 executes functions
+// with a body, an expression over the parameters, and bodiless ones
+// that map onto a built-in operation, such as {:bvbuiltin "bvadd"}.
+// Parameter names of bodiless functions are optional and may be "".
+type Function struct {
+	Name       string
+	TypeParams []string
+	Params     []Var
+	Ret        Type
+	Body       Expr // nil for a bodiless function
+	Attrs      []Attr
+	Pos        Pos
+}
+
+// Procedure is a Boogie procedure; TypeParams are its type parameters,
+// procedure p<a>(x: a), instantiated at each call. A Bodiless one is
+// only declared, and runs as the Go function it is bound to with
+// {:extern}.
 type Procedure struct {
-	Name   string
-	Params []Var
-	Rets   []Var
-	Locals []Var
-	Body   []Stmt
+	Name       string
+	TypeParams []string
+	Params     []Var
+	Rets       []Var
+	Locals     []Var
+	Body       []Stmt
+	Bodiless   bool
+	Attrs      []Attr
+}
+
+// ========================
+// Source Positions
+// ========================
+
+// Pos is a source position. Line and Col are 1-based; the zero Pos
+// means the position is unknown (e.g. for synthesized nodes).
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Pos) String() string {
+	if !p.IsValid() {
+		if p.File != "" {
+			return p.File
+		}
+		return "-"
+	}
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// ========================
+// Attributes
+// ========================
+
+// Attr is a Boogie attribute such as {:boogo "log"}.
+// Arguments are kept as raw strings; their meaning depends on the attribute.
+type Attr struct {
+	Name string
+	Args []string
+}
+
+// FindAttr returns the last attribute named name, mirroring Boogie's
+// "last one wins" rule for repeated attributes.
+func FindAttr(attrs []Attr, name string) (Attr, bool) {
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if attrs[i].Name == name {
+			return attrs[i], true
+		}
+	}
+	return Attr{}, false
 }
 
 // ========================
 // Types (EBS v1)
 // ========================
 
+// Type is a Boogie type. String returns its Boogie spelling, which
+// also serves as its identity: two types are the same if they print
+// the same.
 type Type interface {
 	isType()
+	String() string
 }
 
 type IntType struct{}
 type BoolType struct{}
 type RefType struct{} // abstract heap reference
 
+// RealType is Boogie's real: exact rationals, not floating point.
+type RealType struct{}
+
+// BvType is the bitvector type bvN, N = Width bits.
+type BvType struct {
+	Width int
+}
+
+// MapType is the map type [Args]Result, a total function from the
+// argument types to the result type: [int]bool, [int, int]int.
+// A polymorphic map binds TypeParams, which every select instantiates
+// from its arguments: <a>[Field a]a.
+type MapType struct {
+	TypeParams []string
+	Args       []Type
+	Result     Type
+}
+
+// TypeVar is a type parameter of a procedure, function or map type.
+type TypeVar struct {
+	Name string
+}
+
+// CtorType is a declared type applied to its arguments: Obj, Field int.
+type CtorType struct {
+	Name string
+	Args []Type
+}
+
 func (IntType) isType()  {}
 func (BoolType) isType() {}
 func (RefType) isType()  {}
+func (RealType) isType() {}
+func (BvType) isType()   {}
+func (MapType) isType()  {}
+func (TypeVar) isType()  {}
+func (CtorType) isType() {}
+
+func (IntType) String() string  { return "int" }
+func (BoolType) String() string { return "bool" }
+func (RefType) String() string  { return "ref" }
+func (RealType) String() string { return "real" }
+func (t BvType) String() string { return fmt.Sprintf("bv%d", t.Width) }
+
+func (t MapType) String() string {
+	args := make([]string, len(t.Args))
+	for i, a := range t.Args {
+		args[i] = a.String()
+	}
+	s := "[" + strings.Join(args, ",") + "]" + t.Result.String()
+	if len(t.TypeParams) > 0 {
+		s = "<" + strings.Join(t.TypeParams, ",") + ">" + s
+	}
+	return s
+}
+
+func (t TypeVar) String() string { return t.Name }
+
+// String parenthesizes arguments that are applications or maps.
+func (t CtorType) String() string {
+	s := t.Name
+	for _, a := range t.Args {
+		switch a := a.(type) {
+		case MapType:
+			s += " (" + a.String() + ")"
+		case CtorType:
+			if len(a.Args) > 0 {
+				s += " (" + a.String() + ")"
+				continue
+			}
+			s += " " + a.String()
+		default:
+			s += " " + a.String()
+		}
+	}
+	return s
+}
 
 // ========================
 // Variables
@@ -57,7 +234,9 @@ type LocalDecl struct {
 }
 
 type Assume struct {
-	Cond Expr
+	Cond  Expr
+	Attrs []Attr
+	Pos   Pos
 }
 
 func (*Assume) isStmt() {}
@@ -67,6 +246,7 @@ func (*LocalDecl) isStmt() {}
 type Assign struct {
 	Lhs Expr
 	Rhs Expr
+	Pos Pos
 }
 
 func (*Assign) isStmt() {}
@@ -79,6 +259,20 @@ type If struct {
 
 func (*If) isStmt() {}
 
+// Choice executes one of Branches, picked at runtime by the
+// nondeterminism oracle among those whose guard holds (a nil guard
+// always holds). It is the structured form of a nondeterministic goto.
+// If no branch is enabled the path is infeasible, and Blocked says how
+// that is reported (CheckAssume or CheckFilter, set by erasure).
+type Choice struct {
+	Guards   []Expr
+	Branches [][]Stmt
+	Blocked  CheckAction
+	Pos      Pos
+}
+
+func (*Choice) isStmt() {}
+
 type While struct {
 	Cond Expr
 	Body []Stmt
@@ -90,6 +284,11 @@ type Call struct {
 	Name string
 	Args []Expr
 	Rets []Var // explicit return assignment
+	Pos  Pos
+
+	// TypeArgs instantiate the callee's type parameters, in order.
+	// The checker infers them from Args and Rets.
+	TypeArgs []Type
 }
 
 func (*Call) isStmt() {}
@@ -100,13 +299,46 @@ type Return struct {
 
 func (*Return) isStmt() {}
 
+// Havoc assigns arbitrary values to Vars. At runtime the values come
+// from the nondeterminism oracle.
+type Havoc struct {
+	Vars []Var
+	Pos  Pos
+}
+
+func (*Havoc) isStmt() {}
+
 // Verification-only (erasable)
 type Assert struct {
-	Cond Expr
+	Cond  Expr
+	Attrs []Attr
+	Pos   Pos
 }
 
 func (*Assert) isStmt() {}
 
+// CheckAction selects what a failed runtime Check does.
+type CheckAction int
+
+const (
+	CheckPanic  CheckAction = iota // abort execution
+	CheckLog                       // report on stderr and continue
+	CheckCount                     // count silently; reported at exit
+	CheckAssume                    // abort the path as "assumption violated"
+	CheckFilter                    // silently discard the path
+)
+
+// Check is an executable assertion or assumption. Erasure produces it
+// from an Assert the assertion policy keeps, or from an Assume; it
+// never comes out of the parser.
+type Check struct {
+	Cond   Expr
+	Action CheckAction
+	Pos    Pos
+}
+
+func (*Check) isStmt() {}
+
 // ==================================
 // Expressions (Pure & Deterministic)
 // ==================================
@@ -129,8 +361,29 @@ func (e *VarExpr) Type() Type {
 
 // ---------- Literals ----------
 
+// IntLit is an integer literal. Boogie integers are unbounded: a
+// literal that does not fit in a Go int is held in Big instead. A
+// negated literal, -5, is a literal too.
 type IntLit struct {
 	Value int
+	Big   *big.Int
+	Pos   Pos
+}
+
+// NewIntLit returns the literal of value v at pos.
+func NewIntLit(v *big.Int, pos Pos) *IntLit {
+	if v.IsInt64() && int64(int(v.Int64())) == v.Int64() {
+		return &IntLit{Value: int(v.Int64()), Pos: pos}
+	}
+	return &IntLit{Big: v, Pos: pos}
+}
+
+// BigValue returns the literal's value as a *big.Int.
+func (l *IntLit) BigValue() *big.Int {
+	if l.Big != nil {
+		return l.Big
+	}
+	return big.NewInt(int64(l.Value))
 }
 
 func (*IntLit) isExpr() {}
@@ -147,6 +400,88 @@ func (*BoolLit) Type() Type {
 	return BoolType{}
 }
 
+// RealLit is a decimal literal such as 1.5.
+type RealLit struct {
+	Value *big.Rat
+}
+
+func (*RealLit) isExpr() {}
+func (*RealLit) Type() Type {
+	return RealType{}
+}
+
+// ---------- Conversions ----------
+
+// Convert is real(x), from int to real, or int(x), from real to int
+// rounding down.
+type Convert struct {
+	X   Expr
+	To  Type // RealType or IntType
+	Pos Pos
+}
+
+func (*Convert) isExpr() {}
+func (c *Convert) Type() Type {
+	return c.To
+}
+
+// ---------- Bitvectors ----------
+
+// BvLit is a bitvector literal such as 5bv32.
+type BvLit struct {
+	Value *big.Int
+	Width int
+}
+
+func (*BvLit) isExpr() {}
+func (l *BvLit) Type() Type {
+	return BvType{Width: l.Width}
+}
+
+// ---------- Bitvector Extraction ----------
+
+// BvExtract is x[Hi:Lo]: bits Lo (inclusive) to Hi (exclusive) of X.
+type BvExtract struct {
+	X      Expr
+	Hi, Lo int
+}
+
+func (*BvExtract) isExpr() {}
+func (e *BvExtract) Type() Type {
+	return BvType{Width: e.Hi - e.Lo}
+}
+
+// ---------- Function Application ----------
+
+// FuncApp applies a declared function: f(args).
+type FuncApp struct {
+	Func *Function
+	Args []Expr
+	Pos  Pos
+}
+
+func (*FuncApp) isExpr() {}
+func (f *FuncApp) Type() Type {
+	if subst, ok := f.Func.Instantiate(exprTypes(f.Args)); ok {
+		return Subst(f.Func.Ret, subst)
+	}
+	return f.Func.Ret // rejected by the checker
+}
+
+// TypeArgs returns how f instantiates the type parameters of the
+// function it applies, in order, as its arguments bind them.
+func (f *FuncApp) TypeArgs() []Type {
+	if len(f.Func.TypeParams) == 0 {
+		return nil
+	}
+	subst, _ := f.Func.Instantiate(exprTypes(f.Args))
+	ts := make([]Type, len(f.Func.TypeParams))
+	for i, tp := range f.Func.TypeParams {
+		ts[i] = subst[tp]
+	}
+	return ts
+}
+
 // ---------- Binary Operations ----------
 
 type BinOpKind int
@@ -156,12 +491,21 @@ const (
 	Sub
 	Mul
 	Eq
+	Neq
 	Lt
 	Lte
 	Gt
 	Gte
 	And
 	Or
+	Concat // bitvector concatenation, ++
+	Div    // real division, /
+	IntDiv // Euclidean integer division, div
+	Mod    // Euclidean remainder, mod: never negative
+
+	Implies // ==>, right-associative
+	Explies // <==, the converse: a <== b is b ==> a
+	Iff     // <==>
 )
 
 type BinOp struct {
@@ -169,6 +513,7 @@ type BinOp struct {
 	Left  Expr
 	Right Expr
 	Ty    Type
+	Pos   Pos // start of the expression
 }
 
 func (*BinOp) isExpr() {}
@@ -186,9 +531,10 @@ const (
 )
 
 type UnOp struct {
-	Op UnOpKind
-	X  Expr
-	Ty Type
+	Op  UnOpKind
+	X   Expr
+	Ty  Type
+	Pos Pos
 }
 
 func (*UnOp) isExpr() {}
@@ -196,15 +542,67 @@ func (u *UnOp) Type() Type {
 	return u.Ty
 }
 
+// ---------- Conditional Expressions ----------
+
+// IfThenElse is the expression if Cond then Then else Else. Only the
+// chosen branch is evaluated.
+type IfThenElse struct {
+	Cond Expr
+	Then Expr
+	Else Expr
+	Pos  Pos
+}
+
+func (*IfThenElse) isExpr() {}
+func (e *IfThenElse) Type() Type {
+	return e.Then.Type()
+}
+
+// ---------- Maps ----------
+
+// MapSelect is the map read m[i] or, for several arguments, m[i, j].
+type MapSelect struct {
+	Map  Expr
+	Args []Expr
+	Pos  Pos
+}
+
+func (*MapSelect) isExpr() {}
+func (s *MapSelect) Type() Type {
+	if t, ok := s.Map.Type().(MapType); ok {
+		if res, ok := t.Select(exprTypes(s.Args)); ok {
+			return res
+		}
+		return t.Result
+	}
+	return s.Map.Type() // rejected by the checker
+}
+
+// MapStore is the map update m[i := v]: the map equal to m except at i,
+// where it yields v. m itself is unchanged.
+type MapStore struct {
+	Map   Expr
+	Args  []Expr
+	Value Expr
+	Pos   Pos
+}
+
+func (*MapStore) isExpr() {}
+func (s *MapStore) Type() Type {
+	return s.Map.Type()
+}
+
 // ===========================
 // Heap Operations (Restricted)
 // ===========================
 
-// HeapRead represents: Heap[o, f]
+// HeapRead represents: Heap[o, f]. Ty is the type of field f; the
+// checker fills it in if it is nil.
 type HeapRead struct {
 	Obj   Expr // must be RefType
 	Field string
 	Ty    Type
+	Pos   Pos
 }
 
 func (*HeapRead) isExpr() {}
@@ -218,6 +616,23 @@ type HeapWrite struct {
 	Obj   Expr // RefType
 	Field string
 	Value Expr
+	Pos   Pos
 }
 
 func (*HeapWrite) isStmt() {}
+
+// Alloc represents the built-in call o := New(): V becomes a fresh
+// reference, one no earlier New returned, and never null.
+type Alloc struct {
+	V   Var // RefType
+	Pos Pos
+}
+
+func (*Alloc) isStmt() {}
+
+// NullLit is null, the reference to no object. Reading or writing its
+// fields is a fault.
+type NullLit struct{}
+
+func (*NullLit) isExpr()    {}
+func (*NullLit) Type() Type { return RefType{} }
@@ -0,0 +1,87 @@
+package boogie
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BvBuiltin is a bitvector operation that a function is bound to with
+// {:bvbuiltin "name"}. Names are the SMT-LIB ones Boogie uses: "bvadd",
+// "bvule", "zero_extend 16", ...
+type BvBuiltin struct {
+	Op string // "bvadd", "zero_extend", ...
+	N  int    // bits added by zero_extend and sign_extend
+}
+
+// bvBuiltinArity lists the supported operations by arity. Comparisons
+// yield bool; everything else yields a bitvector.
+var bvBuiltinArity = map[string]int{
+	"bvneg": 1, "bvnot": 1,
+	"bvadd": 2, "bvsub": 2, "bvmul": 2,
+	"bvudiv": 2, "bvurem": 2, "bvsdiv": 2, "bvsrem": 2, "bvsmod": 2,
+	"bvand": 2, "bvor": 2, "bvxor": 2,
+	"bvshl": 2, "bvlshr": 2, "bvashr": 2,
+	"bvult": 2, "bvule": 2, "bvugt": 2, "bvuge": 2,
+	"bvslt": 2, "bvsle": 2, "bvsgt": 2, "bvsge": 2,
+	"zero_extend": 1, "sign_extend": 1,
+}
+
+// ParseBvBuiltin parses the argument of a {:bvbuiltin} attribute.
+func ParseBvBuiltin(name string) (BvBuiltin, error) {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return BvBuiltin{}, fmt.Errorf("empty bitvector builtin")
+	}
+
+	b := BvBuiltin{Op: fields[0]}
+	if _, ok := bvBuiltinArity[b.Op]; !ok {
+		return BvBuiltin{}, fmt.Errorf("unsupported bitvector builtin %q", name)
+	}
+
+	if b.IsExtend() {
+		if len(fields) != 2 {
+			return BvBuiltin{}, fmt.Errorf("%s needs a bit count, as in %q", b.Op, b.Op+" 16")
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 0 {
+			return BvBuiltin{}, fmt.Errorf("invalid bit count in %q", name)
+		}
+		b.N = n
+	} else if len(fields) != 1 {
+		return BvBuiltin{}, fmt.Errorf("unsupported bitvector builtin %q", name)
+	}
+
+	return b, nil
+}
+
+// Arity is the number of arguments the operation takes.
+func (b BvBuiltin) Arity() int {
+	return bvBuiltinArity[b.Op]
+}
+
+// IsExtend reports whether b is zero_extend or sign_extend.
+func (b BvBuiltin) IsExtend() bool {
+	return b.Op == "zero_extend" || b.Op == "sign_extend"
+}
+
+// IsCompare reports whether b is a comparison, yielding bool.
+func (b BvBuiltin) IsCompare() bool {
+	switch b.Op {
+	case "bvult", "bvule", "bvugt", "bvuge", "bvslt", "bvsle", "bvsgt", "bvsge":
+		return true
+	}
+	return false
+}
+
+// ResultType is the type b yields on width-bit operands.
+func (b BvBuiltin) ResultType(width int) Type {
+	switch {
+	case b.IsCompare():
+		return BoolType{}
+	case b.IsExtend():
+		return BvType{Width: width + b.N}
+	default:
+		return BvType{Width: width}
+	}
+}
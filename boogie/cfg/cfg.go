@@ -53,6 +53,12 @@ func Structure(cfg *CFG) ([]boogie.Stmt, error) {
 }
 
 func structBlock(cfg *CFG, id BlockID, seen map[BlockID]bool) ([]boogie.Stmt, error) {
+	return structBlockFrom(cfg, id, 0, seen)
+}
+
+// structBlockFrom structures block id, dropping its first skip
+// statements (guards already turned into a condition by the caller).
+func structBlockFrom(cfg *CFG, id BlockID, skip int, seen map[BlockID]bool) ([]boogie.Stmt, error) {
 	if seen[id] {
 		return nil, fmt.Errorf("cycle not structured")
 	}
@@ -60,7 +66,7 @@ func structBlock(cfg *CFG, id BlockID, seen map[BlockID]bool) ([]boogie.Stmt, er
 	seen[id] = true
 
 	b := cfg.Blocks[id]
-	stmts := append([]boogie.Stmt{}, b.Stmts...)
+	stmts := append([]boogie.Stmt{}, b.Stmts[skip:]...)
 
 	switch t := b.Term.(type) {
 
@@ -85,10 +91,18 @@ func structBlock(cfg *CFG, id BlockID, seen map[BlockID]bool) ([]boogie.Stmt, er
 		}), nil
 
 	case *Goto:
-		if len(t.Targets) == 1 {
+		switch len(t.Targets) {
+		case 0:
+			return nil, fmt.Errorf("goto without targets")
+		case 1:
 			return structBlock(cfg, t.Targets[0], seen)
 		}
-		return nil, fmt.Errorf("unsupported goto")
+
+		s, err := structGoto(cfg, t, seen)
+		if err != nil {
+			return nil, err
+		}
+		return append(stmts, s), nil
 	}
 
 	return nil, fmt.Errorf("unsupported terminator: %T", b.Term)
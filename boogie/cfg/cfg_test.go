@@ -131,3 +131,99 @@ func TestStructureRejectsCycle(t *testing.T) {
 		t.Fatalf("expected cycle to be rejected")
 	}
 }
+
+func TestStructureGotoAssumePatternIsIf(t *testing.T) {
+	x := &boogie.VarExpr{V: boogie.Var{Name: "x", Ty: boogie.IntType{}}}
+	cond := &boogie.BinOp{Op: boogie.Lt, Left: x, Right: &boogie.IntLit{Value: 0}, Ty: boogie.BoolType{}}
+
+	b0 := &Block{
+		ID:   id(0),
+		Term: &Goto{Targets: []BlockID{id(1), id(2)}},
+	}
+
+	b1 := &Block{
+		ID:    id(1),
+		Stmts: []boogie.Stmt{&boogie.Assume{Cond: cond}},
+		Term:  &Return{},
+	}
+
+	b2 := &Block{
+		ID:    id(2),
+		Stmts: []boogie.Stmt{&boogie.Assume{Cond: &boogie.UnOp{Op: boogie.Not, X: cond, Ty: boogie.BoolType{}}}},
+		Term:  &Return{},
+	}
+
+	stmts, err := Structure(BuildCFG([]*Block{b0, b1, b2}, id(0)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ifs, ok := stmts[0].(*boogie.If)
+	if !ok {
+		t.Fatalf("expected If, got %T", stmts[0])
+	}
+
+	if ifs.Cond != cond {
+		t.Fatalf("guard of first target is not the condition")
+	}
+
+	// The guarding assumes are consumed by the condition.
+	if len(ifs.Then) != 1 || len(ifs.Else) != 1 {
+		t.Fatalf("unexpected branch sizes: %d, %d", len(ifs.Then), len(ifs.Else))
+	}
+}
+
+func TestStructureGotoComplementaryComparison(t *testing.T) {
+	x := &boogie.VarExpr{V: boogie.Var{Name: "x", Ty: boogie.IntType{}}}
+	zero := &boogie.IntLit{Value: 0}
+
+	b0 := &Block{ID: id(0), Term: &Goto{Targets: []BlockID{id(1), id(2)}}}
+	b1 := &Block{
+		ID:    id(1),
+		Stmts: []boogie.Stmt{&boogie.Assume{Cond: &boogie.BinOp{Op: boogie.Gte, Left: x, Right: zero}}},
+		Term:  &Return{},
+	}
+	b2 := &Block{
+		ID:    id(2),
+		Stmts: []boogie.Stmt{&boogie.Assume{Cond: &boogie.BinOp{Op: boogie.Lt, Left: x, Right: zero}}},
+		Term:  &Return{},
+	}
+
+	stmts, err := Structure(BuildCFG([]*Block{b0, b1, b2}, id(0)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := stmts[0].(*boogie.If); !ok {
+		t.Fatalf("expected If, got %T", stmts[0])
+	}
+}
+
+func TestStructureNondeterministicGoto(t *testing.T) {
+	b0 := &Block{ID: id(0), Term: &Goto{Targets: []BlockID{id(1), id(2), id(3)}}}
+	b1 := &Block{ID: id(1), Term: &Return{}}
+	b2 := &Block{
+		ID:    id(2),
+		Stmts: []boogie.Stmt{&boogie.Assume{Cond: &boogie.BoolLit{Value: false}}},
+		Term:  &Return{},
+	}
+	b3 := &Block{ID: id(3), Term: &Return{}}
+
+	stmts, err := Structure(BuildCFG([]*Block{b0, b1, b2, b3}, id(0)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ch, ok := stmts[0].(*boogie.Choice)
+	if !ok {
+		t.Fatalf("expected Choice, got %T", stmts[0])
+	}
+
+	if len(ch.Branches) != 3 {
+		t.Fatalf("expected 3 branches, got %d", len(ch.Branches))
+	}
+
+	if ch.Guards[0] != nil || ch.Guards[1] == nil || ch.Guards[2] != nil {
+		t.Fatalf("unexpected guards: %v", ch.Guards)
+	}
+}
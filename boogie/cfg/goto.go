@@ -0,0 +1,84 @@
+package cfg
+
+import "github.com/ezrantn/boogo/boogie"
+
+// structGoto structures a multi-target goto. Each target's leading
+// assume, if any, is its guard. Two targets with complementary guards
+// (goto A, B; A: assume c; ... B: assume !c;) are how Boogie encodes
+// an if, and become one. Anything else becomes a Choice, resolved at
+// runtime by the oracle among the targets whose guard holds.
+func structGoto(cfg *CFG, g *Goto, seen map[BlockID]bool) (boogie.Stmt, error) {
+	guards := make([]boogie.Expr, len(g.Targets))
+	branches := make([][]boogie.Stmt, len(g.Targets))
+
+	for i, tgt := range g.Targets {
+		skip := 0
+		if guard := leadingAssume(cfg.Blocks[tgt]); guard != nil {
+			guards[i] = guard
+			skip = 1
+		}
+
+		body, err := structBlockFrom(cfg, tgt, skip, copySeen(seen))
+		if err != nil {
+			return nil, err
+		}
+		branches[i] = body
+	}
+
+	if len(g.Targets) == 2 && guards[0] != nil && guards[1] != nil &&
+		complementary(guards[0], guards[1]) {
+		return &boogie.If{
+			Cond: guards[0],
+			Then: branches[0],
+			Else: branches[1],
+		}, nil
+	}
+
+	return &boogie.Choice{
+		Guards:   guards,
+		Branches: branches,
+		Pos:      g.Pos,
+	}, nil
+}
+
+// leadingAssume returns the condition of b's first statement if it is
+// an assume, and nil otherwise.
+func leadingAssume(b *Block) boogie.Expr {
+	if len(b.Stmts) == 0 {
+		return nil
+	}
+	if a, ok := b.Stmts[0].(*boogie.Assume); ok {
+		return a.Cond
+	}
+	return nil
+}
+
+// negatedRel pairs each relational operator with its negation.
+var negatedRel = map[boogie.BinOpKind]boogie.BinOpKind{
+	boogie.Lt:  boogie.Gte,
+	boogie.Gte: boogie.Lt,
+	boogie.Gt:  boogie.Lte,
+	boogie.Lte: boogie.Gt,
+	boogie.Eq:  boogie.Neq,
+	boogie.Neq: boogie.Eq,
+}
+
+// complementary reports whether exactly one of a and b holds in every
+// state: one is the negation of the other, either as !c or as the
+// opposite comparison.
+func complementary(a, b boogie.Expr) bool {
+	if u, ok := a.(*boogie.UnOp); ok && u.Op == boogie.Not && boogie.EqualExpr(u.X, b) {
+		return true
+	}
+	if u, ok := b.(*boogie.UnOp); ok && u.Op == boogie.Not && boogie.EqualExpr(u.X, a) {
+		return true
+	}
+
+	x, ok1 := a.(*boogie.BinOp)
+	y, ok2 := b.(*boogie.BinOp)
+	if !ok1 || !ok2 {
+		return false
+	}
+	neg, ok := negatedRel[x.Op]
+	return ok && neg == y.Op && boogie.EqualExpr(x.Left, y.Left) && boogie.EqualExpr(x.Right, y.Right)
+}
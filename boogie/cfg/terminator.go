@@ -4,8 +4,12 @@ import "github.com/ezrantn/boogo/boogie"
 
 type Terminator interface{ isTerm() }
 
+// Goto jumps to one of Targets. With several targets the choice is
+// nondeterministic; Boogie encodes branching this way, guarding each
+// target with a leading assume.
 type Goto struct {
 	Targets []BlockID
+	Pos     boogie.Pos
 }
 
 func (*Goto) isTerm() {}
@@ -0,0 +1,83 @@
+package boogie
+
+// EqualExpr reports whether a and b are structurally identical
+// expressions. Variables are compared by name; types are ignored.
+func EqualExpr(a, b Expr) bool {
+	switch x := a.(type) {
+
+	case *VarExpr:
+		y, ok := b.(*VarExpr)
+		return ok && x.V.Name == y.V.Name
+
+	case *IntLit:
+		y, ok := b.(*IntLit)
+		return ok && x.BigValue().Cmp(y.BigValue()) == 0
+
+	case *BoolLit:
+		y, ok := b.(*BoolLit)
+		return ok && x.Value == y.Value
+
+	case *RealLit:
+		y, ok := b.(*RealLit)
+		return ok && x.Value.Cmp(y.Value) == 0
+
+	case *Convert:
+		y, ok := b.(*Convert)
+		return ok && x.To.String() == y.To.String() && EqualExpr(x.X, y.X)
+
+	case *BvLit:
+		y, ok := b.(*BvLit)
+		return ok && x.Width == y.Width && x.Value.Cmp(y.Value) == 0
+
+	case *BvExtract:
+		y, ok := b.(*BvExtract)
+		return ok && x.Hi == y.Hi && x.Lo == y.Lo && EqualExpr(x.X, y.X)
+
+	case *FuncApp:
+		y, ok := b.(*FuncApp)
+		return ok && x.Func.Name == y.Func.Name && equalExprs(x.Args, y.Args)
+
+	case *MapSelect:
+		y, ok := b.(*MapSelect)
+		return ok && EqualExpr(x.Map, y.Map) && equalExprs(x.Args, y.Args)
+
+	case *MapStore:
+		y, ok := b.(*MapStore)
+		return ok && EqualExpr(x.Map, y.Map) && equalExprs(x.Args, y.Args) && EqualExpr(x.Value, y.Value)
+
+	case *BinOp:
+		y, ok := b.(*BinOp)
+		return ok && x.Op == y.Op && EqualExpr(x.Left, y.Left) && EqualExpr(x.Right, y.Right)
+
+	case *UnOp:
+		y, ok := b.(*UnOp)
+		return ok && x.Op == y.Op && EqualExpr(x.X, y.X)
+
+	case *IfThenElse:
+		y, ok := b.(*IfThenElse)
+		return ok && EqualExpr(x.Cond, y.Cond) && EqualExpr(x.Then, y.Then) && EqualExpr(x.Else, y.Else)
+
+	case *NullLit:
+		_, ok := b.(*NullLit)
+		return ok
+
+	case *HeapRead:
+		y, ok := b.(*HeapRead)
+		return ok && x.Field == y.Field && EqualExpr(x.Obj, y.Obj)
+
+	default:
+		return false
+	}
+}
+
+func equalExprs(a, b []Expr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !EqualExpr(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
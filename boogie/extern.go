@@ -0,0 +1,53 @@
+package boogie
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// Extern is the Go function a bodiless function or procedure is bound
+// to with {:extern "import/path.Func"}.
+type Extern struct {
+	Path string // the import path of its package, such as "math/bits"
+	Name string // its name in the package, such as "OnesCount"
+}
+
+func (e Extern) String() string {
+	return e.Path + "." + e.Name
+}
+
+// ParseExtern parses the argument of an {:extern} attribute. The name
+// follows the last dot, so that import paths may contain dots:
+// "example.com/m/v2.Func".
+func ParseExtern(s string) (Extern, error) {
+	i := strings.LastIndex(s, ".")
+	if i < 0 || strings.LastIndex(s, "/") > i {
+		return Extern{}, fmt.Errorf("extern %q is not of the form \"import/path.Func\"", s)
+	}
+	e := Extern{Path: s[:i], Name: s[i+1:]}
+	if e.Path == "" || !token.IsIdentifier(e.Name) || !token.IsExported(e.Name) {
+		return Extern{}, fmt.Errorf("extern %q does not name an exported function of a package", s)
+	}
+	return e, nil
+}
+
+// FindExtern returns the Go function f is bound to, if any. Check has
+// made sure the binding is well-formed.
+func FindExtern(f *Function) (Extern, bool) {
+	return findExtern(f.Attrs)
+}
+
+// FindProcExtern is FindExtern for a bodiless procedure.
+func FindProcExtern(p *Procedure) (Extern, bool) {
+	return findExtern(p.Attrs)
+}
+
+func findExtern(attrs []Attr) (Extern, bool) {
+	attr, ok := FindAttr(attrs, "extern")
+	if !ok || len(attr.Args) != 1 {
+		return Extern{}, false
+	}
+	e, err := ParseExtern(attr.Args[0])
+	return e, err == nil
+}
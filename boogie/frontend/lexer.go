@@ -1,16 +1,30 @@
 package frontend
 
-import "unicode"
+import (
+	"unicode"
+
+	"github.com/ezrantn/boogo/boogie"
+)
 
 type TokenKind int
 
 type Lexer struct {
-	src []rune
-	pos int
+	src  []rune
+	pos  int
+	file string
+	line int
+	col  int
 }
 
 func NewLexer(input string) *Lexer {
-	return &Lexer{src: []rune(input), pos: 0}
+	return &Lexer{src: []rune(input), pos: 0, line: 1, col: 1}
+}
+
+// NewFileLexer is like NewLexer but records file in token positions.
+func NewFileLexer(file, input string) *Lexer {
+	l := NewLexer(input)
+	l.file = file
+	return l
 }
 
 // peek returns the current character without advancing
@@ -21,13 +35,32 @@ func (l *Lexer) peek() rune {
 	return l.src[l.pos]
 }
 
+// peekNext returns the character after the current one without advancing
+func (l *Lexer) peekNext() rune {
+	if l.pos+1 >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos+1]
+}
+
 // advance consumes the current character and returns it
 func (l *Lexer) advance() rune {
 	ch := l.peek()
 	l.pos++
+	if ch == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
 	return ch
 }
 
+// position returns the source position of the current character
+func (l *Lexer) position() boogie.Pos {
+	return boogie.Pos{File: l.file, Line: l.line, Col: l.col}
+}
+
 // skipWhitespace ignores spaces, tabs, and newlines
 func (l *Lexer) skipWhitespace() {
 	for unicode.IsSpace(l.peek()) {
@@ -37,26 +70,40 @@ func (l *Lexer) skipWhitespace() {
 
 const (
 	EOF TokenKind = iota
+	ILLEGAL
 
 	// identifiers + literals
 	IDENT
 	INT_LIT
 	BOOL_LIT
+	BV_LIT
+	DEC_LIT
 
 	// keywords
 	PROCEDURE
 	RETURNS
 	VAR
 	IF
+	THEN
 	ELSE
 	WHILE
 	RETURN
+	HAVOC
+	FUNCTION
+	TYPE
+	CALL
+	CONST
+	MODIFIES
+	DIV
+	MOD
 
 	// symbols
 	LPAREN
 	RPAREN
 	LBRACE
 	RBRACE
+	LBRACKET
+	RBRACKET
 	COLON
 	COMMA
 	SEMI
@@ -64,7 +111,10 @@ const (
 	PLUS
 	MINUS
 	MUL
+	SLASH
+	CONCAT // ++
 	EQ
+	NEQ
 	LT
 	GT
 	GTE
@@ -72,30 +122,103 @@ const (
 	AND
 	OR
 	NOT
+	IMPLIES // ==>
+	EXPLIES // <==
+	IFF     // <==>
 	ASSERT
 	ASSUME
+	STRING_LIT
 )
 
+var tokenNames = map[TokenKind]string{
+	EOF:        "end of file",
+	ILLEGAL:    "illegal character",
+	IDENT:      "identifier",
+	INT_LIT:    "integer literal",
+	BOOL_LIT:   "boolean literal",
+	BV_LIT:     "bitvector literal",
+	DEC_LIT:    "decimal literal",
+	STRING_LIT: "string literal",
+	PROCEDURE:  "procedure",
+	RETURNS:    "returns",
+	VAR:        "var",
+	IF:         "if",
+	THEN:       "then",
+	ELSE:       "else",
+	WHILE:      "while",
+	RETURN:     "return",
+	HAVOC:      "havoc",
+	FUNCTION:   "function",
+	TYPE:       "type",
+	CALL:       "call",
+	CONST:      "const",
+	MODIFIES:   "modifies",
+	DIV:        "div",
+	MOD:        "mod",
+	LPAREN:     "(",
+	RPAREN:     ")",
+	LBRACE:     "{",
+	RBRACE:     "}",
+	LBRACKET:   "[",
+	RBRACKET:   "]",
+	COLON:      ":",
+	COMMA:      ",",
+	SEMI:       ";",
+	ASSIGN:     ":=",
+	PLUS:       "+",
+	MINUS:      "-",
+	MUL:        "*",
+	SLASH:      "/",
+	CONCAT:     "++",
+	EQ:         "==",
+	NEQ:        "!=",
+	LT:         "<",
+	GT:         ">",
+	GTE:        ">=",
+	LTE:        "<=",
+	AND:        "&&",
+	OR:         "||",
+	NOT:        "!",
+	IMPLIES:    "==>",
+	EXPLIES:    "<==",
+	IFF:        "<==>",
+	ASSERT:     "assert",
+	ASSUME:     "assume",
+}
+
+func (k TokenKind) String() string {
+	if name, ok := tokenNames[k]; ok {
+		return name
+	}
+	return "unknown token"
+}
+
 type Token struct {
 	Kind  TokenKind
 	Value string
+	Pos   boogie.Pos
 }
 
+// NextToken returns the next token, stamped with its source position.
 func (l *Lexer) NextToken() Token {
 	l.skipWhitespace()
+	for l.peek() == '/' && l.peekNext() == '/' {
+		l.skipLineComment()
+		l.skipWhitespace()
+	}
 
+	pos := l.position()
+	tok := l.scan()
+	tok.Pos = pos
+	return tok
+}
+
+func (l *Lexer) scan() Token {
 	ch := l.peek()
 	if ch == 0 {
 		return Token{Kind: EOF, Value: ""}
 	}
 
-	if ch == '/' {
-		if l.peek() == '/' { // You'd need a peekNext helper
-			l.skipLineComment()
-			return l.NextToken()
-		}
-	}
-
 	// Handle Identifiers and Keywords
 	if unicode.IsLetter(ch) || ch == '_' || ch == '$' || ch == '\'' {
 		return l.lexIdentifier()
@@ -106,62 +229,97 @@ func (l *Lexer) NextToken() Token {
 		return l.lexNumber()
 	}
 
+	// Handle String Literals (attribute arguments)
+	if ch == '"' {
+		return l.lexString()
+	}
+
 	// Handle Multi-character and Single-character Symbols
 	l.advance()
 	switch ch {
 	case '(':
-		return Token{LPAREN, "("}
+		return Token{Kind: LPAREN, Value: "("}
 	case ')':
-		return Token{RPAREN, ")"}
+		return Token{Kind: RPAREN, Value: ")"}
 	case '{':
-		return Token{LBRACE, "{"}
+		return Token{Kind: LBRACE, Value: "{"}
 	case '}':
-		return Token{RBRACE, "}"}
+		return Token{Kind: RBRACE, Value: "}"}
 	case ',':
-		return Token{COMMA, ","}
+		return Token{Kind: COMMA, Value: ","}
 	case ';':
-		return Token{SEMI, ";"}
+		return Token{Kind: SEMI, Value: ";"}
+	case '[':
+		return Token{Kind: LBRACKET, Value: "["}
+	case ']':
+		return Token{Kind: RBRACKET, Value: "]"}
 	case '+':
-		return Token{PLUS, "+"}
+		if l.peek() == '+' {
+			l.advance()
+			return Token{Kind: CONCAT, Value: "++"}
+		}
+		return Token{Kind: PLUS, Value: "+"}
 	case '-':
-		return Token{MINUS, "-"}
+		return Token{Kind: MINUS, Value: "-"}
 	case '*':
-		return Token{MUL, "*"}
+		return Token{Kind: MUL, Value: "*"}
+	case '/':
+		return Token{Kind: SLASH, Value: "/"}
 	case ':':
 		if l.peek() == '=' {
 			l.advance()
-			return Token{ASSIGN, ":="}
+			return Token{Kind: ASSIGN, Value: ":="}
 		}
-		return Token{COLON, ":"}
+		return Token{Kind: COLON, Value: ":"}
 	case '<':
+		if l.peek() == '=' && l.peekNext() == '=' {
+			l.advance()
+			l.advance()
+			if l.peek() == '>' {
+				l.advance()
+				return Token{Kind: IFF, Value: "<==>"}
+			}
+			return Token{Kind: EXPLIES, Value: "<=="}
+		}
 		if l.peek() == '=' {
 			l.advance()
-			return Token{LTE, "<="}
+			return Token{Kind: LTE, Value: "<="}
 		}
-		return Token{LT, "<"}
+		return Token{Kind: LT, Value: "<"}
 	case '>':
 		if l.peek() == '=' {
 			l.advance()
-			return Token{GTE, ">="}
+			return Token{Kind: GTE, Value: ">="}
 		}
-		return Token{GT, ">"}
+		return Token{Kind: GT, Value: ">"}
 	case '=':
-		return Token{EQ, "="}
+		if l.peek() == '=' {
+			l.advance()
+			if l.peek() == '>' {
+				l.advance()
+				return Token{Kind: IMPLIES, Value: "==>"}
+			}
+			return Token{Kind: EQ, Value: "=="}
+		}
 	case '&':
 		if l.peek() == '&' {
 			l.advance()
-			return Token{AND, "&&"}
+			return Token{Kind: AND, Value: "&&"}
 		}
 	case '|':
 		if l.peek() == '|' {
 			l.advance()
-			return Token{OR, "||"}
+			return Token{Kind: OR, Value: "||"}
 		}
 	case '!':
-		return Token{NOT, "!"}
+		if l.peek() == '=' {
+			l.advance()
+			return Token{Kind: NEQ, Value: "!="}
+		}
+		return Token{Kind: NOT, Value: "!"}
 	}
 
-	return Token{EOF, ""} // Or handle as ILLEGAL token
+	return Token{Kind: ILLEGAL, Value: string(ch)}
 }
 
 func (l *Lexer) skipLineComment() {
@@ -175,9 +333,20 @@ var keywords = map[string]TokenKind{
 	"returns":   RETURNS,
 	"var":       VAR,
 	"if":        IF,
+	"then":      THEN,
 	"else":      ELSE,
 	"while":     WHILE,
 	"return":    RETURN,
+	"havoc":     HAVOC,
+	"function":  FUNCTION,
+	"type":      TYPE,
+	"call":      CALL,
+	"const":     CONST,
+	"modifies":  MODIFIES,
+	"div":       DIV,
+	"mod":       MOD,
+	"assert":    ASSERT,
+	"assume":    ASSUME,
 	"true":      BOOL_LIT,
 	"false":     BOOL_LIT,
 }
@@ -189,19 +358,56 @@ func (l *Lexer) lexIdentifier() Token {
 	}
 	val := string(l.src[start:l.pos])
 	if kind, ok := keywords[val]; ok {
-		return Token{kind, val}
+		return Token{Kind: kind, Value: val}
 	}
-	return Token{IDENT, val}
+	return Token{Kind: IDENT, Value: val}
 }
 
 func isIdentChar(ch rune) bool {
 	return unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_' || ch == '$' || ch == '\'' || ch == '.'
 }
 
+// lexNumber reads an integer literal, a bitvector literal such as
+// 5bv32 (value 5, width 32) or a decimal literal such as 1.5 or 2.5e-3.
 func (l *Lexer) lexNumber() Token {
 	start := l.pos
 	for unicode.IsDigit(l.peek()) {
 		l.advance()
 	}
-	return Token{INT_LIT, string(l.src[start:l.pos])}
+	if l.peek() == '.' && unicode.IsDigit(l.peekNext()) {
+		l.advance()
+		for unicode.IsDigit(l.peek()) {
+			l.advance()
+		}
+		if l.peek() == 'e' && (unicode.IsDigit(l.peekNext()) || l.peekNext() == '-') {
+			l.advance()
+			l.advance()
+			for unicode.IsDigit(l.peek()) {
+				l.advance()
+			}
+		}
+		return Token{Kind: DEC_LIT, Value: string(l.src[start:l.pos])}
+	}
+	if l.peek() == 'b' && l.peekNext() == 'v' {
+		l.advance()
+		l.advance()
+		for unicode.IsDigit(l.peek()) {
+			l.advance()
+		}
+		return Token{Kind: BV_LIT, Value: string(l.src[start:l.pos])}
+	}
+	return Token{Kind: INT_LIT, Value: string(l.src[start:l.pos])}
+}
+
+// lexString reads a double-quoted string. Boogie strings have no escapes;
+// the token value excludes the quotes.
+func (l *Lexer) lexString() Token {
+	l.advance() // opening quote
+	start := l.pos
+	for l.peek() != '"' && l.peek() != 0 {
+		l.advance()
+	}
+	val := string(l.src[start:l.pos])
+	l.advance() // closing quote
+	return Token{Kind: STRING_LIT, Value: val}
 }
@@ -65,3 +65,21 @@ func TestLexer(t *testing.T) {
 		}
 	}
 }
+
+func TestLexerOperators(t *testing.T) {
+	input := `a == b != c div d mod e / f ! g = h`
+
+	expected := []TokenKind{
+		IDENT, EQ, IDENT, NEQ, IDENT, DIV, IDENT, MOD, IDENT, SLASH, IDENT,
+		NOT, IDENT, ILLEGAL, IDENT, EOF,
+	}
+
+	lexer := NewLexer(input)
+
+	for i, want := range expected {
+		if tok := lexer.NextToken(); tok.Kind != want {
+			t.Fatalf("tests[%d] - tokenkind wrong. expected=%v, got=%v (%q)",
+				i, want, tok.Kind, tok.Value)
+		}
+	}
+}
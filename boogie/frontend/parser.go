@@ -2,7 +2,10 @@ package frontend
 
 import (
 	"fmt"
+	"math/big"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/ezrantn/boogo/boogie"
 )
@@ -29,19 +32,67 @@ import (
 //
 // - axiom, invariant, requires, ensures
 // - forall, exists
-// - havoc
 // - goto (frontend only; allowed internally via CFG)
-// - call with multiple returns (for now)
-// - maps other than heap encoding
 
 type Parser struct {
 	lexer *Lexer
 	curr  Token
 	peek  Token
+
+	// scope maps the variables visible in the current procedure
+	// (parameters, returns and locals) to their declared types.
+	scope  map[string]boogie.Type
+	locals []boogie.Var
+
+	// funcs holds the functions declared so far; a function must be
+	// declared before it is applied.
+	funcs map[string]*boogie.Function
+
+	// types holds the type declarations seen so far, and typeVars the
+	// type parameters in scope: those of the enclosing procedure or
+	// function, then those of enclosing map types.
+	types    map[string]*boogie.TypeDecl
+	typeVars []string
+
+	// heap is the heap variable, if declared, and fields maps each
+	// heap field declared so far to its type, such as Field int.
+	heap   heapDecl
+	fields map[string]boogie.CtorType
+}
+
+// heapDecl describes the heap variable var H: <a>[ref, Field a]a: its
+// name, and the type constructor of its fields.
+type heapDecl struct {
+	name, ctor string
+}
+
+// Error is a syntax or name-resolution error at a source position.
+type Error struct {
+	Pos boogie.Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
 }
 
 func Parse(src []byte) (*boogie.Program, error) {
-	l := NewLexer(string(src))
+	return ParseFile("", src)
+}
+
+// ParseFile parses src, recording file in every source position.
+func ParseFile(file string, src []byte) (prog *boogie.Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			prog, err = nil, perr
+		}
+	}()
+
+	l := NewFileLexer(file, string(src))
 	p := NewParser(l)
 
 	return p.ParseProgram(), nil
@@ -55,23 +106,49 @@ func NewParser(l *Lexer) *Parser {
 	return p
 }
 
+// errorf aborts parsing; Parse turns the panic back into an *Error.
+func (p *Parser) errorf(format string, args ...any) {
+	p.errorAt(p.curr.Pos, format, args...)
+}
+
+// errorAt is errorf reporting pos rather than the current token.
+func (p *Parser) errorAt(pos boogie.Pos, format string, args ...any) {
+	panic(&Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Binary operator precedence, loosest first, following Boogie's
+// grammar (EquivExpr, ImpliesExpr, LogicalExpr, RelationalExpr, BvTerm,
+// Term, Factor). Unary - and ! bind tighter than all of them.
 const (
-	PREC_LOWEST      = iota
-	PREC_EQUALS      // ==
-	PREC_LESSGREATER // > or <
-	PREC_SUM         // + or -
-	PREC_PRODUCT     // *
+	PREC_LOWEST     = iota
+	PREC_IFF        // <==>
+	PREC_IMPLIES    // ==> or <==
+	PREC_LOGICAL    // && or ||
+	PREC_RELATIONAL // == != < <= > >=
+	PREC_CONCAT     // ++
+	PREC_SUM        // + or -
+	PREC_PRODUCT    // * / div mod
 )
 
 var precedences = map[TokenKind]int{
-	EQ:    PREC_EQUALS,
-	LT:    PREC_LESSGREATER,
-	LTE:   PREC_LESSGREATER,
-	GT:    PREC_LESSGREATER,
-	GTE:   PREC_LESSGREATER,
-	PLUS:  PREC_SUM,
-	MINUS: PREC_SUM,
-	MUL:   PREC_PRODUCT,
+	IFF:     PREC_IFF,
+	IMPLIES: PREC_IMPLIES,
+	EXPLIES: PREC_IMPLIES,
+	AND:     PREC_LOGICAL,
+	OR:      PREC_LOGICAL,
+	EQ:      PREC_RELATIONAL,
+	NEQ:     PREC_RELATIONAL,
+	LT:      PREC_RELATIONAL,
+	LTE:     PREC_RELATIONAL,
+	GT:      PREC_RELATIONAL,
+	GTE:     PREC_RELATIONAL,
+	CONCAT:  PREC_CONCAT,
+	PLUS:    PREC_SUM,
+	MINUS:   PREC_SUM,
+	MUL:     PREC_PRODUCT,
+	SLASH:   PREC_PRODUCT,
+	DIV:     PREC_PRODUCT,
+	MOD:     PREC_PRODUCT,
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -97,27 +174,127 @@ func (p *Parser) expect(kind TokenKind) {
 	if p.curr.Kind == kind {
 		p.nextToken()
 	} else {
-		panic(fmt.Sprintf("expected %v, got %v", kind, p.curr.Kind))
+		p.errorf("expected %v, got %v", kind, p.curr.Kind)
 	}
 }
 
 func (p *Parser) ParseProgram() *boogie.Program {
 	prog := &boogie.Program{}
+	p.funcs = make(map[string]*boogie.Function)
+	p.types = make(map[string]*boogie.TypeDecl)
+	p.fields = make(map[string]boogie.CtorType)
 	for p.curr.Kind != EOF {
-		if p.curr.Kind == PROCEDURE {
+		switch p.curr.Kind {
+		case TYPE:
+			prog.Types = append(prog.Types, p.parseTypeDecl())
+		case CONST:
+			prog.Fields = append(prog.Fields, p.parseConst()...)
+		case VAR:
+			p.parseGlobal()
+		case PROCEDURE:
 			prog.Procs = append(prog.Procs, p.parseProcedure())
-		} else {
+		case FUNCTION:
+			prog.Funcs = append(prog.Funcs, p.parseFunction())
+		default:
 			p.nextToken() // Skip unknown top-level decls for now
 		}
 	}
 	return prog
 }
 
+// parseFunction parses a function declaration, bodiless or with an
+// expression body:
+//
+//	function {:bvbuiltin "bvadd"} add32(x: bv32, y: bv32) returns (bv32);
+//	function {:memoize} inc(x: int): int { x + 1 }
+//
+// Parameter and result names are optional, unless there is a body, and
+// the result may also be given as ": T".
+func (p *Parser) parseFunction() *boogie.Function {
+	pos := p.curr.Pos
+	p.expect(FUNCTION)
+	attrs := p.parseAttributes()
+
+	f := &boogie.Function{Name: p.curr.Value, Attrs: attrs, Pos: pos}
+	p.expect(IDENT)
+	if _, ok := p.funcs[f.Name]; ok {
+		p.errorf("function %s redeclared", f.Name)
+	}
+	f.TypeParams = p.parseTypeParams()
+	p.typeVars = f.TypeParams
+	defer func() { p.typeVars = nil }()
+
+	p.expect(LPAREN)
+	for p.curr.Kind != RPAREN && p.curr.Kind != EOF {
+		f.Params = append(f.Params, p.parseFormal())
+		if p.curr.Kind != COMMA {
+			break
+		}
+		p.nextToken()
+	}
+	p.expect(RPAREN)
+
+	if p.curr.Kind == COLON {
+		p.nextToken()
+		f.Ret = p.parseType()
+	} else {
+		p.expect(RETURNS)
+		p.expect(LPAREN)
+		f.Ret = p.parseFormal().Ty
+		p.expect(RPAREN)
+	}
+
+	// Declared before its body, so that a recursive application
+	// reaches the checker, which rejects it.
+	p.funcs[f.Name] = f
+
+	if p.curr.Kind != LBRACE {
+		p.expect(SEMI)
+		return f
+	}
+
+	p.scope = make(map[string]boogie.Type)
+	defer func() { p.scope = nil }()
+	for _, v := range f.Params {
+		if v.Name == "" {
+			p.errorAt(pos, "function %s has a body, so its parameters need names", f.Name)
+		}
+		p.declare(v)
+	}
+	p.nextToken()
+	f.Body = p.parseExpression(PREC_LOWEST)
+	p.expect(RBRACE)
+	return f
+}
+
+// parseFormal parses a function parameter or result, "x: T" or "T".
+func (p *Parser) parseFormal() boogie.Var {
+	if p.curr.Kind == IDENT && p.peek.Kind == COLON {
+		name := p.curr.Value
+		p.nextToken()
+		p.nextToken()
+		return boogie.Var{Name: name, Ty: p.parseType()}
+	}
+	return boogie.Var{Ty: p.parseType()}
+}
+
+// parseProcedure parses a procedure, or a procedure declaration
+// without a body, such as
+//
+//	procedure {:extern "example.com/io.Read"} Read() returns (x: int);
 func (p *Parser) parseProcedure() *boogie.Procedure {
 	p.expect(PROCEDURE)
+	attrs := p.parseAttributes()
 	name := p.curr.Value
 	p.expect(IDENT)
 
+	typeParams := p.parseTypeParams()
+	p.typeVars = typeParams
+	defer func() { p.typeVars = nil }()
+
+	p.scope = make(map[string]boogie.Type)
+	p.locals = nil
+
 	params := p.parseVarList()
 
 	var rets []boogie.Var
@@ -126,16 +303,290 @@ func (p *Parser) parseProcedure() *boogie.Procedure {
 		rets = p.parseVarList()
 	}
 
+	// Running code needs no frame conditions: modifies clauses only
+	// have to name the heap, the one global.
+	for p.curr.Kind == MODIFIES {
+		p.nextToken()
+		for {
+			if p.curr.Kind == IDENT && p.curr.Value != p.heap.name {
+				p.errorf("undeclared global %s", p.curr.Value)
+			}
+			p.expect(IDENT)
+			if p.curr.Kind != COMMA {
+				break
+			}
+			p.nextToken()
+		}
+		p.expect(SEMI)
+	}
+
+	proc := &boogie.Procedure{
+		Name:       name,
+		TypeParams: typeParams,
+		Params:     params,
+		Rets:       rets,
+		Attrs:      attrs,
+	}
+	if p.curr.Kind == SEMI {
+		p.nextToken()
+		proc.Bodiless = true
+		return proc
+	}
+
 	p.expect(LBRACE)
-	body := p.parseStatements()
+	proc.Body = p.parseStatements()
 	p.expect(RBRACE)
+	proc.Locals = p.locals
+	return proc
+}
+
+// parseTypeDecl parses an uninterpreted type, "type Obj;", or a type
+// constructor, "type Field _;", whose argument names are placeholders.
+func (p *Parser) parseTypeDecl() *boogie.TypeDecl {
+	pos := p.curr.Pos
+	p.expect(TYPE)
+	p.parseAttributes()
+
+	d := &boogie.TypeDecl{Name: p.curr.Value, Pos: pos}
+	p.expect(IDENT)
+	if _, ok := p.types[d.Name]; ok || builtinType(d.Name) {
+		p.errorf("type %s redeclared", d.Name)
+	}
+	for p.curr.Kind == IDENT {
+		d.Arity++
+		p.nextToken()
+	}
+	if p.curr.Kind == ILLEGAL && p.curr.Value == "=" {
+		p.errorf("type synonyms are not supported")
+	}
+	p.expect(SEMI)
+
+	p.types[d.Name] = d
+	return d
+}
+
+// parseConst parses heap field declarations, "const f, g: Field int;",
+// optionally unique; fields are always distinct. Field must be the
+// type constructor of the heap's fields. Other constants are not
+// supported.
+func (p *Parser) parseConst() []*boogie.FieldDecl {
+	pos := p.curr.Pos
+	p.expect(CONST)
+	if p.curr.Kind == IDENT && p.curr.Value == "unique" {
+		p.nextToken()
+	}
+
+	var names []string
+	for {
+		names = append(names, p.curr.Value)
+		p.expect(IDENT)
+		if p.curr.Kind != COMMA {
+			break
+		}
+		p.nextToken()
+	}
+	p.expect(COLON)
+	ty := p.parseType()
+	p.expect(SEMI)
+
+	// The common declaration of null is accepted; null is built in.
+	if _, ok := ty.(boogie.RefType); ok && len(names) == 1 && names[0] == "null" {
+		return nil
+	}
+
+	c, ok := ty.(boogie.CtorType)
+	if !ok || len(c.Args) != 1 {
+		p.errorAt(pos, "constant %s: only heap fields, such as const f: Field int, can be constants", names[0])
+	}
+
+	var decls []*boogie.FieldDecl
+	for _, name := range names {
+		if _, ok := p.fields[name]; ok {
+			p.errorAt(pos, "heap field %s redeclared", name)
+		}
+		p.fields[name] = c
+		decls = append(decls, &boogie.FieldDecl{Name: name, Ty: c.Args[0], Pos: pos})
+	}
+	return decls
+}
+
+// parseGlobal parses the one global variable EBS supports, the heap:
+// var H: <a>[ref, Field a]a, which maps an object and a field holding
+// values of type a to a value of type a.
+func (p *Parser) parseGlobal() {
+	pos := p.curr.Pos
+	p.expect(VAR)
+	name := p.curr.Value
+	p.expect(IDENT)
+	p.expect(COLON)
+	ty := p.parseType()
+	p.expect(SEMI)
+
+	ctor, ok := heapFieldCtor(ty)
+	if !ok {
+		p.errorAt(pos, "global %s: the only global variable supported is the heap, var %s: <a>[ref, Field a]a", name, name)
+	}
+	if p.heap.name != "" {
+		p.errorAt(pos, "global %s: the heap is already declared as %s", name, p.heap.name)
+	}
+	p.heap = heapDecl{name: name, ctor: ctor}
+}
+
+// heapFieldCtor reports whether t is a heap type <a>[ref, C a]a, and
+// returns the type constructor C of its fields.
+func heapFieldCtor(t boogie.Type) (string, bool) {
+	m, ok := t.(boogie.MapType)
+	if !ok || len(m.TypeParams) != 1 || len(m.Args) != 2 {
+		return "", false
+	}
+	isParam := func(t boogie.Type) bool {
+		v, ok := t.(boogie.TypeVar)
+		return ok && v.Name == m.TypeParams[0]
+	}
+	f, ok := m.Args[1].(boogie.CtorType)
+	if _, ref := m.Args[0].(boogie.RefType); !ref || !ok || len(f.Args) != 1 || !isParam(f.Args[0]) || !isParam(m.Result) {
+		return "", false
+	}
+	return f.Name, true
+}
+
+// isHeap reports whether name refers to the heap variable.
+func (p *Parser) isHeap(name string) bool {
+	_, local := p.scope[name]
+	return name == p.heap.name && !local
+}
+
+// parseHeapIndex parses the [o, f] of a heap access H[o, f], returning
+// the object, the field and the type of the field's values.
+func (p *Parser) parseHeapIndex() (boogie.Expr, string, boogie.Type) {
+	p.expect(LBRACKET)
+	obj := p.parseExpression(PREC_LOWEST)
+	p.expect(COMMA)
+
+	field := p.curr.Value
+	c, ok := p.fields[field]
+	if p.curr.Kind != IDENT || !ok {
+		p.errorf("heap field must be a declared constant, got %s", p.curr.Value)
+	}
+	if c.Name != p.heap.ctor {
+		p.errorf("%s has type %v, not a field of %s", field, c, p.heap.name)
+	}
+	p.nextToken()
+
+	if p.curr.Kind == ASSIGN {
+		p.errorf("heap update expressions are not supported; assign %s[o, f] := v instead", p.heap.name)
+	}
+	p.expect(RBRACKET)
+	return obj, field, c.Args[0]
+}
 
-	return &boogie.Procedure{
-		Name:   name,
-		Params: params,
-		Rets:   rets,
-		Body:   body,
+// parseHeapRead parses H[o, f] and any selects from the value read.
+func (p *Parser) parseHeapRead() boogie.Expr {
+	pos := p.curr.Pos
+	p.expect(IDENT)
+	if p.curr.Kind != LBRACKET {
+		p.errorf("the heap %s can only be used as %s[o, f]", p.heap.name, p.heap.name)
 	}
+	obj, field, ty := p.parseHeapIndex()
+	return p.parseSuffixes(&boogie.HeapRead{Obj: obj, Field: field, Ty: ty, Pos: pos})
+}
+
+// parseHeapWrite parses "H[o, f] := v;" and, for map-valued fields,
+// "H[o, f][i] := v;".
+func (p *Parser) parseHeapWrite() boogie.Stmt {
+	pos := p.curr.Pos
+	p.expect(IDENT)
+	obj, field, ty := p.parseHeapIndex()
+
+	var indexes [][]boogie.Expr
+	var indexPos []boogie.Pos
+	for p.curr.Kind == LBRACKET {
+		indexPos = append(indexPos, p.curr.Pos)
+		p.nextToken()
+		indexes = append(indexes, p.parseMapArgs())
+		p.expect(RBRACKET)
+	}
+
+	p.expect(ASSIGN)
+	rhs := p.parseExpression(PREC_LOWEST)
+	p.expect(SEMI)
+
+	if len(indexes) > 0 {
+		read := &boogie.HeapRead{Obj: obj, Field: field, Ty: ty, Pos: pos}
+		rhs = mapUpdate(read, indexes, indexPos, rhs)
+	}
+	return &boogie.HeapWrite{Obj: obj, Field: field, Value: rhs, Pos: pos}
+}
+
+// parseTypeParams parses optional type parameters, "<a, b>".
+func (p *Parser) parseTypeParams() []string {
+	if p.curr.Kind != LT {
+		return nil
+	}
+	p.nextToken()
+	var names []string
+	for {
+		if slices.Contains(names, p.curr.Value) {
+			p.errorf("type parameter %s redeclared", p.curr.Value)
+		}
+		names = append(names, p.curr.Value)
+		p.expect(IDENT)
+		if p.curr.Kind != COMMA {
+			break
+		}
+		p.nextToken()
+	}
+	p.expect(GT)
+	return names
+}
+
+// declare brings v into the current procedure scope.
+func (p *Parser) declare(v boogie.Var) {
+	if _, ok := p.scope[v.Name]; ok {
+		p.errorf("%s redeclared", v.Name)
+	}
+	p.scope[v.Name] = v.Ty
+}
+
+// lookup resolves a variable reference against the current scope.
+func (p *Parser) lookup(name string) boogie.Var {
+	ty, ok := p.scope[name]
+	if !ok {
+		p.errorf("undeclared identifier %s", name)
+	}
+	return boogie.Var{Name: name, Ty: ty}
+}
+
+// parseAttributes parses zero or more {:name arg, ...} attributes.
+// Arguments may be strings, identifiers or integer literals.
+func (p *Parser) parseAttributes() []boogie.Attr {
+	var attrs []boogie.Attr
+	for p.curr.Kind == LBRACE && p.peek.Kind == COLON {
+		p.nextToken() // {
+		p.nextToken() // :
+		attr := boogie.Attr{Name: p.curr.Value}
+		p.expect(IDENT)
+
+		for p.curr.Kind != RBRACE && p.curr.Kind != EOF {
+			switch p.curr.Kind {
+			case STRING_LIT, IDENT, INT_LIT, BOOL_LIT:
+				attr.Args = append(attr.Args, p.curr.Value)
+				p.nextToken()
+			default:
+				p.errorf("unexpected %v in attribute {:%s}", p.curr.Kind, attr.Name)
+			}
+
+			if p.curr.Kind == COMMA {
+				p.nextToken()
+			} else {
+				break
+			}
+		}
+
+		p.expect(RBRACE)
+		attrs = append(attrs, attr)
+	}
+	return attrs
 }
 
 func (p *Parser) parseVarList() []boogie.Var {
@@ -148,7 +599,9 @@ func (p *Parser) parseVarList() []boogie.Var {
 		p.expect(COLON)
 
 		ty := p.parseType()
-		vars = append(vars, boogie.Var{Name: name, Ty: ty})
+		v := boogie.Var{Name: name, Ty: ty}
+		p.declare(v)
+		vars = append(vars, v)
 
 		if p.curr.Kind == COMMA {
 			p.nextToken()
@@ -161,48 +614,191 @@ func (p *Parser) parseVarList() []boogie.Var {
 	return vars
 }
 
+// parseType parses one of the types EBS supports: int, real, bool, ref,
+// a bitvector type bvN, a map type, a type parameter in scope or a
+// declared type applied to its arguments, such as Field int.
 func (p *Parser) parseType() boogie.Type {
+	switch p.curr.Kind {
+	case LBRACKET, LT:
+		return p.parseMapType()
+	case LPAREN:
+		p.nextToken()
+		ty := p.parseType()
+		p.expect(RPAREN)
+		return ty
+	}
+
 	typeName := p.curr.Value
-	p.expect(IDENT)
+	if p.curr.Kind != IDENT {
+		p.errorf("expected type, got %v", p.curr.Kind)
+	}
+
+	// Type parameters shadow declared types, which shadow nothing:
+	// the built-in names cannot be redeclared.
+	if slices.Contains(p.typeVars, typeName) {
+		p.nextToken()
+		return boogie.TypeVar{Name: typeName}
+	}
+	if d, ok := p.types[typeName]; ok {
+		p.nextToken()
+		ty := boogie.CtorType{Name: typeName}
+		for range d.Arity {
+			ty.Args = append(ty.Args, p.parseType())
+		}
+		return ty
+	}
 
+	var ty boogie.Type
 	switch typeName {
 	case "int":
-		return boogie.IntType{}
+		ty = boogie.IntType{}
+	case "real":
+		ty = boogie.RealType{}
 	case "bool":
-		return boogie.BoolType{}
+		ty = boogie.BoolType{}
+	case "ref":
+		ty = boogie.RefType{}
 	default:
-		// You can expand this for bitvectors: case "bv32": ...
-		return boogie.IntType{}
+		w, ok := bvWidth(typeName)
+		if !ok {
+			p.errorf("unknown type %s (want int, real, bool, ref, bvN or a declared type)", typeName)
+		}
+		ty = boogie.BvType{Width: w}
+	}
+
+	p.nextToken()
+	return ty
+}
+
+// parseMapType parses a map type such as [int]bool or [int, ref]int,
+// or a polymorphic one such as <a>[Field a]a.
+func (p *Parser) parseMapType() boogie.Type {
+	typeParams := p.parseTypeParams()
+	outer := p.typeVars
+	p.typeVars = append(slices.Clip(outer), typeParams...)
+	defer func() { p.typeVars = outer }()
+
+	p.expect(LBRACKET)
+	var args []boogie.Type
+	for {
+		args = append(args, p.parseType())
+		if p.curr.Kind != COMMA {
+			break
+		}
+		p.nextToken()
+	}
+	p.expect(RBRACKET)
+	return boogie.MapType{TypeParams: typeParams, Args: args, Result: p.parseType()}
+}
+
+// builtinType reports whether name is a built-in type name.
+func builtinType(name string) bool {
+	switch name {
+	case "int", "real", "bool", "ref":
+		return true
+	}
+	_, ok := bvWidth(name)
+	return ok
+}
+
+// bvWidth parses the width of a bitvector type name such as "bv32".
+func bvWidth(name string) (int, bool) {
+	digits, ok := strings.CutPrefix(name, "bv")
+	if !ok || digits == "" || digits[0] == '+' || digits[0] == '-' {
+		return 0, false
+	}
+	w, err := strconv.Atoi(digits)
+	if err != nil || w < 1 {
+		return 0, false
+	}
+	return w, true
+}
+
+// chains reports whether the operator next may follow prev, at the
+// same precedence, without parentheses. Arithmetic operators mix
+// freely; &&, ||, ++, <==> and the implications only repeat themselves
+// (a && b || c is rejected, as is a ==> b <== c); comparisons do not
+// chain at all.
+func chains(prev, next TokenKind) bool {
+	switch prev {
+	case EQ, NEQ, LT, LTE, GT, GTE:
+		return false
+	case AND, OR, CONCAT, IFF, IMPLIES, EXPLIES:
+		return next == prev
+	default:
+		return true
 	}
 }
 
 func (p *Parser) parseExpression(precedence int) boogie.Expr {
-	// Parse the "Prefix" part (identifiers, numbers, or grouping)
-	left := p.parsePrimary()
+	return p.parseOperand(precedence, ILLEGAL)
+}
+
+// parseOperand parses an expression of operators binding tighter than
+// precedence. prev is the operator the expression is the right operand
+// of, or ILLEGAL; it matters for ==>, whose right operand is parsed at
+// the operator's own level to make it right-associative.
+func (p *Parser) parseOperand(precedence int, prev TokenKind) boogie.Expr {
+	start := p.curr.Pos
+
+	left := p.parseUnary()
 
 	// while the next token isn't a semicolon/brace
 	// and the next operator binds tighter than our current level
 	for p.curr.Kind != SEMI && p.curr.Kind != RPAREN && precedence < p.currPrecedence() {
-		left = p.parseInfix(left)
+		kind := p.curr.Kind
+		if prev != ILLEGAL && precedences[prev] == precedences[kind] && !chains(prev, kind) {
+			p.errorf("%v after %v needs parentheses", kind, prev)
+		}
+		left = p.parseInfix(left, start)
+		prev = kind
 	}
 
 	return left
 }
 
-func (p *Parser) parseInfix(left boogie.Expr) boogie.Expr {
+// parseInfix parses the operator and right operand of a binary
+// expression whose left operand, starting at start, is already parsed.
+func (p *Parser) parseInfix(left boogie.Expr, start boogie.Pos) boogie.Expr {
 	kind := p.curr.Kind
 	prec := p.currPrecedence()
+	op := p.tokenToOp(kind)
 	p.nextToken() // consume operator
 
+	// ==> is right-associative: a ==> b ==> c is a ==> (b ==> c).
+	if kind == IMPLIES {
+		prec--
+	}
+	right := p.parseOperand(prec, kind)
 	return &boogie.BinOp{
-		Op:    tokenToOp(kind),
+		Op:    op,
 		Left:  left,
-		Right: p.parseExpression(prec),
+		Right: right,
+		Ty:    binOpType(op, left, right),
+		Pos:   start,
+	}
+}
+
+// binOpType is the result type of op: arithmetic keeps the operand
+// type, concatenation adds up widths, everything else yields bool. The checker validates operands.
+func binOpType(op boogie.BinOpKind, left, right boogie.Expr) boogie.Type {
+	switch op {
+	case boogie.Add, boogie.Sub, boogie.Mul, boogie.Div, boogie.IntDiv, boogie.Mod:
+		return left.Type()
+	case boogie.Concat:
+		l, lok := left.Type().(boogie.BvType)
+		r, rok := right.Type().(boogie.BvType)
+		if !lok || !rok {
+			return left.Type() // rejected by the checker
+		}
+		return boogie.BvType{Width: l.Width + r.Width}
+	default:
+		return boogie.BoolType{}
 	}
 }
 
 // Helper to map TokenKind to boogie.BinOpKind
-func tokenToOp(kind TokenKind) boogie.BinOpKind {
+func (p *Parser) tokenToOp(kind TokenKind) boogie.BinOpKind {
 	switch kind {
 	case PLUS:
 		return boogie.Add
@@ -210,43 +806,239 @@ func tokenToOp(kind TokenKind) boogie.BinOpKind {
 		return boogie.Sub
 	case MUL:
 		return boogie.Mul
+	case SLASH:
+		return boogie.Div
+	case DIV:
+		return boogie.IntDiv
+	case MOD:
+		return boogie.Mod
 	case LT:
 		return boogie.Lt
 	case LTE:
 		return boogie.Lte
 	case EQ:
 		return boogie.Eq
+	case NEQ:
+		return boogie.Neq
 	case GT:
 		return boogie.Gt
 	case GTE:
 		return boogie.Gte
+	case AND:
+		return boogie.And
+	case OR:
+		return boogie.Or
+	case CONCAT:
+		return boogie.Concat
+	case IMPLIES:
+		return boogie.Implies
+	case EXPLIES:
+		return boogie.Explies
+	case IFF:
+		return boogie.Iff
 	default:
-		panic("unsupported operator")
+		p.errorf("unsupported operator %v", kind)
+		return 0
+	}
+}
+
+// parseUnary parses a primary expression under any number of prefix
+// operators, - and !, which bind tighter than every binary operator:
+// -x * y is (-x) * y and !a == b is (!a) == b.
+func (p *Parser) parseUnary() boogie.Expr {
+	pos := p.curr.Pos
+	switch p.curr.Kind {
+	case MINUS:
+		p.nextToken()
+		x := p.parseUnary()
+		if lit, ok := x.(*boogie.IntLit); ok {
+			// Folded, so that -9223372036854775808 is an int64 literal
+			// rather than the negation of one out of range.
+			return boogie.NewIntLit(new(big.Int).Neg(lit.BigValue()), pos)
+		}
+		return &boogie.UnOp{Op: boogie.Neg, X: x, Ty: x.Type(), Pos: pos}
+	case NOT:
+		p.nextToken()
+		x := p.parseUnary()
+		return &boogie.UnOp{Op: boogie.Not, X: x, Ty: boogie.BoolType{}, Pos: pos}
+	default:
+		return p.parsePrimary()
 	}
 }
 
 func (p *Parser) parsePrimary() boogie.Expr {
 	switch p.curr.Kind {
 	case IDENT:
-		name := p.curr.Value
+		if p.peek.Kind == LPAREN && (p.curr.Value == "real" || p.curr.Value == "int") {
+			return p.parseConvert()
+		}
+		if p.peek.Kind == LPAREN {
+			return p.parseSuffixes(p.parseFuncApp())
+		}
+		if p.isHeap(p.curr.Value) {
+			return p.parseHeapRead()
+		}
+		if _, ok := p.scope[p.curr.Value]; !ok && p.curr.Value == "null" {
+			p.nextToken()
+			return &boogie.NullLit{}
+		}
+		v := p.lookup(p.curr.Value)
 		p.nextToken()
-		return &boogie.VarExpr{V: boogie.Var{Name: name}}
+		return p.parseSuffixes(&boogie.VarExpr{V: v})
+	case BV_LIT:
+		return p.parseBvLit()
+	case DEC_LIT:
+		val, ok := new(big.Rat).SetString(p.curr.Value)
+		if !ok {
+			p.errorf("invalid decimal literal %s", p.curr.Value)
+		}
+		p.nextToken()
+		return &boogie.RealLit{Value: val}
 	case INT_LIT:
-		val, _ := strconv.Atoi(p.curr.Value)
+		// Boogie ints are unbounded
+		val, _ := new(big.Int).SetString(p.curr.Value, 10)
+		lit := boogie.NewIntLit(val, p.curr.Pos)
 		p.nextToken()
-		return &boogie.IntLit{Value: val}
+		return lit
+	case IF:
+		return p.parseIfThenElse()
 	case LPAREN:
 		p.nextToken() // consume (
 		expr := p.parseExpression(PREC_LOWEST)
 		p.expect(RPAREN) // consume )
-		return expr
+		return p.parseSuffixes(expr)
 	case BOOL_LIT:
 		val, _ := strconv.ParseBool(p.curr.Value)
 		p.nextToken()
 		return &boogie.BoolLit{Value: val}
 	default:
-		panic(fmt.Sprintf("unexpected token: %v", p.curr.Kind))
+		p.errorf("unexpected %v in expression", p.curr.Kind)
+		return nil
+	}
+}
+
+// parseFuncApp parses "f(args)".
+func (p *Parser) parseFuncApp() boogie.Expr {
+	pos := p.curr.Pos
+	f, ok := p.funcs[p.curr.Value]
+	if !ok {
+		p.errorf("undeclared function %s", p.curr.Value)
+	}
+	p.expect(IDENT)
+	p.expect(LPAREN)
+
+	var args []boogie.Expr
+	for p.curr.Kind != RPAREN && p.curr.Kind != EOF {
+		args = append(args, p.parseExpression(PREC_LOWEST))
+		if p.curr.Kind != COMMA {
+			break
+		}
+		p.nextToken()
+	}
+	p.expect(RPAREN)
+
+	return &boogie.FuncApp{Func: f, Args: args, Pos: pos}
+}
+
+// parseIfThenElse parses the expression if c then a else b. The else
+// branch extends as far to the right as possible.
+func (p *Parser) parseIfThenElse() boogie.Expr {
+	pos := p.curr.Pos
+	p.nextToken() // consume if
+	cond := p.parseExpression(PREC_LOWEST)
+	p.expect(THEN)
+	then := p.parseExpression(PREC_LOWEST)
+	p.expect(ELSE)
+	els := p.parseExpression(PREC_LOWEST)
+	return &boogie.IfThenElse{Cond: cond, Then: then, Else: els, Pos: pos}
+}
+
+// parseConvert parses the conversions real(x) and int(x).
+func (p *Parser) parseConvert() boogie.Expr {
+	pos := p.curr.Pos
+	var to boogie.Type = boogie.IntType{}
+	if p.curr.Value == "real" {
+		to = boogie.RealType{}
+	}
+	p.nextToken()
+	p.expect(LPAREN)
+	x := p.parseExpression(PREC_LOWEST)
+	p.expect(RPAREN)
+	return &boogie.Convert{X: x, To: to, Pos: pos}
+}
+
+// parseBvLit parses a bitvector literal such as 5bv32.
+func (p *Parser) parseBvLit() boogie.Expr {
+	digits, width, _ := strings.Cut(p.curr.Value, "bv")
+	w, ok := bvWidth("bv" + width)
+	if !ok {
+		p.errorf("invalid bitvector literal %s", p.curr.Value)
+	}
+	val, _ := new(big.Int).SetString(digits, 10)
+	if val.BitLen() > w {
+		p.errorf("literal %s does not fit in bv%d", digits, w)
 	}
+	p.nextToken()
+	return &boogie.BvLit{Value: val, Width: w}
+}
+
+// mapUpdate builds the map equal to m except at m[indexes[0]][indexes[1]]...,
+// which holds v.
+func mapUpdate(m boogie.Expr, indexes [][]boogie.Expr, pos []boogie.Pos, v boogie.Expr) boogie.Expr {
+	if len(indexes) > 1 {
+		inner := &boogie.MapSelect{Map: m, Args: indexes[0], Pos: pos[0]}
+		v = mapUpdate(inner, indexes[1:], pos[1:], v)
+	}
+	return &boogie.MapStore{Map: m, Args: indexes[0], Value: v, Pos: pos[0]}
+}
+
+// parseSuffixes parses the bracketed suffixes applied to x: map
+// selects m[i, j] and updates m[i := v] if x is a map, bitvector
+// extractions x[hi:lo] otherwise.
+func (p *Parser) parseSuffixes(x boogie.Expr) boogie.Expr {
+	for p.curr.Kind == LBRACKET {
+		pos := p.curr.Pos
+		p.nextToken()
+		if _, ok := x.Type().(boogie.MapType); ok {
+			args := p.parseMapArgs()
+			if p.curr.Kind == ASSIGN {
+				p.nextToken()
+				v := p.parseExpression(PREC_LOWEST)
+				p.expect(RBRACKET)
+				x = &boogie.MapStore{Map: x, Args: args, Value: v, Pos: pos}
+				continue
+			}
+			p.expect(RBRACKET)
+			x = &boogie.MapSelect{Map: x, Args: args, Pos: pos}
+			continue
+		}
+		hi := p.parseBitIndex()
+		p.expect(COLON)
+		lo := p.parseBitIndex()
+		p.expect(RBRACKET)
+		x = &boogie.BvExtract{X: x, Hi: hi, Lo: lo}
+	}
+	return x
+}
+
+// parseMapArgs parses the comma-separated arguments of a map select or
+// update, up to the closing bracket or the := of an update.
+func (p *Parser) parseMapArgs() []boogie.Expr {
+	args := []boogie.Expr{p.parseExpression(PREC_LOWEST)}
+	for p.curr.Kind == COMMA {
+		p.nextToken()
+		args = append(args, p.parseExpression(PREC_LOWEST))
+	}
+	return args
+}
+
+func (p *Parser) parseBitIndex() int {
+	n, err := strconv.Atoi(p.curr.Value)
+	if p.curr.Kind != INT_LIT || err != nil {
+		p.errorf("expected bit index, got %v", p.curr.Kind)
+	}
+	p.nextToken()
+	return n
 }
 
 func (p *Parser) parseStatements() []boogie.Stmt {
@@ -256,16 +1048,17 @@ func (p *Parser) parseStatements() []boogie.Stmt {
 		case ASSERT, ASSUME:
 			stmts = append(stmts, p.parseAssertAssume())
 		case VAR:
-			stmts = append(stmts, p.parseVarDecl())
+			p.parseVarDecl()
 		case IDENT: // Likely an assignment: y := ...
 			stmts = append(stmts, p.parseAssignment())
 		case IF:
 			stmts = append(stmts, p.parseIf())
 		case RETURN:
-			p.nextToken()
-			expr := p.parseExpression(PREC_LOWEST)
-			p.expect(SEMI)
-			stmts = append(stmts, &boogie.Return{Values: []boogie.Expr{expr}})
+			stmts = append(stmts, p.parseReturn())
+		case HAVOC:
+			stmts = append(stmts, p.parseHavoc())
+		case CALL:
+			stmts = append(stmts, p.parseCall())
 		default:
 			p.nextToken()
 		}
@@ -276,30 +1069,129 @@ func (p *Parser) parseStatements() []boogie.Stmt {
 
 func (p *Parser) parseAssertAssume() boogie.Stmt {
 	kind := p.curr.Kind
+	pos := p.curr.Pos
 	p.nextToken() // consume ASSERT or ASSUME
 
+	attrs := p.parseAttributes()
+
 	// Use PREC_LOWEST to allow full logical expressions (e.g., x > 0 && y == 2)
 	expr := p.parseExpression(PREC_LOWEST)
 	p.expect(SEMI)
 
 	if kind == ASSERT {
-		return &boogie.Assert{Cond: expr}
+		return &boogie.Assert{Cond: expr, Attrs: attrs, Pos: pos}
 	}
 
-	return &boogie.Assume{Cond: expr}
+	return &boogie.Assume{Cond: expr, Attrs: attrs, Pos: pos}
 }
 
-func (p *Parser) parseVarDecl() boogie.Stmt {
+// parseVarDecl parses "var x, y: T;" and records the variables as
+// procedure locals rather than as statements.
+func (p *Parser) parseVarDecl() {
 	p.expect(VAR)
-	name := p.curr.Value
-	p.expect(IDENT)
+
+	var names []string
+	for {
+		names = append(names, p.curr.Value)
+		p.expect(IDENT)
+		if p.curr.Kind != COMMA {
+			break
+		}
+		p.nextToken()
+	}
+
 	p.expect(COLON)
 	ty := p.parseType()
 	p.expect(SEMI)
 
-	return &boogie.LocalDecl{
-		V: boogie.Var{Name: name, Ty: ty},
+	for _, name := range names {
+		v := boogie.Var{Name: name, Ty: ty}
+		p.declare(v)
+		p.locals = append(p.locals, v)
+	}
+}
+
+// parseHavoc parses "havoc x, y;".
+func (p *Parser) parseHavoc() boogie.Stmt {
+	pos := p.curr.Pos
+	p.expect(HAVOC)
+
+	var vars []boogie.Var
+	for {
+		vars = append(vars, p.lookup(p.curr.Value))
+		p.expect(IDENT)
+		if p.curr.Kind != COMMA {
+			break
+		}
+		p.nextToken()
+	}
+	p.expect(SEMI)
+
+	return &boogie.Havoc{Vars: vars, Pos: pos}
+}
+
+// parseCall parses "call p(args);" and "call x, y := p(args);". The
+// callee may be declared further down; the checker resolves it.
+func (p *Parser) parseCall() boogie.Stmt {
+	pos := p.curr.Pos
+	p.expect(CALL)
+
+	var rets []boogie.Var
+	if p.peek.Kind == COMMA || p.peek.Kind == ASSIGN {
+		for {
+			rets = append(rets, p.lookup(p.curr.Value))
+			p.expect(IDENT)
+			if p.curr.Kind != COMMA {
+				break
+			}
+			p.nextToken()
+		}
+		p.expect(ASSIGN)
 	}
+
+	c := &boogie.Call{Name: p.curr.Value, Rets: rets, Pos: pos}
+	p.expect(IDENT)
+	p.expect(LPAREN)
+	for p.curr.Kind != RPAREN && p.curr.Kind != EOF {
+		c.Args = append(c.Args, p.parseExpression(PREC_LOWEST))
+		if p.curr.Kind != COMMA {
+			break
+		}
+		p.nextToken()
+	}
+	p.expect(RPAREN)
+	p.expect(SEMI)
+
+	if c.Name == "New" {
+		return p.allocFrom(c)
+	}
+	return c
+}
+
+// allocFrom turns call o := New(), the built-in allocator, into an
+// Alloc.
+func (p *Parser) allocFrom(c *boogie.Call) boogie.Stmt {
+	if len(c.Args) != 0 || len(c.Rets) != 1 {
+		p.errorAt(c.Pos, "New takes no arguments and returns one reference, as in call o := New();")
+	}
+	if _, ok := c.Rets[0].Ty.(boogie.RefType); !ok {
+		p.errorAt(c.Pos, "New returns a ref, but %s has type %v", c.Rets[0].Name, c.Rets[0].Ty)
+	}
+	return &boogie.Alloc{V: c.Rets[0], Pos: c.Pos}
+}
+
+// parseReturn parses "return;" (yield the out-parameters, as in Boogie)
+// and the "return e;" shorthand.
+func (p *Parser) parseReturn() boogie.Stmt {
+	p.expect(RETURN)
+	if p.curr.Kind == SEMI {
+		p.nextToken()
+		return &boogie.Return{}
+	}
+
+	expr := p.parseExpression(PREC_LOWEST)
+	p.expect(SEMI)
+	return &boogie.Return{Values: []boogie.Expr{expr}}
 }
 
 func (p *Parser) parseIf() boogie.Stmt {
@@ -337,14 +1229,34 @@ func (p *Parser) parseIf() boogie.Stmt {
 }
 
 func (p *Parser) parseAssignment() boogie.Stmt {
-	lhsName := p.curr.Value
+	if p.isHeap(p.curr.Value) {
+		return p.parseHeapWrite()
+	}
+	pos := p.curr.Pos
+	lhs := p.lookup(p.curr.Value)
 	p.expect(IDENT)
+
+	// m[i][j] := v updates the map variable: m := m[i := m[i][j := v]].
+	var indexes [][]boogie.Expr
+	var indexPos []boogie.Pos
+	for p.curr.Kind == LBRACKET {
+		indexPos = append(indexPos, p.curr.Pos)
+		p.nextToken()
+		indexes = append(indexes, p.parseMapArgs())
+		p.expect(RBRACKET)
+	}
+
 	p.expect(ASSIGN)
 	rhs := p.parseExpression(PREC_LOWEST)
 	p.expect(SEMI)
 
+	if len(indexes) > 0 {
+		rhs = mapUpdate(&boogie.VarExpr{V: lhs}, indexes, indexPos, rhs)
+	}
+
 	return &boogie.Assign{
-		Lhs: &boogie.VarExpr{V: boogie.Var{Name: lhsName}},
+		Lhs: &boogie.VarExpr{V: lhs},
 		Rhs: rhs,
+		Pos: pos,
 	}
 }
@@ -0,0 +1,261 @@
+package frontend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+func TestParseTypes(t *testing.T) {
+	tests := []struct {
+		src  string
+		want boogie.Type
+	}{
+		{"int", boogie.IntType{}},
+		{"real", boogie.RealType{}},
+		{"bool", boogie.BoolType{}},
+		{"ref", boogie.RefType{}},
+		{"bv1", boogie.BvType{Width: 1}},
+		{"bv128", boogie.BvType{Width: 128}},
+	}
+
+	for _, tt := range tests {
+		prog, err := Parse([]byte("procedure p(x: " + tt.src + ") {}"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.src, err)
+		}
+		if got := prog.Procs[0].Params[0].Ty; got != tt.want {
+			t.Errorf("%s: parsed as %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseUnknownType(t *testing.T) {
+	for _, name := range []string{"boool", "Int", "bv0", "bv", "bvx"} {
+		_, err := Parse([]byte("procedure p(x: " + name + ") {}"))
+		if err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+		if !strings.Contains(err.Error(), "1:16: unknown type "+name) ||
+			!strings.Contains(err.Error(), "int, real, bool, ref, bvN or a declared type") {
+			t.Errorf("%s: unexpected error %q", name, err)
+		}
+	}
+}
+
+func TestParseImplications(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"a ==> b ==> c", "a ==> b ==> c"},
+		{"(a ==> b) ==> c", "(a ==> b) ==> c"},
+		{"a <== b <== c", "a <== b <== c"},
+		{"a ==> b <==> b <== a", "a ==> b <==> b <== a"},
+		{"(a <==> b) ==> c", "(a <==> b) ==> c"},
+		{"x == 1 ==> x > 0", "x == 1 ==> x > 0"},
+		{"if a then x else x + 1", "if a then x else x + 1"},
+		{"(if a then x else 0) + 1 > 0", "(if a then x else 0) + 1 > 0"},
+		{"if a then b else if c then b else a", "if a then b else if c then b else a"},
+	}
+
+	for _, tt := range tests {
+		e, err := parseExpr(tt.src)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.src, err)
+		}
+		if got := boogie.FormatExpr(e); got != tt.want {
+			t.Errorf("%s: printed as %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseMaps(t *testing.T) {
+	prog, err := Parse([]byte(`procedure p(m: [int, bool][int]real) {
+  m[1, true][2] := 1.5;
+  assert m[1, false := m[1, true]][1, false][2] == 1.5;
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := prog.Procs[0].Params[0].Ty.String(); got != "[int,bool][int]real" {
+		t.Errorf("map type parsed as %s", got)
+	}
+
+	// m[i][j] := v is the update m := m[i := m[i][j := v]].
+	a := prog.Procs[0].Body[0].(*boogie.Assign)
+	if got := boogie.FormatExpr(a.Rhs); got != "m[1, true := m[1, true][2 := 1.5]]" {
+		t.Errorf("indexed assignment parsed as m := %s", got)
+	}
+
+	c := prog.Procs[0].Body[1].(*boogie.Assert).Cond
+	if got := boogie.FormatExpr(c); got != "m[1, false := m[1, true]][1, false][2] == 1.5" {
+		t.Errorf("select and update parsed as %s", got)
+	}
+}
+
+func TestParseGenerics(t *testing.T) {
+	prog, err := Parse([]byte(`type Field _;
+type Pair _ _;
+procedure p<a>(h: <a>[Field a]a, f: Field (Field a), q: Pair int [int]bool) returns (x: a)
+{
+  call x := p(h, f, q);
+  call p(h, f, q);
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(prog.Types) != 2 || prog.Types[0].Arity != 1 || prog.Types[1].Arity != 2 {
+		t.Fatalf("type declarations parsed as %+v", prog.Types)
+	}
+
+	p := prog.Procs[0]
+	if len(p.TypeParams) != 1 || p.TypeParams[0] != "a" {
+		t.Errorf("type parameters parsed as %v", p.TypeParams)
+	}
+	for i, want := range []string{"<a>[Field a]a", "Field (Field a)", "Pair int ([int]bool)"} {
+		if got := p.Params[i].Ty.String(); got != want {
+			t.Errorf("parameter %d type parsed as %s, want %s", i, got, want)
+		}
+	}
+	if _, ok := p.Rets[0].Ty.(boogie.TypeVar); !ok {
+		t.Errorf("result type parsed as %T, want a type variable", p.Rets[0].Ty)
+	}
+
+	c := p.Body[0].(*boogie.Call)
+	if c.Name != "p" || len(c.Args) != 3 || len(c.Rets) != 1 || c.Rets[0].Name != "x" {
+		t.Errorf("call parsed as %+v", c)
+	}
+	if c := p.Body[1].(*boogie.Call); len(c.Rets) != 0 {
+		t.Errorf("call without results parsed with results %v", c.Rets)
+	}
+}
+
+func TestParseHeap(t *testing.T) {
+	prog, err := Parse([]byte(`type Field _;
+const unique next: Field ref;
+const val, count: Field int;
+var Heap: <a>[ref, Field a]a;
+procedure p(o: ref)
+  modifies Heap;
+{
+  Heap[o, val] := Heap[Heap[o, next], val] + 1;
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var fields []string
+	for _, f := range prog.Fields {
+		fields = append(fields, f.Name+": "+f.Ty.String())
+	}
+	if got := strings.Join(fields, ", "); got != "next: ref, val: int, count: int" {
+		t.Errorf("fields parsed as %s", got)
+	}
+
+	w, ok := prog.Procs[0].Body[0].(*boogie.HeapWrite)
+	if !ok || w.Field != "val" {
+		t.Fatalf("heap assignment parsed as %#v", prog.Procs[0].Body[0])
+	}
+	if got := boogie.FormatExpr(w.Value); got != "Heap[Heap[o, next], val] + 1" {
+		t.Errorf("heap read parsed as %s", got)
+	}
+	if got := w.Value.Type(); got != (boogie.IntType{}) {
+		t.Errorf("heap read typed %v, want int", got)
+	}
+}
+
+func TestParseAlloc(t *testing.T) {
+	prog, err := Parse([]byte(`const null: ref;
+procedure p() returns (o: ref)
+{
+  call o := New();
+  assert o != null;
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	a, ok := prog.Procs[0].Body[0].(*boogie.Alloc)
+	if !ok || a.V.Name != "o" {
+		t.Fatalf("call o := New() parsed as %#v", prog.Procs[0].Body[0])
+	}
+	c := prog.Procs[0].Body[1].(*boogie.Assert).Cond.(*boogie.BinOp)
+	if _, ok := c.Right.(*boogie.NullLit); !ok {
+		t.Errorf("null parsed as %#v", c.Right)
+	}
+}
+
+func TestParseFunctionBody(t *testing.T) {
+	prog, err := Parse([]byte(`function {:memoize} sq(x: int): int { x * x }
+function f(x: int, y: int) returns (bool) { sq(x) < y }
+procedure p() returns (b: bool)
+{
+  b := f(1, 2);
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sq, f := prog.Funcs[0], prog.Funcs[1]
+	if _, ok := boogie.FindAttr(sq.Attrs, "memoize"); !ok {
+		t.Errorf("attributes parsed as %v", sq.Attrs)
+	}
+	if got := boogie.FormatExpr(sq.Body); got != "x * x" {
+		t.Errorf("body of sq parsed as %s", got)
+	}
+	if got := boogie.FormatExpr(f.Body); got != "sq(x) < y" {
+		t.Errorf("body of f parsed as %s", got)
+	}
+	if app := f.Body.(*boogie.BinOp).Left.(*boogie.FuncApp); app.Func != sq {
+		t.Errorf("sq applied as %#v", app.Func)
+	}
+
+	a := prog.Procs[0].Body[0].(*boogie.Assign)
+	if app, ok := a.Rhs.(*boogie.FuncApp); !ok || app.Func != f {
+		t.Errorf("application parsed as %#v", a.Rhs)
+	}
+
+	if _, err := Parse([]byte("function f(int): int { 1 }")); err == nil || !strings.Contains(err.Error(), "parameters need names") {
+		t.Errorf("unnamed parameter of a body: got error %v", err)
+	}
+	if _, err := Parse([]byte("function f(x: int): int { y }")); err == nil || !strings.Contains(err.Error(), "undeclared identifier y") {
+		t.Errorf("body reading a non-parameter: got error %v", err)
+	}
+}
+
+func TestParseBodilessProcedure(t *testing.T) {
+	prog, err := Parse([]byte(`procedure {:extern "example.com/io.Read"} Read(fd: int) returns (x: int, ok: bool);
+procedure p() {}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := prog.Procs[0]
+	if !r.Bodiless || len(r.Params) != 1 || len(r.Rets) != 2 {
+		t.Errorf("declaration parsed as %+v", r)
+	}
+	if e, ok := boogie.FindProcExtern(r); !ok || e.Path != "example.com/io" || e.Name != "Read" {
+		t.Errorf("binding parsed as %+v", e)
+	}
+	if prog.Procs[1].Bodiless {
+		t.Error("an empty body parsed as none")
+	}
+}
+
+func TestParseNegatedLiteral(t *testing.T) {
+	prog, err := Parse([]byte("procedure p() { var x: int; x := -9223372036854775808; x := -x; }"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lit, ok := prog.Procs[0].Body[0].(*boogie.Assign).Rhs.(*boogie.IntLit)
+	if !ok || lit.Big != nil || lit.Value != -9223372036854775808 || lit.Pos.Col != 34 {
+		t.Errorf("-9223372036854775808 parsed as %#v", prog.Procs[0].Body[0].(*boogie.Assign).Rhs)
+	}
+	if _, ok := prog.Procs[0].Body[1].(*boogie.Assign).Rhs.(*boogie.UnOp); !ok {
+		t.Error("-x parsed as other than a negation")
+	}
+}
@@ -0,0 +1,157 @@
+package frontend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// The corpus below follows the expression grammar of Boogie's reference
+// parser (Boogie.atg), loosest first:
+//
+//	EquivExpr      = ImpliesExpr { "<==>" ImpliesExpr }
+//	ImpliesExpr    = LogicalExpr [ "==>" ImpliesExpr
+//	                             | "<==" LogicalExpr { "<==" LogicalExpr } ]
+//	LogicalExpr    = RelationalExpr [ "&&" RelationalExpr { "&&" RelationalExpr }
+//	                                | "||" RelationalExpr { "||" RelationalExpr } ]
+//	RelationalExpr = BvTerm [ RelOp BvTerm ]
+//	BvTerm         = Term { "++" Term }
+//	Term           = Factor { AddOp Factor }
+//	Factor         = UnaryExpression { MulOp UnaryExpression }
+//	UnaryExpression = "-" UnaryExpression | "!" UnaryExpression | primary
+
+const precedenceDecls = "a: bool, b: bool, c: bool, d: bool, " +
+	"x: int, y: int, z: int, w: int, r: real, s: real, u: bv8, v: bv8, t: bv8"
+
+func parseExpr(src string) (boogie.Expr, error) {
+	prog, err := Parse([]byte("procedure p(" + precedenceDecls + ") { assert " + src + "; }"))
+	if err != nil {
+		return nil, err
+	}
+	return prog.Procs[0].Body[0].(*boogie.Assert).Cond, nil
+}
+
+// grouped prints e with every operator application parenthesized.
+func grouped(e boogie.Expr) string {
+	switch ex := e.(type) {
+	case *boogie.BinOp:
+		return "(" + grouped(ex.Left) + " " + ex.Op.String() + " " + grouped(ex.Right) + ")"
+	case *boogie.UnOp:
+		return "(" + ex.Op.String() + grouped(ex.X) + ")"
+	case *boogie.IfThenElse:
+		return "(if " + grouped(ex.Cond) + " then " + grouped(ex.Then) + " else " + grouped(ex.Else) + ")"
+	default:
+		return boogie.FormatExpr(e)
+	}
+}
+
+var precedenceCorpus = []struct {
+	src, want string
+}{
+	// Equivalence: loosest, left-associative.
+	{"a <==> b <==> c", "((a <==> b) <==> c)"},
+	{"a <==> b ==> c", "(a <==> (b ==> c))"},
+	{"a ==> b <==> c", "((a ==> b) <==> c)"},
+	{"a <== b <==> c <== d", "((a <== b) <==> (c <== d))"},
+
+	// Implication: ==> right-associative, <== left-associative.
+	{"a ==> b ==> c", "(a ==> (b ==> c))"},
+	{"a ==> b ==> c ==> d", "(a ==> (b ==> (c ==> d)))"},
+	{"a <== b <== c", "((a <== b) <== c)"},
+	{"a && b ==> c", "((a && b) ==> c)"},
+	{"a ==> b || c", "(a ==> (b || c))"},
+	{"a ==> b && c ==> d", "(a ==> ((b && c) ==> d))"},
+	{"a || b <== c && d", "((a || b) <== (c && d))"},
+
+	// && and ||: one level, each left-associative.
+	{"a && b && c", "((a && b) && c)"},
+	{"a || b || c", "((a || b) || c)"},
+	{"x < y && y < z", "((x < y) && (y < z))"},
+	{"a || x == y", "(a || (x == y))"},
+	{"(a && b) || c", "((a && b) || c)"},
+	{"a && (b || c)", "(a && (b || c))"},
+
+	// Relational: below ++ and arithmetic, non-associative.
+	{"x + y == z", "((x + y) == z)"},
+	{"x * y < z - w", "((x * y) < (z - w))"},
+	{"x != y + 1", "(x != (y + 1))"},
+	{"(x < y) == a", "((x < y) == a)"},
+	{"r / s >= r", "((r / s) >= r)"},
+
+	// Concatenation: between relational and additive.
+	{"u ++ v ++ t", "((u ++ v) ++ t)"},
+	{"u ++ v == t ++ u", "((u ++ v) == (t ++ u))"},
+
+	// Additive and multiplicative: left-associative, mixing freely.
+	{"x - y - z", "((x - y) - z)"},
+	{"x - y + z", "((x - y) + z)"},
+	{"x + y * z", "(x + (y * z))"},
+	{"x * y + z", "((x * y) + z)"},
+	{"x * y div z mod w", "(((x * y) div z) mod w)"},
+	{"x - (y - z)", "(x - (y - z))"},
+	{"x div (y * z)", "(x div (y * z))"},
+	{"r * s / r", "((r * s) / r)"},
+
+	// Unary: tighter than every binary operator, and nesting.
+	{"-x * y", "((-x) * y)"},
+	{"-x + y", "((-x) + y)"},
+	{"x - -y", "(x - (-y))"},
+	{"- -x < y", "((-(-x)) < y)"},
+	{"-(x + y)", "(-(x + y))"},
+	{"!a == b", "((!a) == b)"},
+	{"!!a && b", "((!(!a)) && b)"},
+	{"!(a && b)", "(!(a && b))"},
+	{"!a ==> !b", "((!a) ==> (!b))"},
+
+	// if-then-else: the else branch extends as far right as it can.
+	{"if a then x else y + 1 > 0", "(if a then x else ((y + 1) > 0))"},
+	{"(if a then x else y) + 1 > 0", "(((if a then x else y) + 1) > 0)"},
+	{"if a then b else c ==> d", "(if a then b else (c ==> d))"},
+}
+
+func TestPrecedenceCorpus(t *testing.T) {
+	for _, tt := range precedenceCorpus {
+		e, err := parseExpr(tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if got := grouped(e); got != tt.want {
+			t.Errorf("%s: parsed as %s, want %s", tt.src, got, tt.want)
+		}
+
+		// FormatExpr drops parentheses where the grammar allows; the
+		// result must parse back to the same tree.
+		back, err := parseExpr(boogie.FormatExpr(e))
+		if err != nil {
+			t.Errorf("%s: printed as %s, which does not parse: %v", tt.src, boogie.FormatExpr(e), err)
+			continue
+		}
+		if !boogie.EqualExpr(e, back) {
+			t.Errorf("%s: printed as %s, which parses as %s", tt.src, boogie.FormatExpr(e), grouped(back))
+		}
+	}
+}
+
+func TestPrecedenceNeedsParentheses(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"a && b || c", "|| after && needs parentheses"},
+		{"a || b && c", "&& after || needs parentheses"},
+		{"a ==> b <== c", "<== after ==> needs parentheses"},
+		{"a <== b ==> c", "==> after <== needs parentheses"},
+		{"x < y < z", "< after < needs parentheses"},
+		{"x == y == z", "== after == needs parentheses"},
+		{"x < y == a", "== after < needs parentheses"},
+		{"a ==> b && c || d", "|| after && needs parentheses"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseExpr(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.src, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package boogie
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// FormatExpr renders an expression in Boogie surface syntax, adding
+// parentheses only where precedence requires them. It is used for
+// diagnostics and for the expression text embedded in runtime checks.
+func FormatExpr(e Expr) string {
+	var b strings.Builder
+	formatExpr(&b, e, 0)
+	return b.String()
+}
+
+const precUnary = 10
+
+var binOpPrec = map[BinOpKind]int{
+	Iff:     1,
+	Implies: 2,
+	Explies: 2,
+	Or:      3,
+	And:     3,
+	Eq:      4,
+	Neq:     4,
+	Lt:      4,
+	Lte:     4,
+	Gt:      4,
+	Gte:     4,
+	Concat:  5,
+	Add:     6,
+	Sub:     6,
+	Mul:     7,
+	Div:     7,
+	IntDiv:  7,
+	Mod:     7,
+}
+
+var binOpSym = map[BinOpKind]string{
+	Add: "+",
+	Sub: "-",
+	Mul: "*",
+	Div: "/",
+	Eq:  "==",
+	Neq: "!=",
+	Lt:  "<",
+	Lte: "<=",
+	Gt:  ">",
+	Gte: ">=",
+	And: "&&",
+	Or:  "||",
+
+	Concat:  "++",
+	Implies: "==>",
+	Explies: "<==",
+	Iff:     "<==>",
+	IntDiv:  "div",
+	Mod:     "mod",
+}
+
+// String returns the Boogie spelling of the operator.
+func (k BinOpKind) String() string {
+	if s, ok := binOpSym[k]; ok {
+		return s
+	}
+	return "?"
+}
+
+// String returns the Boogie spelling of the operator.
+func (k UnOpKind) String() string {
+	switch k {
+	case Not:
+		return "!"
+	case Neg:
+		return "-"
+	default:
+		return "?"
+	}
+}
+
+// formatExpr writes e, parenthesized if it binds looser than ctx.
+func formatExpr(b *strings.Builder, e Expr, ctx int) {
+	switch ex := e.(type) {
+
+	case *VarExpr:
+		b.WriteString(ex.V.Name)
+
+	case *IntLit:
+		b.WriteString(ex.BigValue().String())
+
+	case *BoolLit:
+		b.WriteString(strconv.FormatBool(ex.Value))
+
+	case *RealLit:
+		b.WriteString(decimalString(ex.Value))
+
+	case *Convert:
+		b.WriteString(ex.To.String() + "(")
+		formatExpr(b, ex.X, 0)
+		b.WriteString(")")
+
+	case *BvLit:
+		b.WriteString(ex.Value.String() + "bv" + strconv.Itoa(ex.Width))
+
+	case *BvExtract:
+		formatExpr(b, ex.X, precUnary+1)
+		b.WriteString("[" + strconv.Itoa(ex.Hi) + ":" + strconv.Itoa(ex.Lo) + "]")
+
+	case *MapSelect:
+		formatExpr(b, ex.Map, precUnary+1)
+		b.WriteString("[")
+		formatArgs(b, ex.Args)
+		b.WriteString("]")
+
+	case *MapStore:
+		formatExpr(b, ex.Map, precUnary+1)
+		b.WriteString("[")
+		formatArgs(b, ex.Args)
+		b.WriteString(" := ")
+		formatExpr(b, ex.Value, 0)
+		b.WriteString("]")
+
+	case *FuncApp:
+		b.WriteString(ex.Func.Name + "(")
+		formatArgs(b, ex.Args)
+		b.WriteString(")")
+
+	case *BinOp:
+		prec := binOpPrec[ex.Op]
+		if prec < ctx {
+			b.WriteString("(")
+		}
+		formatOperand(b, ex.Left, ex.Op, true)
+		b.WriteString(" " + ex.Op.String() + " ")
+		formatOperand(b, ex.Right, ex.Op, false)
+		if prec < ctx {
+			b.WriteString(")")
+		}
+
+	case *UnOp:
+		b.WriteString(ex.Op.String())
+		formatExpr(b, ex.X, precUnary)
+
+	case *IfThenElse:
+		// The else branch extends as far right as it can.
+		if ctx > 0 {
+			b.WriteString("(")
+		}
+		b.WriteString("if ")
+		formatExpr(b, ex.Cond, 0)
+		b.WriteString(" then ")
+		formatExpr(b, ex.Then, 0)
+		b.WriteString(" else ")
+		formatExpr(b, ex.Else, 0)
+		if ctx > 0 {
+			b.WriteString(")")
+		}
+
+	case *NullLit:
+		b.WriteString("null")
+
+	case *HeapRead:
+		b.WriteString("Heap[")
+		formatExpr(b, ex.Obj, 0)
+		b.WriteString(", " + ex.Field + "]")
+
+	default:
+		b.WriteString("<?>")
+	}
+}
+
+// formatArgs writes a comma-separated argument list.
+func formatArgs(b *strings.Builder, args []Expr) {
+	for i, a := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		formatExpr(b, a, 0)
+	}
+}
+
+// formatOperand writes the left or right operand of op. An operand at
+// op's own precedence goes without parentheses only where the parser
+// would group it that way.
+func formatOperand(b *strings.Builder, e Expr, op BinOpKind, left bool) {
+	prec := binOpPrec[op]
+	if x, ok := e.(*BinOp); ok && binOpPrec[x.Op] == prec && chains(op, x.Op, left) {
+		formatExpr(b, e, prec)
+		return
+	}
+	formatExpr(b, e, prec+1)
+}
+
+// chains reports whether inner, at the same precedence as outer, may
+// stand unparenthesized as its left or right operand. Arithmetic
+// operators mix and associate left; &&, ||, ++, <==> and <== associate
+// left but do not mix; ==> associates right; comparisons do not chain.
+func chains(outer, inner BinOpKind, left bool) bool {
+	switch outer {
+	case Implies:
+		return !left && inner == Implies
+	case Eq, Neq, Lt, Lte, Gt, Gte:
+		return false
+	case And, Or, Concat, Iff, Explies:
+		return left && inner == outer
+	default:
+		return left
+	}
+}
+
+// decimalString writes r as a decimal literal. Real literals are
+// decimals, so their denominators divide a power of ten; other values
+// fall back to a quotient.
+func decimalString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String() + ".0"
+	}
+	pow := big.NewInt(10)
+	for digits := 1; digits <= 100; digits++ {
+		if new(big.Int).Mod(pow, r.Denom()).Sign() == 0 {
+			return r.FloatString(digits)
+		}
+		pow.Mul(pow, big.NewInt(10))
+	}
+	return "(" + r.Num().String() + ".0 / " + r.Denom().String() + ".0)"
+}
@@ -0,0 +1,263 @@
+package ranges
+
+import (
+	"math"
+	"math/big"
+)
+
+// Interval is the set of integers between Lo and Hi, inclusive. A nil
+// bound is infinite: Lo == nil means unbounded below, Hi == nil
+// unbounded above. Intervals are never empty; an unreachable state is
+// represented by the absence of a State instead.
+type Interval struct {
+	Lo, Hi *big.Int
+}
+
+var (
+	minInt64 = big.NewInt(math.MinInt64)
+	maxInt64 = big.NewInt(math.MaxInt64)
+)
+
+// Top is the interval of all integers.
+func Top() Interval { return Interval{} }
+
+// Const is the interval holding just v.
+func Const(v *big.Int) Interval { return Interval{Lo: v, Hi: v} }
+
+// FitsInt64 reports whether every value in i is representable as an
+// int64.
+func (i Interval) FitsInt64() bool {
+	return i.Lo != nil && i.Hi != nil && i.Lo.Cmp(minInt64) >= 0 && i.Hi.Cmp(maxInt64) <= 0
+}
+
+func (i Interval) String() string {
+	lo, hi := "-inf", "+inf"
+	if i.Lo != nil {
+		lo = i.Lo.String()
+	}
+	if i.Hi != nil {
+		hi = i.Hi.String()
+	}
+	return "[" + lo + ", " + hi + "]"
+}
+
+// Join is the smallest interval containing both i and j.
+func (i Interval) Join(j Interval) Interval {
+	return Interval{Lo: minBound(i.Lo, j.Lo), Hi: maxBound(i.Hi, j.Hi)}
+}
+
+// Widen extrapolates a growing bound of next (relative to i) to
+// infinity, which is what makes loop analysis terminate.
+func (i Interval) Widen(next Interval) Interval {
+	w := i
+	if i.Lo != nil && (next.Lo == nil || next.Lo.Cmp(i.Lo) < 0) {
+		w.Lo = nil
+	}
+	if i.Hi != nil && (next.Hi == nil || next.Hi.Cmp(i.Hi) > 0) {
+		w.Hi = nil
+	}
+	return w
+}
+
+// Meet intersects i and j; ok is false if they are disjoint.
+func (i Interval) Meet(j Interval) (m Interval, ok bool) {
+	m = i
+	if j.Lo != nil && (m.Lo == nil || j.Lo.Cmp(m.Lo) > 0) {
+		m.Lo = j.Lo
+	}
+	if j.Hi != nil && (m.Hi == nil || j.Hi.Cmp(m.Hi) < 0) {
+		m.Hi = j.Hi
+	}
+	if m.Lo != nil && m.Hi != nil && m.Lo.Cmp(m.Hi) > 0 {
+		return Interval{}, false
+	}
+	return m, true
+}
+
+func (i Interval) Add(j Interval) Interval {
+	return Interval{Lo: addBound(i.Lo, j.Lo), Hi: addBound(i.Hi, j.Hi)}
+}
+
+func (i Interval) Neg() Interval {
+	return Interval{Lo: negBound(i.Hi), Hi: negBound(i.Lo)}
+}
+
+func (i Interval) Sub(j Interval) Interval {
+	return i.Add(j.Neg())
+}
+
+// Mul multiplies the extremes pairwise; the result spans the smallest
+// and largest product.
+func (i Interval) Mul(j Interval) Interval {
+	lo, hi := i.lower(), i.upper()
+	jlo, jhi := j.lower(), j.upper()
+	ps := []ext{lo.mul(jlo), lo.mul(jhi), hi.mul(jlo), hi.mul(jhi)}
+
+	min, max := ps[0], ps[0]
+	for _, p := range ps[1:] {
+		if p.cmp(min) < 0 {
+			min = p
+		}
+		if p.cmp(max) > 0 {
+			max = p
+		}
+	}
+	return Interval{Lo: min.v, Hi: max.v}
+}
+
+// Div is Euclidean division. With a positive divisor it is floor
+// division, monotone in both operands, so the extremes are again at the
+// corners. Otherwise |x div y| <= |x| + 1 whenever y != 0; a zero
+// divisor faults, so it contributes no value.
+func (i Interval) Div(j Interval) Interval {
+	if j.Lo == nil || j.Lo.Sign() <= 0 {
+		if i.Lo == nil || i.Hi == nil {
+			return Top()
+		}
+		m := new(big.Int).Abs(i.Lo)
+		if h := new(big.Int).Abs(i.Hi); h.Cmp(m) > 0 {
+			m = h
+		}
+		m.Add(m, one)
+		return Interval{Lo: new(big.Int).Neg(m), Hi: m}
+	}
+
+	lo, hi := i.lower(), i.upper()
+	jlo, jhi := j.lower(), j.upper()
+	min := lo.floorDiv(jlo)
+	if q := lo.floorDiv(jhi); q.cmp(min) < 0 {
+		min = q
+	}
+	max := hi.floorDiv(jlo)
+	if q := hi.floorDiv(jhi); q.cmp(max) > 0 {
+		max = q
+	}
+	return Interval{Lo: min.v, Hi: max.v}
+}
+
+// Mod is the Euclidean remainder: at least zero and below |y|. It
+// cannot exceed a non-negative x either.
+func (i Interval) Mod(j Interval) Interval {
+	r := Interval{Lo: new(big.Int)}
+	if j.Lo != nil && j.Hi != nil {
+		m := new(big.Int).Abs(j.Lo)
+		if h := new(big.Int).Abs(j.Hi); h.Cmp(m) > 0 {
+			m = h
+		}
+		if m.Sign() == 0 {
+			return zero() // always faults
+		}
+		r.Hi = m.Sub(m, one)
+	}
+	if i.Lo != nil && i.Lo.Sign() >= 0 && i.Hi != nil && (r.Hi == nil || i.Hi.Cmp(r.Hi) < 0) {
+		r.Hi = i.Hi
+	}
+	return r
+}
+
+// ext is an integer extended with -inf (inf < 0) and +inf (inf > 0).
+type ext struct {
+	inf int
+	v   *big.Int
+}
+
+func (i Interval) lower() ext {
+	if i.Lo == nil {
+		return ext{inf: -1}
+	}
+	return ext{v: i.Lo}
+}
+
+func (i Interval) upper() ext {
+	if i.Hi == nil {
+		return ext{inf: 1}
+	}
+	return ext{v: i.Hi}
+}
+
+func (a ext) sign() int {
+	if a.inf != 0 {
+		return a.inf
+	}
+	return a.v.Sign()
+}
+
+func (a ext) mul(b ext) ext {
+	if a.inf == 0 && b.inf == 0 {
+		return ext{v: new(big.Int).Mul(a.v, b.v)}
+	}
+	// Zero times infinity is zero: the finite factor really is zero.
+	s := a.sign() * b.sign()
+	if s == 0 {
+		return ext{v: new(big.Int)}
+	}
+	return ext{inf: s}
+}
+
+// floorDiv is floor(a / b) for a positive b. An infinite a stays
+// infinite; a finite a divided by +inf tends to 0 or, below zero, -1.
+func (a ext) floorDiv(b ext) ext {
+	switch {
+	case a.inf != 0:
+		return a
+	case b.inf != 0:
+		if a.v.Sign() < 0 {
+			return ext{v: big.NewInt(-1)}
+		}
+		return ext{v: new(big.Int)}
+	default:
+		return ext{v: new(big.Int).Div(a.v, b.v)}
+	}
+}
+
+func (a ext) cmp(b ext) int {
+	switch {
+	case a.inf != 0 || b.inf != 0:
+		if a.inf == b.inf {
+			return 0
+		}
+		if a.inf < b.inf {
+			return -1
+		}
+		return 1
+	default:
+		return a.v.Cmp(b.v)
+	}
+}
+
+// Bound helpers. A nil bound is infinite, in the direction the caller
+// knows from context (both operands are lower bounds, or both upper).
+
+func minBound(a, b *big.Int) *big.Int {
+	if a == nil || b == nil {
+		return nil
+	}
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+func maxBound(a, b *big.Int) *big.Int {
+	if a == nil || b == nil {
+		return nil
+	}
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+func addBound(a, b *big.Int) *big.Int {
+	if a == nil || b == nil {
+		return nil
+	}
+	return new(big.Int).Add(a, b)
+}
+
+func negBound(a *big.Int) *big.Int {
+	if a == nil {
+		return nil
+	}
+	return new(big.Int).Neg(a)
+}
@@ -0,0 +1,491 @@
+// Package ranges is an interval analysis over structured Boogie
+// procedures. It proves which int variables always hold values that
+// fit in an int64, so code generation can use machine integers for
+// them and keep big integers only where they are needed.
+package ranges
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// Result is the outcome of analysing one procedure.
+type Result struct {
+	vars    []string            // int variables, in declaration order
+	hull    map[string]Interval // every value a variable ever holds
+	reasons map[string]string   // why a variable needs a big integer
+}
+
+// Range returns the interval covering every value name can hold
+// anywhere in the procedure.
+func (r *Result) Range(name string) Interval {
+	if i, ok := r.hull[name]; ok {
+		return i
+	}
+	return Top()
+}
+
+// Fits reports whether the int variable name can be an int64.
+func (r *Result) Fits(name string) bool {
+	_, ok := r.hull[name]
+	return ok && r.reasons[name] == ""
+}
+
+// Reason explains why name needs a big integer; it is "" if Fits.
+func (r *Result) Reason(name string) string {
+	return r.reasons[name]
+}
+
+// Eval bounds the values of an int expression anywhere in the
+// procedure. Since every variable is bounded by its Range, so is every
+// intermediate result, which is what code generation needs to know.
+func (r *Result) Eval(e boogie.Expr) Interval {
+	return eval(r.hull, e)
+}
+
+// Analyze computes the ranges of the int variables of p.
+//
+// Parameters are unbounded, since any caller may pass any value, and
+// out-parameters always get big integers so that procedure signatures
+// agree without looking across calls. Locals start at zero, as in the
+// generated code.
+func Analyze(p *boogie.Procedure) *Result {
+	a := &analyzer{res: &Result{
+		hull:    make(map[string]Interval),
+		reasons: make(map[string]string),
+	}}
+
+	entry := make(State)
+	for _, v := range p.Params {
+		if isInt(v.Ty) {
+			entry[v.Name] = Top()
+			a.declare(v.Name, Top(), "parameter: callers may pass any integer")
+		}
+	}
+	for _, v := range p.Rets {
+		if isInt(v.Ty) {
+			entry[v.Name] = zero()
+			a.declare(v.Name, zero(), "out-parameter: procedure signatures keep big integers")
+		}
+	}
+	for _, v := range p.Locals {
+		if isInt(v.Ty) {
+			entry[v.Name] = zero()
+			a.declare(v.Name, zero(), "")
+		}
+	}
+
+	a.stmts(entry, p.Body)
+	return a.res
+}
+
+// Report lists, for each procedure of p, the int variables that need
+// big integers and why; it is empty if every variable fits an int64.
+func Report(p *boogie.Program) string {
+	var b strings.Builder
+	for _, proc := range p.Procs {
+		r := Analyze(proc)
+		for _, name := range r.vars {
+			if reason := r.reasons[name]; reason != "" {
+				fmt.Fprintf(&b, "%s: %s needs big integers: %s\n", proc.Name, name, reason)
+			}
+		}
+	}
+	return b.String()
+}
+
+// State maps each int variable to its possible values at a program
+// point. A nil State is unreachable.
+type State map[string]Interval
+
+func (s State) clone() State {
+	c := make(State, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
+
+func join(s, t State) State {
+	if s == nil {
+		return t
+	}
+	if t == nil {
+		return s
+	}
+	j := make(State, len(s))
+	for k, v := range s {
+		j[k] = v.Join(t[k])
+	}
+	return j
+}
+
+func widen(s, t State) State {
+	if s == nil {
+		return t
+	}
+	if t == nil {
+		return s
+	}
+	w := make(State, len(s))
+	for k, v := range s {
+		w[k] = v.Widen(t[k])
+	}
+	return w
+}
+
+func equal(s, t State) bool {
+	if s == nil || t == nil {
+		return s == nil && t == nil
+	}
+	for k, v := range s {
+		u := t[k]
+		if !boundEq(v.Lo, u.Lo) || !boundEq(v.Hi, u.Hi) {
+			return false
+		}
+	}
+	return true
+}
+
+type analyzer struct {
+	res *Result
+}
+
+func (a *analyzer) declare(name string, init Interval, reason string) {
+	a.res.vars = append(a.res.vars, name)
+	a.res.hull[name] = init
+	if reason != "" {
+		a.res.reasons[name] = reason
+	}
+}
+
+// assign records that name may hold any value in v, blaming why if
+// that leaves the int64 range.
+func (a *analyzer) assign(s State, name string, v Interval, why func() string) State {
+	s = s.clone()
+	s[name] = v
+	if _, ok := a.res.hull[name]; ok {
+		a.res.hull[name] = a.res.hull[name].Join(v)
+		if !v.FitsInt64() && a.res.reasons[name] == "" {
+			a.res.reasons[name] = why()
+		}
+	}
+	return s
+}
+
+func (a *analyzer) stmts(s State, stmts []boogie.Stmt) State {
+	for _, st := range stmts {
+		if s == nil {
+			return nil
+		}
+		s = a.stmt(s, st)
+	}
+	return s
+}
+
+func (a *analyzer) stmt(s State, st boogie.Stmt) State {
+	switch st := st.(type) {
+
+	case *boogie.Assign:
+		x, ok := st.Lhs.(*boogie.VarExpr)
+		if !ok || !isInt(x.V.Ty) {
+			return s
+		}
+		v := eval(s, st.Rhs)
+		return a.assign(s, x.V.Name, v, func() string {
+			return fmt.Sprintf("%s := %s%s may leave the int64 range %s",
+				x.V.Name, boogie.FormatExpr(st.Rhs), at(st.Pos), v)
+		})
+
+	case *boogie.Havoc:
+		for _, v := range st.Vars {
+			if isInt(v.Ty) {
+				s = a.assign(s, v.Name, Top(), func() string {
+					return "havoc" + at(st.Pos) + " may pick any integer"
+				})
+			}
+		}
+		return s
+
+	case *boogie.Call:
+		for _, v := range st.Rets {
+			if isInt(v.Ty) {
+				s = a.assign(s, v.Name, Top(), func() string {
+					return "result of call to " + st.Name + " is not bounded"
+				})
+			}
+		}
+		return s
+
+	case *boogie.If:
+		return join(
+			a.stmts(refine(s, st.Cond, true), st.Then),
+			a.stmts(refine(s, st.Cond, false), st.Else),
+		)
+
+	case *boogie.Choice:
+		var out State
+		for i, br := range st.Branches {
+			in := s
+			if g := st.Guards[i]; g != nil {
+				in = refine(s, g, true)
+			}
+			out = join(out, a.stmts(in, br))
+		}
+		return out
+
+	case *boogie.While:
+		return a.loop(s, st)
+
+	case *boogie.Check:
+		// Past a check that aborts the path, its condition holds.
+		switch st.Action {
+		case boogie.CheckPanic, boogie.CheckAssume, boogie.CheckFilter:
+			return refine(s, st.Cond, true)
+		}
+		return s
+
+	case *boogie.Assume:
+		return refine(s, st.Cond, true)
+
+	case *boogie.Return:
+		return nil
+
+	default:
+		return s
+	}
+}
+
+// loop finds a state at the loop head that every iteration preserves,
+// widening so that the search terminates, then narrows it once to win
+// back the bound the loop condition gives.
+func (a *analyzer) loop(entry State, w *boogie.While) State {
+	head := entry
+	for {
+		next := widen(head, join(head, a.stmts(refine(head, w.Cond, true), w.Body)))
+		if equal(next, head) {
+			break
+		}
+		head = next
+	}
+
+	narrowed := join(entry, a.stmts(refine(head, w.Cond, true), w.Body))
+	return refine(narrowed, w.Cond, false)
+}
+
+// ========================
+// Expressions
+// ========================
+
+func eval(s State, e boogie.Expr) Interval {
+	switch ex := e.(type) {
+
+	case *boogie.IntLit:
+		return Const(ex.BigValue())
+
+	case *boogie.VarExpr:
+		if i, ok := s[ex.V.Name]; ok {
+			return i
+		}
+		return Top()
+
+	case *boogie.BinOp:
+		l, r := eval(s, ex.Left), eval(s, ex.Right)
+		switch ex.Op {
+		case boogie.Add:
+			return l.Add(r)
+		case boogie.Sub:
+			return l.Sub(r)
+		case boogie.Mul:
+			return l.Mul(r)
+		case boogie.IntDiv:
+			return l.Div(r)
+		case boogie.Mod:
+			return l.Mod(r)
+		}
+		return Top()
+
+	case *boogie.UnOp:
+		if ex.Op == boogie.Neg {
+			return eval(s, ex.X).Neg()
+		}
+		return Top()
+
+	case *boogie.IfThenElse:
+		t, f := refine(s, ex.Cond, true), refine(s, ex.Cond, false)
+		switch {
+		case t == nil && f == nil:
+			return Top()
+		case t == nil:
+			return eval(f, ex.Else)
+		case f == nil:
+			return eval(t, ex.Then)
+		}
+		return eval(t, ex.Then).Join(eval(f, ex.Else))
+
+	default:
+		return Top()
+	}
+}
+
+// refine narrows s to the states where cond evaluates to truth; it
+// returns nil if there are none.
+func refine(s State, cond boogie.Expr, truth bool) State {
+	if s == nil {
+		return nil
+	}
+
+	switch c := cond.(type) {
+
+	case *boogie.BoolLit:
+		if c.Value != truth {
+			return nil
+		}
+		return s
+
+	case *boogie.UnOp:
+		if c.Op == boogie.Not {
+			return refine(s, c.X, !truth)
+		}
+		return s
+
+	case *boogie.BinOp:
+		switch {
+		case c.Op == boogie.And && truth, c.Op == boogie.Or && !truth:
+			return refine(refine(s, c.Left, truth), c.Right, truth)
+		case c.Op == boogie.And, c.Op == boogie.Or:
+			return join(refine(s, c.Left, truth), refine(s, c.Right, truth))
+		case c.Op == boogie.Implies && truth:
+			return join(refine(s, c.Left, false), refine(s, c.Right, true))
+		case c.Op == boogie.Implies:
+			return refine(refine(s, c.Left, true), c.Right, false)
+		}
+		if !isInt(c.Left.Type()) {
+			return s
+		}
+		op, ok := c.Op, true
+		if !truth {
+			op, ok = negate(op)
+		}
+		if !ok {
+			return s
+		}
+		s = constrain(s, c.Left, op, eval(s, c.Right))
+		if s == nil {
+			return nil
+		}
+		if op, ok := mirror(op); ok {
+			s = constrain(s, c.Right, op, eval(s, c.Left))
+		}
+		return s
+
+	default:
+		return s
+	}
+}
+
+// constrain narrows the variable e (if it is one) to the values that
+// satisfy "e op other".
+func constrain(s State, e boogie.Expr, op boogie.BinOpKind, other Interval) State {
+	x, ok := e.(*boogie.VarExpr)
+	if !ok {
+		return s
+	}
+	cur, ok := s[x.V.Name]
+	if !ok {
+		return s
+	}
+
+	var allowed Interval
+	switch op {
+	case boogie.Lt:
+		allowed = Interval{Hi: addBound(other.Hi, minusOne)}
+	case boogie.Lte:
+		allowed = Interval{Hi: other.Hi}
+	case boogie.Gt:
+		allowed = Interval{Lo: addBound(other.Lo, one)}
+	case boogie.Gte:
+		allowed = Interval{Lo: other.Lo}
+	case boogie.Eq:
+		allowed = other
+	default:
+		return s
+	}
+
+	m, ok := cur.Meet(allowed)
+	if !ok {
+		return nil
+	}
+	s = s.clone()
+	s[x.V.Name] = m
+	return s
+}
+
+// negate returns the comparison that holds exactly when op does not.
+func negate(op boogie.BinOpKind) (boogie.BinOpKind, bool) {
+	switch op {
+	case boogie.Lt:
+		return boogie.Gte, true
+	case boogie.Lte:
+		return boogie.Gt, true
+	case boogie.Gt:
+		return boogie.Lte, true
+	case boogie.Gte:
+		return boogie.Lt, true
+	case boogie.Eq:
+		return boogie.Neq, true
+	case boogie.Neq:
+		return boogie.Eq, true
+	}
+	return 0, false
+}
+
+// mirror returns the comparison with its operands swapped.
+func mirror(op boogie.BinOpKind) (boogie.BinOpKind, bool) {
+	switch op {
+	case boogie.Lt:
+		return boogie.Gt, true
+	case boogie.Lte:
+		return boogie.Gte, true
+	case boogie.Gt:
+		return boogie.Lt, true
+	case boogie.Gte:
+		return boogie.Lte, true
+	case boogie.Eq, boogie.Neq:
+		return op, true
+	}
+	return 0, false
+}
+
+// ========================
+// Helpers
+// ========================
+
+var (
+	one      = big.NewInt(1)
+	minusOne = big.NewInt(-1)
+)
+
+func zero() Interval { return Const(new(big.Int)) }
+
+func isInt(t boogie.Type) bool {
+	_, ok := t.(boogie.IntType)
+	return ok
+}
+
+func boundEq(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Cmp(b) == 0
+}
+
+func at(pos boogie.Pos) string {
+	if !pos.IsValid() {
+		return ""
+	}
+	return " at " + pos.String()
+}
@@ -0,0 +1,154 @@
+package ranges
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+var (
+	intX = boogie.Var{Name: "x", Ty: boogie.IntType{}}
+	intY = boogie.Var{Name: "y", Ty: boogie.IntType{}}
+)
+
+func v(x boogie.Var) *boogie.VarExpr { return &boogie.VarExpr{V: x} }
+
+func lit(n int) *boogie.IntLit { return &boogie.IntLit{Value: n} }
+
+func bin(op boogie.BinOpKind, l, r boogie.Expr) *boogie.BinOp {
+	return &boogie.BinOp{Op: op, Left: l, Right: r}
+}
+
+func assign(x boogie.Var, e boogie.Expr) *boogie.Assign {
+	return &boogie.Assign{Lhs: v(x), Rhs: e}
+}
+
+func TestCountingLoopFits(t *testing.T) {
+	// x := 0; while (x < 10) { x := x + 1; } y := x * x;
+	p := &boogie.Procedure{
+		Name:   "p",
+		Locals: []boogie.Var{intX, intY},
+		Body: []boogie.Stmt{
+			assign(intX, lit(0)),
+			&boogie.While{
+				Cond: bin(boogie.Lt, v(intX), lit(10)),
+				Body: []boogie.Stmt{assign(intX, bin(boogie.Add, v(intX), lit(1)))},
+			},
+			assign(intY, bin(boogie.Mul, v(intX), v(intX))),
+		},
+	}
+
+	r := Analyze(p)
+	if got := r.Range("x").String(); got != "[0, 10]" {
+		t.Fatalf("x: got %s, want [0, 10]", got)
+	}
+	if got := r.Range("y").String(); got != "[0, 100]" {
+		t.Fatalf("y: got %s, want [0, 100] (loop exit should narrow x to 10)", got)
+	}
+	if !r.Fits("x") || !r.Fits("y") {
+		t.Fatalf("expected both to fit: %q %q", r.Reason("x"), r.Reason("y"))
+	}
+}
+
+func TestUnboundedGrowthNeedsBig(t *testing.T) {
+	// x := 1; while (y < 10) { x := x * 2; }
+	p := &boogie.Procedure{
+		Name:   "p",
+		Params: []boogie.Var{intY},
+		Locals: []boogie.Var{intX},
+		Body: []boogie.Stmt{
+			assign(intX, lit(1)),
+			&boogie.While{
+				Cond: bin(boogie.Lt, v(intY), lit(10)),
+				Body: []boogie.Stmt{assign(intX, bin(boogie.Mul, v(intX), lit(2)))},
+			},
+		},
+	}
+
+	r := Analyze(p)
+	if r.Fits("x") || !strings.Contains(r.Reason("x"), "x := x * 2 may leave the int64 range") {
+		t.Fatalf("x: unexpected reason %q", r.Reason("x"))
+	}
+	if r.Fits("y") || !strings.Contains(r.Reason("y"), "parameter") {
+		t.Fatalf("y: unexpected reason %q", r.Reason("y"))
+	}
+}
+
+func TestGuardsRefine(t *testing.T) {
+	// if (x > 5 && x <= 7) { y := x; } else { y := 0; }
+	xp := boogie.Var{Name: "x", Ty: boogie.IntType{}}
+	p := &boogie.Procedure{
+		Name:   "p",
+		Params: []boogie.Var{xp},
+		Locals: []boogie.Var{intY},
+		Body: []boogie.Stmt{
+			&boogie.If{
+				Cond: bin(boogie.And, bin(boogie.Gt, v(xp), lit(5)), bin(boogie.Lte, v(xp), lit(7))),
+				Then: []boogie.Stmt{assign(intY, v(xp))},
+				Else: []boogie.Stmt{assign(intY, lit(0))},
+			},
+		},
+	}
+
+	if got := Analyze(p).Range("y").String(); got != "[0, 7]" {
+		t.Fatalf("y: got %s, want [0, 7]", got)
+	}
+}
+
+func TestIntervalMul(t *testing.T) {
+	i := func(lo, hi int64) Interval { return Interval{Lo: big.NewInt(lo), Hi: big.NewInt(hi)} }
+
+	tests := []struct {
+		a, b Interval
+		want string
+	}{
+		{i(-2, 3), i(4, 5), "[-10, 15]"},
+		{i(-2, -1), i(-3, 4), "[-8, 6]"},
+		{i(0, 0), Top(), "[0, 0]"},
+		{Interval{Lo: big.NewInt(1)}, i(-1, 1), "[-inf, +inf]"},
+		{Interval{Lo: big.NewInt(1)}, i(2, 3), "[2, +inf]"},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Mul(tt.b).String(); got != tt.want {
+			t.Errorf("%s * %s = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestIntervalDivMod(t *testing.T) {
+	i := func(lo, hi int64) Interval { return Interval{Lo: big.NewInt(lo), Hi: big.NewInt(hi)} }
+
+	div := []struct {
+		a, b Interval
+		want string
+	}{
+		{i(-7, 7), i(2, 2), "[-4, 3]"},
+		{i(-7, 7), Interval{Lo: big.NewInt(2)}, "[-4, 3]"},
+		{i(0, 9), i(-3, 3), "[-10, 10]"},
+		{Interval{Lo: big.NewInt(0)}, i(1, 4), "[0, +inf]"},
+		{Top(), i(-1, -1), "[-inf, +inf]"},
+	}
+	for _, tt := range div {
+		if got := tt.a.Div(tt.b).String(); got != tt.want {
+			t.Errorf("%s div %s = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	mod := []struct {
+		a, b Interval
+		want string
+	}{
+		{Top(), i(-5, 3), "[0, 4]"},
+		{i(0, 2), i(10, 10), "[0, 2]"},
+		{i(-3, 2), i(10, 10), "[0, 9]"},
+		{i(5, 6), Top(), "[0, 6]"},
+		{Top(), Top(), "[0, +inf]"},
+	}
+	for _, tt := range mod {
+		if got := tt.a.Mod(tt.b).String(); got != tt.want {
+			t.Errorf("%s mod %s = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package boogie
+
+import "slices"
+
+// Type parameters are instantiated by matching: a pattern type that
+// mentions type variables is unified with a concrete type, binding each
+// variable to the type it stands for there.
+
+// Unify reports whether t is an instance of pattern, in which only the
+// type variables named in vars may be bound. Bindings are recorded in
+// subst; a variable already bound must match its binding.
+func Unify(pattern, t Type, vars []string, subst map[string]Type) bool {
+	switch p := pattern.(type) {
+	case TypeVar:
+		if !slices.Contains(vars, p.Name) {
+			break
+		}
+		if bound, ok := subst[p.Name]; ok {
+			return bound.String() == t.String()
+		}
+		subst[p.Name] = t
+		return true
+
+	case CtorType:
+		c, ok := t.(CtorType)
+		if !ok || c.Name != p.Name || len(c.Args) != len(p.Args) {
+			return false
+		}
+		for i := range p.Args {
+			if !Unify(p.Args[i], c.Args[i], vars, subst) {
+				return false
+			}
+		}
+		return true
+
+	case MapType:
+		m, ok := t.(MapType)
+		if !ok || len(m.Args) != len(p.Args) || !slices.Equal(m.TypeParams, p.TypeParams) {
+			return false
+		}
+		inner := shadow(vars, p.TypeParams)
+		for i := range p.Args {
+			if !Unify(p.Args[i], m.Args[i], inner, subst) {
+				return false
+			}
+		}
+		return Unify(p.Result, m.Result, inner, subst)
+	}
+	return pattern.String() == t.String()
+}
+
+// Subst replaces the type variables bound in subst throughout t.
+func Subst(t Type, subst map[string]Type) Type {
+	switch t := t.(type) {
+	case TypeVar:
+		if s, ok := subst[t.Name]; ok {
+			return s
+		}
+	case CtorType:
+		args := make([]Type, len(t.Args))
+		for i, a := range t.Args {
+			args[i] = Subst(a, subst)
+		}
+		return CtorType{Name: t.Name, Args: args}
+	case MapType:
+		inner := subst
+		if len(t.TypeParams) > 0 {
+			inner = make(map[string]Type, len(subst))
+			for k, v := range subst {
+				if !slices.Contains(t.TypeParams, k) {
+					inner[k] = v
+				}
+			}
+		}
+		args := make([]Type, len(t.Args))
+		for i, a := range t.Args {
+			args[i] = Subst(a, inner)
+		}
+		return MapType{TypeParams: t.TypeParams, Args: args, Result: Subst(t.Result, inner)}
+	}
+	return t
+}
+
+// Mentions reports whether the type variable name occurs free in t.
+func Mentions(t Type, name string) bool {
+	switch t := t.(type) {
+	case TypeVar:
+		return t.Name == name
+	case CtorType:
+		for _, a := range t.Args {
+			if Mentions(a, name) {
+				return true
+			}
+		}
+	case MapType:
+		if slices.Contains(t.TypeParams, name) {
+			return false
+		}
+		for _, a := range t.Args {
+			if Mentions(a, name) {
+				return true
+			}
+		}
+		return Mentions(t.Result, name)
+	}
+	return false
+}
+
+// Select returns the type of a select from a map of type t with
+// arguments of the given types, instantiating t's type parameters.
+// It reports false if the arguments do not fit.
+func (t MapType) Select(args []Type) (Type, bool) {
+	if len(args) != len(t.Args) {
+		return nil, false
+	}
+	subst := make(map[string]Type)
+	for i, a := range args {
+		if !Unify(t.Args[i], a, t.TypeParams, subst) {
+			return nil, false
+		}
+	}
+	return Subst(t.Result, subst), true
+}
+
+// Instantiate matches the parameters of f against arguments of the
+// given types, binding f's type parameters. It reports false if the
+// arguments do not fit.
+func (f *Function) Instantiate(args []Type) (map[string]Type, bool) {
+	if len(args) != len(f.Params) {
+		return nil, false
+	}
+	subst := make(map[string]Type)
+	for i, a := range args {
+		if !Unify(f.Params[i].Ty, a, f.TypeParams, subst) {
+			return nil, false
+		}
+	}
+	return subst, true
+}
+
+func exprTypes(es []Expr) []Type {
+	ts := make([]Type, len(es))
+	for i, e := range es {
+		ts[i] = e.Type()
+	}
+	return ts
+}
+
+// shadow removes the names bound by an inner binder from vars.
+func shadow(vars, bound []string) []string {
+	var out []string
+	for _, v := range vars {
+		if !slices.Contains(bound, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package boogie
+
+// WalkStmts calls stmt on every statement in stmts, including nested
+// ones, and expr on every expression they contain, subexpressions
+// included. Parents come before their children. Either callback may
+// be nil.
+func WalkStmts(stmts []Stmt, stmt func(Stmt), expr func(Expr)) {
+	for _, s := range stmts {
+		walkStmt(s, stmt, expr)
+	}
+}
+
+func walkStmt(s Stmt, stmt func(Stmt), expr func(Expr)) {
+	if stmt != nil {
+		stmt(s)
+	}
+	walk := func(es ...Expr) {
+		for _, e := range es {
+			if e != nil && expr != nil {
+				WalkExpr(e, expr)
+			}
+		}
+	}
+
+	switch st := s.(type) {
+	case *Assign:
+		walk(st.Lhs, st.Rhs)
+	case *Assert:
+		walk(st.Cond)
+	case *Assume:
+		walk(st.Cond)
+	case *Check:
+		walk(st.Cond)
+	case *If:
+		walk(st.Cond)
+		WalkStmts(st.Then, stmt, expr)
+		WalkStmts(st.Else, stmt, expr)
+	case *Choice:
+		walk(st.Guards...)
+		for _, br := range st.Branches {
+			WalkStmts(br, stmt, expr)
+		}
+	case *While:
+		walk(st.Cond)
+		WalkStmts(st.Body, stmt, expr)
+	case *Call:
+		walk(st.Args...)
+	case *Return:
+		walk(st.Values...)
+	case *HeapWrite:
+		walk(st.Obj, st.Value)
+	case *HeapRead:
+		walk(st)
+	}
+}
+
+// WalkExpr calls fn on e and each of its subexpressions, parents first.
+func WalkExpr(e Expr, fn func(Expr)) {
+	fn(e)
+	var subs []Expr
+	switch ex := e.(type) {
+	case *Convert:
+		subs = []Expr{ex.X}
+	case *BvExtract:
+		subs = []Expr{ex.X}
+	case *FuncApp:
+		subs = ex.Args
+	case *BinOp:
+		subs = []Expr{ex.Left, ex.Right}
+	case *UnOp:
+		subs = []Expr{ex.X}
+	case *IfThenElse:
+		subs = []Expr{ex.Cond, ex.Then, ex.Else}
+	case *MapSelect:
+		subs = append([]Expr{ex.Map}, ex.Args...)
+	case *MapStore:
+		subs = append(append([]Expr{ex.Map}, ex.Args...), ex.Value)
+	case *HeapRead:
+		subs = []Expr{ex.Obj}
+	}
+	for _, s := range subs {
+		WalkExpr(s, fn)
+	}
+}
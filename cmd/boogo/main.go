@@ -1,6 +1,8 @@
 package boogo
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/ezrantn/boogo/boogie"
@@ -9,45 +11,121 @@ import (
 	"github.com/ezrantn/boogo/ebs"
 )
 
+// Options configures a Run.
+type Options struct {
+	// Filename is recorded in source positions reported by the
+	// generated code (e.g. failed assertions). Optional.
+	Filename string
+
+	// Erase decides which verification constructs become runtime
+	// checks. The zero value erases assertions and guards assumptions.
+	Erase ebs.Policy
+
+	// Codegen selects how Boogie semantics map onto Go. The zero
+	// value is exact (unbounded integers).
+	Codegen codegen.Options
+
+	// Runtime selects whether the generated program imports the
+	// runtime package (the zero value) or carries its own copy.
+	Runtime Runtime
+
+	// Externs binds bodiless functions and procedures, by name, to Go
+	// functions, as an {:extern "import/path.Func"} attribute would,
+	// and overriding one. Optional.
+	Externs map[string]string
+}
+
 func Run(src []byte) (string, error) {
-	prog, err := frontend.Parse(src)
+	return RunWithOptions(src, Options{})
+}
+
+// RunWithOptions is Run with explicit options.
+func RunWithOptions(src []byte, opts Options) (string, error) {
+	prog, err := frontend.ParseFile(opts.Filename, src)
 	if err != nil {
 		return "", err
 	}
 
+	if err := bindExterns(prog, opts.Externs); err != nil {
+		return "", err
+	}
+
 	if err := ebs.Check(prog); err != nil {
 		return "", err
 	}
 
-	ep := ebs.Erase(prog)
-	return EmitProgram(ep), nil
+	ep := ebs.EraseWith(prog, opts.Erase)
+	if err := codegen.CheckIntLits(ep, opts.Codegen); err != nil {
+		return "", err
+	}
+	return EmitProgramWithRuntime(ep, opts.Codegen, opts.Runtime), nil
 }
 
-// EmitProgram emits a complete Go source file from a Boogie program.
+// EmitProgram emits a complete Go source file from a Boogie program,
+// using the default code generation options.
 func EmitProgram(p *boogie.Program) string {
-	var b strings.Builder
+	return EmitProgramWithOptions(p, codegen.Options{})
+}
 
-	// Package header
-	b.WriteString("package main\n\n")
+// EmitProgramWithOptions emits a complete Go source file from a Boogie
+// program, importing the runtime package.
+func EmitProgramWithOptions(p *boogie.Program, opts codegen.Options) string {
+	return EmitProgramWithRuntime(p, opts, RuntimeImport)
+}
 
-	// Imports (minimal, explicit)
-	b.WriteString("import (\n")
-	b.WriteString("\t\"fmt\"\n")
-	b.WriteString(")\n\n")
+// EmitProgramWithRuntime emits a complete Go source file from a Boogie
+// program, getting the runtime as rt says.
+func EmitProgramWithRuntime(p *boogie.Program, opts codegen.Options, rt Runtime) string {
+	var code strings.Builder
+
+	// Heap, typed by the program's fields
+	code.WriteString(codegen.EmitHeap(p, opts))
+
+	// Declared types
+	code.WriteString(codegen.EmitTypes(p))
 
-	// Runtime heap
-	b.WriteString(emitHeapRuntime())
+	// Functions with bodies or bound to Go
+	for _, f := range p.Funcs {
+		code.WriteString(codegen.EmitFunc(f, opts))
+	}
 
 	// Procedures
 	for _, proc := range p.Procs {
-		b.WriteString(codegen.EmitProc(proc))
+		code.WriteString(codegen.EmitProcWithOptions(proc, opts))
 	}
 
 	// Optional: bootstrap main()
 	if hasMain(p) {
-		b.WriteString(emitMainWrapper())
+		code.WriteString(emitMainWrapper(opts))
 	}
 
+	var b strings.Builder
+
+	// Package header
+	b.WriteString("package main\n\n")
+
+	// Imports: the runtime, or what its inlined source needs, then the
+	// packages of {:extern} functions
+	imports := runtimeImports(code.String(), rt)
+	if ext := codegen.ExternImports(p); len(ext) > 0 {
+		imports = append(append(imports, ""), ext...)
+	}
+	b.WriteString("import (\n")
+	for _, imp := range imports {
+		if imp == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString("\t" + imp + "\n")
+	}
+	b.WriteString(")\n\n")
+
+	if rt == RuntimeInline {
+		decls, _ := inlineRuntime()
+		b.WriteString(decls)
+	}
+	b.WriteString(code.String())
+
 	return b.String()
 }
 
@@ -65,57 +143,43 @@ func hasMain(p *boogie.Program) bool {
 	return false
 }
 
-// emitMainWrapper emits a Go main() that calls Boogie main().
-func emitMainWrapper() string {
-	var b strings.Builder
-
-	b.WriteString("func main() {\n")
-	b.WriteString("\tmain()\n")
-	b.WriteString("}\n")
-
-	return b.String()
+// bindExterns adds an {:extern} attribute to each function or
+// procedure externs binds, where it takes precedence over one in the
+// source.
+func bindExterns(p *boogie.Program, externs map[string]string) error {
+	var names []string
+	for name := range externs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		attr := boogie.Attr{Name: "extern", Args: []string{externs[name]}}
+		if i := slices.IndexFunc(p.Funcs, func(f *boogie.Function) bool { return f.Name == name }); i >= 0 {
+			p.Funcs[i].Attrs = append(p.Funcs[i].Attrs, attr)
+			continue
+		}
+		if i := slices.IndexFunc(p.Procs, func(proc *boogie.Procedure) bool { return proc.Name == name }); i >= 0 {
+			p.Procs[i].Attrs = append(p.Procs[i].Attrs, attr)
+			continue
+		}
+		return fmt.Errorf("extern binding for %s: no such function or procedure", name)
+	}
+	return nil
 }
 
-// emitHeapRuntime embeds the heap runtime.
-// This assumes heap.go is part of the same package;
-// in a real system this could be factored out.
-func emitHeapRuntime() string {
+// emitMainWrapper emits a Go main() that runs Boogie main() under
+// RunMain, which turns its outcome into an exit status. Outside
+// codegen.StateGlobal, Boogie main() runs in mainContext.
+func emitMainWrapper(opts codegen.Options) string {
 	var b strings.Builder
 
-	b.WriteString("// ========================\n")
-	b.WriteString("// Runtime Heap\n")
-	b.WriteString("// ========================\n\n")
-
-	b.WriteString("var heap = make(map[int]map[string]interface{})\n\n")
-
-	b.WriteString("func heapRead(obj interface{}, field string) interface{} {\n")
-	b.WriteString("\tid, ok := obj.(int)\n")
-	b.WriteString("\tif !ok {\n")
-	b.WriteString("\t\tpanic(\"heapRead: object is not an int reference\")\n")
-	b.WriteString("\t}\n\n")
-	b.WriteString("\tfields, ok := heap[id]\n")
-	b.WriteString("\tif !ok {\n")
-	b.WriteString("\t\tpanic(\"heapRead: unknown object\")\n")
-	b.WriteString("\t}\n\n")
-	b.WriteString("\tval, ok := fields[field]\n")
-	b.WriteString("\tif !ok {\n")
-	b.WriteString("\t\tpanic(\"heapRead: unknown field\")\n")
-	b.WriteString("\t}\n\n")
-	b.WriteString("\treturn val\n")
-	b.WriteString("}\n\n")
-
-	b.WriteString("func heapWrite(obj interface{}, field string, value interface{}) {\n")
-	b.WriteString("\tid, ok := obj.(int)\n")
-	b.WriteString("\tif !ok {\n")
-	b.WriteString("\t\tpanic(\"heapWrite: object is not an int reference\")\n")
-	b.WriteString("\t}\n\n")
-	b.WriteString("\tfields, ok := heap[id]\n")
-	b.WriteString("\tif !ok {\n")
-	b.WriteString("\t\tfields = make(map[string]interface{})\n")
-	b.WriteString("\t\theap[id] = fields\n")
-	b.WriteString("\t}\n\n")
-	b.WriteString("\tfields[field] = value\n")
-	b.WriteString("}\n\n")
+	b.WriteString("func main() {\n")
+	if opts.State == codegen.StateGlobal {
+		b.WriteString("\tRunMain(" + codegen.GoName("main") + ")\n")
+	} else {
+		b.WriteString("\tRunMain(func() { " + codegen.GoName("main") + "(mainContext) })\n")
+	}
+	b.WriteString("}\n")
 
 	return b.String()
 }
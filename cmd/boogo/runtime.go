@@ -0,0 +1,111 @@
+package boogo
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/ezrantn/boogo/runtime"
+)
+
+// Runtime selects how a generated program gets the runtime library,
+// the package github.com/ezrantn/boogo/runtime.
+type Runtime int
+
+const (
+	// RuntimeImport imports the runtime package, so the program builds
+	// in a module that requires boogo.
+	RuntimeImport Runtime = iota
+
+	// RuntimeInline copies the runtime's source into the program,
+	// which then builds on its own, with only the standard library.
+	RuntimeInline
+)
+
+const runtimePath = "github.com/ezrantn/boogo/runtime"
+
+// runtimeImports returns the imports of a program whose own code,
+// everything but the runtime, is code. An empty import separates the
+// standard library from the runtime.
+func runtimeImports(code string, rt Runtime) []string {
+	if rt == RuntimeInline {
+		_, imports := inlineRuntime()
+		return imports
+	}
+
+	imports := []string{`. "` + runtimePath + `"`}
+	if usesBig(code) {
+		imports = append([]string{`"math/big"`, ""}, imports...)
+	}
+	return imports
+}
+
+// usesBig reports whether code refers to math/big, as generated code
+// does for unbounded ints and reals.
+func usesBig(code string) bool {
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package main\n"+code, 0)
+	if err != nil {
+		return true // let the Go compiler report it
+	}
+
+	found := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == "big" {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// inlineRuntime returns the declarations of the runtime package, file
+// by file, and the imports they need.
+func inlineRuntime() (decls string, imports []string) {
+	names, err := fs.Glob(runtime.Source, "*.go")
+	if err != nil {
+		panic(err)
+	}
+
+	var b strings.Builder
+	for _, name := range names {
+		if name == "source.go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := runtime.Source.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, name, src, parser.ImportsOnly)
+		if err != nil {
+			panic("runtime/" + name + ": " + err.Error())
+		}
+		for _, imp := range f.Imports {
+			if !slices.Contains(imports, imp.Path.Value) {
+				imports = append(imports, imp.Path.Value)
+			}
+		}
+
+		// Everything after the package clause and imports.
+		end := f.Name.End()
+		if len(f.Decls) > 0 {
+			end = f.Decls[len(f.Decls)-1].End()
+		}
+		body := strings.TrimLeft(string(src[fset.Position(end).Offset:]), "\n")
+
+		b.WriteString("// ========================\n")
+		b.WriteString("// Runtime: " + name + "\n")
+		b.WriteString("// ========================\n\n")
+		b.WriteString(body)
+		b.WriteString("\n")
+	}
+
+	slices.Sort(imports)
+	return b.String(), imports
+}
@@ -0,0 +1,230 @@
+package codegen
+
+import (
+	"strconv"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// Bitvectors of up to 64 bits are Go unsigned integers of the next
+// size up (bv32 is uint32, bv5 is uint8); wider ones are *big.Int.
+// Results are masked back to their width wherever Go's own wraparound
+// does not already give the Boogie result.
+
+func bvGoType(w int) string {
+	switch {
+	case w <= 8:
+		return "uint8"
+	case w <= 16:
+		return "uint16"
+	case w <= 32:
+		return "uint32"
+	case w <= 64:
+		return "uint64"
+	}
+	return "*big.Int"
+}
+
+func bvBig(w int) bool {
+	return w > 64
+}
+
+func bvWidthOf(e boogie.Expr) int {
+	return e.Type().(boogie.BvType).Width
+}
+
+// bvWrap masks x, of the Go type for width w, back to w bits.
+func bvWrap(x string, w int) string {
+	switch w {
+	case 8, 16, 32, 64:
+		return x
+	}
+	return "(" + x + " & " + bvMask(w) + ")"
+}
+
+func bvMask(w int) string {
+	return "0x" + strconv.FormatUint(1<<w-1, 16)
+}
+
+func (g *emitter) emitBvLit(l *boogie.BvLit) string {
+	if bvBig(l.Width) {
+		if l.Value.IsInt64() {
+			return "big.NewInt(" + l.Value.String() + ")"
+		}
+		return "BigLit(" + strconv.Quote(l.Value.String()) + ")"
+	}
+	return bvGoType(l.Width) + "(" + l.Value.String() + ")"
+}
+
+// emitBvToBig emits the bitvector e as a *big.Int.
+func (g *emitter) emitBvToBig(e boogie.Expr) string {
+	x := g.emitExpr(e)
+	if bvBig(bvWidthOf(e)) {
+		return x
+	}
+	return "new(big.Int).SetUint64(uint64(" + x + "))"
+}
+
+// emitBvExtract emits x[hi:lo] as a shift and a mask.
+func (g *emitter) emitBvExtract(e *boogie.BvExtract) string {
+	x := g.emitExpr(e.X)
+	w := e.Hi - e.Lo
+	hi, lo := strconv.Itoa(e.Hi), strconv.Itoa(e.Lo)
+
+	if bvBig(bvWidthOf(e.X)) {
+		r := "BvBigExtract(" + x + ", " + hi + ", " + lo + ")"
+		if bvBig(w) {
+			return r
+		}
+		return bvGoType(w) + "(" + r + ".Uint64())"
+	}
+
+	bits := "uint64(" + x + ")"
+	if e.Lo > 0 {
+		bits = "(" + bits + " >> " + lo + ")"
+	}
+	return bvGoType(w) + "(" + bvWrap(bits, w) + ")"
+}
+
+// emitBvConcat emits l ++ r, with l in the high bits.
+func (g *emitter) emitBvConcat(b *boogie.BinOp) string {
+	rw := bvWidthOf(b.Right)
+	w := bvWidthOf(b.Left) + rw
+
+	if bvBig(w) {
+		return "BvBigConcat(" + g.emitBvToBig(b.Left) + ", " + g.emitBvToBig(b.Right) + ", " + strconv.Itoa(rw) + ")"
+	}
+	return bvGoType(w) + "(uint64(" + g.emitExpr(b.Left) + ")<<" + strconv.Itoa(rw) + " | uint64(" + g.emitExpr(b.Right) + "))"
+}
+
+// emitBvFuncApp emits an application of a {:bvbuiltin} function; the
+// checker rejects every other bodiless kind.
+func (g *emitter) emitBvFuncApp(f *boogie.FuncApp) string {
+	attr, _ := boogie.FindAttr(f.Func.Attrs, "bvbuiltin")
+	b, err := boogie.ParseBvBuiltin(attr.Args[0])
+	if err != nil {
+		panic("unsupported function in codegen: " + f.Func.Name)
+	}
+	return g.emitBvBuiltin(b, f.Args)
+}
+
+// bvOps are the builtins Go has an operator for, on unsigned integers.
+// wrap says the result may need masking back to the operand width.
+var bvOps = map[string]struct {
+	op   string
+	wrap bool
+}{
+	"bvadd":  {"+", true},
+	"bvsub":  {"-", true},
+	"bvmul":  {"*", true},
+	"bvshl":  {"<<", true},
+	"bvlshr": {">>", false},
+	"bvand":  {"&", false},
+	"bvor":   {"|", false},
+	"bvxor":  {"^", false},
+	"bvult":  {"<", false},
+	"bvule":  {"<=", false},
+	"bvugt":  {">", false},
+	"bvuge":  {">=", false},
+}
+
+// bvSignedCmps are the Go comparisons behind the signed builtins.
+var bvSignedCmps = map[string]string{
+	"bvslt": "<",
+	"bvsle": "<=",
+	"bvsgt": ">",
+	"bvsge": ">=",
+}
+
+func (g *emitter) emitBvBuiltin(b boogie.BvBuiltin, args []boogie.Expr) string {
+	w := bvWidthOf(args[0])
+	ws := strconv.Itoa(w)
+
+	if bvBig(w) || b.IsExtend() && bvBig(w+b.N) {
+		return g.emitBigBvBuiltin(b, args, w)
+	}
+
+	var xs []string
+	for _, a := range args {
+		xs = append(xs, g.emitExpr(a))
+	}
+
+	// An operation on constants only would be folded by the Go
+	// compiler, which rejects the wraparound Boogie asks for.
+	if (bvOps[b.Op].wrap || b.Op == "bvneg") && allConstBv(args) {
+		xs[0] = bvGoType(w) + "(BvDyn(uint64(" + xs[0] + ")))"
+	}
+
+	if op, ok := bvOps[b.Op]; ok {
+		x := "(" + xs[0] + " " + op.op + " " + xs[1] + ")"
+		if op.wrap {
+			return bvWrap(x, w)
+		}
+		return x
+	}
+
+	if cmp, ok := bvSignedCmps[b.Op]; ok {
+		return "(BvSigned(uint64(" + xs[0] + "), " + ws + ") " + cmp + " BvSigned(uint64(" + xs[1] + "), " + ws + "))"
+	}
+
+	switch b.Op {
+	case "bvneg":
+		return bvWrap("(-"+xs[0]+")", w)
+	case "bvnot":
+		return bvWrap("(^"+xs[0]+")", w)
+	case "zero_extend":
+		return bvGoType(w+b.N) + "(" + xs[0] + ")"
+	case "sign_extend":
+		return bvGoType(w+b.N) + "(BvSignExtend(uint64(" + xs[0] + "), " + ws + ", " + strconv.Itoa(w+b.N) + "))"
+	}
+
+	// Division, remainder and arithmetic shift: runtime helpers.
+	return bvGoType(w) + "(BvOp(" + strconv.Quote(b.Op) + ", " + ws + ", uint64(" + xs[0] + "), uint64(" + xs[1] + ")))"
+}
+
+// emitBigBvBuiltin emits a builtin on bitvectors wider than 64 bits.
+func (g *emitter) emitBigBvBuiltin(b boogie.BvBuiltin, args []boogie.Expr, w int) string {
+	var xs []string
+	for _, a := range args {
+		xs = append(xs, g.emitBvToBig(a))
+	}
+	ws := strconv.Itoa(w)
+
+	switch {
+	case b.IsCompare():
+		return "BvBigRel(" + strconv.Quote(b.Op) + ", " + ws + ", " + xs[0] + ", " + xs[1] + ")"
+	case b.Op == "zero_extend":
+		return xs[0]
+	case b.Op == "sign_extend":
+		return "BvBigSignExtend(" + xs[0] + ", " + ws + ", " + strconv.Itoa(w+b.N) + ")"
+	}
+
+	call := "BvBigOp(" + strconv.Quote(b.Op) + ", " + ws
+	for _, x := range xs {
+		call += ", " + x
+	}
+	return call + ")"
+}
+
+// allConstBv reports whether every bitvector in es is built from
+// literals alone, which Go would treat as a constant expression.
+func allConstBv(es []boogie.Expr) bool {
+	for _, e := range es {
+		if !constBv(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func constBv(e boogie.Expr) bool {
+	switch ex := e.(type) {
+	case *boogie.BvLit:
+		return true
+	case *boogie.BvExtract:
+		return constBv(ex.X)
+	case *boogie.BinOp:
+		return ex.Op == boogie.Concat && constBv(ex.Left) && constBv(ex.Right)
+	}
+	return false
+}
@@ -7,15 +7,20 @@ import (
 	"github.com/ezrantn/boogo/boogie"
 )
 
-// EmitExpr emits Go code for a Boogie expression (EBS v1 only).
+// EmitExpr emits Go code for a Boogie expression (EBS v1 only),
+// using the default Options.
 func EmitExpr(e boogie.Expr) string {
+	return (&emitter{}).emitExpr(e)
+}
+
+func (g *emitter) emitExpr(e boogie.Expr) string {
 	switch ex := e.(type) {
 
 	case *boogie.VarExpr:
-		return ex.V.Name
+		return GoName(ex.V.Name)
 
 	case *boogie.IntLit:
-		return strconv.Itoa(ex.Value)
+		return g.emitIntLit(ex)
 
 	case *boogie.BoolLit:
 		if ex.Value {
@@ -23,14 +28,41 @@ func EmitExpr(e boogie.Expr) string {
 		}
 		return "false"
 
+	case *boogie.RealLit:
+		return g.emitRealLit(ex)
+
+	case *boogie.Convert:
+		return g.emitConvert(ex)
+
+	case *boogie.BvLit:
+		return g.emitBvLit(ex)
+
+	case *boogie.BvExtract:
+		return g.emitBvExtract(ex)
+
+	case *boogie.FuncApp:
+		return g.emitFuncApp(ex)
+
 	case *boogie.BinOp:
-		return emitBinOp(ex)
+		return g.emitBinOp(ex)
 
 	case *boogie.UnOp:
-		return emitUnOp(ex)
+		return g.emitUnOp(ex)
+
+	case *boogie.IfThenElse:
+		return g.emitIfThenElse(ex)
+
+	case *boogie.MapSelect:
+		return g.emitMapSelect(ex)
+
+	case *boogie.MapStore:
+		return g.emitMapStore(ex)
+
+	case *boogie.NullLit:
+		return "nullRef"
 
 	case *boogie.HeapRead:
-		return emitHeapRead(ex)
+		return g.emitHeapRead(ex)
 
 	default:
 		panic(fmt.Sprintf("unsupported expression in codegen: %T", e))
@@ -41,9 +73,38 @@ func EmitExpr(e boogie.Expr) string {
 // Binary Operators
 // ========================
 
-func emitBinOp(b *boogie.BinOp) string {
-	l := EmitExpr(b.Left)
-	r := EmitExpr(b.Right)
+func (g *emitter) emitBinOp(b *boogie.BinOp) string {
+	if b.Op == boogie.Concat {
+		return g.emitBvConcat(b)
+	}
+	if bv, ok := b.Left.Type().(boogie.BvType); ok && bvBig(bv.Width) {
+		return emitBigBinOp(b.Op, g.emitExpr(b.Left), g.emitExpr(b.Right))
+	}
+	if isReal(b.Left) {
+		return g.emitRatBinOp(b)
+	}
+	if b.Op == boogie.IntDiv || b.Op == boogie.Mod {
+		return g.emitDivMod(b)
+	}
+	if isTypeVar(b.Left) {
+		return g.emitTypeVarEq(b)
+	}
+
+	if isInt(b.Left) {
+		switch g.opts.Ints {
+		case IntBig, IntRange:
+			if g.bigOperands(b) {
+				return emitBigBinOp(b.Op, g.emitAs(b.Left, true), g.emitAs(b.Right, true))
+			}
+		case IntChecked:
+			if fn, ok := checkedBinOps[b.Op]; ok {
+				return fn + "(" + g.emitExpr(b.Left) + ", " + g.emitExpr(b.Right) + ", " + posArgs(b.Pos, b) + ")"
+			}
+		}
+	}
+
+	l := g.emitExpr(b.Left)
+	r := g.emitExpr(b.Right)
 
 	switch b.Op {
 
@@ -59,6 +120,9 @@ func emitBinOp(b *boogie.BinOp) string {
 	case boogie.Eq:
 		return "(" + l + " == " + r + ")"
 
+	case boogie.Neq:
+		return "(" + l + " != " + r + ")"
+
 	case boogie.Lt:
 		return "(" + l + " < " + r + ")"
 
@@ -77,17 +141,237 @@ func emitBinOp(b *boogie.BinOp) string {
 	case boogie.Or:
 		return "(" + l + " || " + r + ")"
 
+	// An implication evaluates its antecedent first and the consequent
+	// only when the antecedent holds, so it can guard a partial
+	// operation: y != 0 ==> x div y > 0. a <== b is b ==> a, and is
+	// evaluated the same way, right to left.
+	case boogie.Implies:
+		return "(!" + l + " || " + r + ")"
+
+	case boogie.Explies:
+		return "(!" + r + " || " + l + ")"
+
+	case boogie.Iff:
+		return "(" + l + " == " + r + ")"
+
 	default:
 		panic("unsupported binary operator")
 	}
 }
 
+// bigBinOps names the runtime helpers for *big.Int arithmetic; they
+// always allocate, so a *big.Int is never mutated once created.
+var bigBinOps = map[boogie.BinOpKind]string{
+	boogie.Add: "BigAdd",
+	boogie.Sub: "BigSub",
+	boogie.Mul: "BigMul",
+}
+
+// bigCmpOps are the Go comparison operators applied to a.Cmp(b).
+var bigCmpOps = map[boogie.BinOpKind]string{
+	boogie.Eq:  "==",
+	boogie.Neq: "!=",
+	boogie.Lt:  "<",
+	boogie.Lte: "<=",
+	boogie.Gt:  ">",
+	boogie.Gte: ">=",
+}
+
+func emitBigBinOp(op boogie.BinOpKind, l, r string) string {
+	if fn, ok := bigBinOps[op]; ok {
+		return fn + "(" + l + ", " + r + ")"
+	}
+	if cmp, ok := bigCmpOps[op]; ok {
+		return "(" + l + ".Cmp(" + r + ") " + cmp + " 0)"
+	}
+	panic("unsupported binary operator on big integers")
+}
+
+// checkedBinOps names the runtime helpers for overflow-checked int64
+// arithmetic.
+var checkedBinOps = map[boogie.BinOpKind]string{
+	boogie.Add: "CheckedAdd",
+	boogie.Sub: "CheckedSub",
+	boogie.Mul: "CheckedMul",
+}
+
+// divModFuncs names the runtime helpers for div and mod per
+// representation. All of them are Euclidean, unlike Go's / and %, and
+// fault with the source position on a zero divisor.
+var divModFuncs = map[boogie.BinOpKind]struct{ big, checked, native string }{
+	boogie.IntDiv: {"BigDiv", "CheckedDiv", "IntDiv"},
+	boogie.Mod:    {"BigMod", "CheckedMod", "IntMod"},
+}
+
+func (g *emitter) emitDivMod(b *boogie.BinOp) string {
+	fns := divModFuncs[b.Op]
+	fn := fns.native
+	switch {
+	case g.bigExpr(b):
+		fn = fns.big
+	case g.opts.Ints == IntChecked:
+		fn = fns.checked
+	}
+	big := fn == fns.big
+	return fn + "(" + g.emitAs(b.Left, big) + ", " + g.emitAs(b.Right, big) + ", " + posArgs(b.Pos, b) + ")"
+}
+
+// posArgs emits the position and source text arguments a runtime
+// helper reports when e fails: "file:3:5", "x + 1".
+func posArgs(pos boogie.Pos, e boogie.Expr) string {
+	return strconv.Quote(pos.String()) + ", " + strconv.Quote(boogie.FormatExpr(e))
+}
+
+// ========================
+// Integers
+// ========================
+
+func isInt(e boogie.Expr) bool {
+	return isIntType(e.Type())
+}
+
+func isIntType(t boogie.Type) bool {
+	_, ok := t.(boogie.IntType)
+	return ok
+}
+
+// bigVar reports whether the variable v is a *big.Int.
+func (g *emitter) bigVar(v boogie.Var) bool {
+	if !isIntType(v.Ty) {
+		return false
+	}
+	switch g.opts.Ints {
+	case IntBig:
+		return true
+	case IntRange:
+		return g.ranges == nil || !g.ranges.Fits(v.Name)
+	}
+	return false
+}
+
+// bigExpr reports whether the int expression e evaluates to a *big.Int.
+// Under IntRange that is whenever e, or any intermediate result, is
+// not proven to fit in an int64.
+func (g *emitter) bigExpr(e boogie.Expr) bool {
+	switch g.opts.Ints {
+	case IntBig:
+		return true
+	case IntRange:
+		return !g.fitsInt64(e)
+	}
+	return false
+}
+
+func (g *emitter) fitsInt64(e boogie.Expr) bool {
+	if g.ranges == nil {
+		return false
+	}
+	switch ex := e.(type) {
+	case *boogie.IntLit:
+		return ex.Big == nil || ex.Big.IsInt64()
+	case *boogie.VarExpr:
+		return !g.bigVar(ex.V)
+	case *boogie.BinOp:
+		return g.fitsInt64(ex.Left) && g.fitsInt64(ex.Right) && g.ranges.Eval(ex).FitsInt64()
+	case *boogie.UnOp:
+		return g.fitsInt64(ex.X) && g.ranges.Eval(ex).FitsInt64()
+	case *boogie.IfThenElse:
+		return g.fitsInt64(ex.Then) && g.fitsInt64(ex.Else) && g.ranges.Eval(ex).FitsInt64()
+	}
+	return false
+}
+
+// bigOperands reports whether b is computed on *big.Int: arithmetic
+// whose result is big, or a comparison with a big operand.
+func (g *emitter) bigOperands(b *boogie.BinOp) bool {
+	if _, ok := bigBinOps[b.Op]; ok {
+		return g.bigExpr(b)
+	}
+	return g.bigExpr(b.Left) || g.bigExpr(b.Right)
+}
+
+// emitAs emits e, converting an int between int64 and *big.Int to get
+// the wanted representation. Narrowing is only asked for where range
+// analysis proved the value fits.
+func (g *emitter) emitAs(e boogie.Expr, big bool) string {
+	x := g.emitExpr(e)
+	if !isInt(e) || g.bigExpr(e) == big {
+		return x
+	}
+	if big {
+		return "big.NewInt(" + x + ")"
+	}
+	return x + ".Int64()"
+}
+
+// emitIntLit emits a literal as a Go int constant or, for big integers,
+// as big.NewInt(v) (BigLit("...") beyond int64).
+func (g *emitter) emitIntLit(l *boogie.IntLit) string {
+	if !g.bigExpr(l) {
+		// CheckIntLits has rejected any literal this cannot hold.
+		return l.BigValue().String()
+	}
+	if l.Big != nil && !l.Big.IsInt64() {
+		return "BigLit(" + strconv.Quote(l.Big.String()) + ")"
+	}
+	return "big.NewInt(" + l.BigValue().String() + ")"
+}
+
+// CheckIntLits reports the first int literal of p that is out of range
+// for opts: one that does not fit in an int64 under IntChecked, or in
+// a Go int under IntNative.
+func CheckIntLits(p *boogie.Program, opts Options) error {
+	var fits func(l *boogie.IntLit) bool
+	var kind string
+	switch opts.Ints {
+	case IntChecked:
+		fits = func(l *boogie.IntLit) bool { return l.BigValue().IsInt64() }
+		kind = "the int64 range of checked ints"
+	case IntNative:
+		// Big holds exactly the literals beyond int.
+		fits = func(l *boogie.IntLit) bool { return l.Big == nil }
+		kind = "the int range of native ints"
+	default:
+		return nil
+	}
+
+	var err error
+	check := func(e boogie.Expr) {
+		if l, ok := e.(*boogie.IntLit); ok && err == nil && !fits(l) {
+			err = fmt.Errorf("%v: integer literal %v is out of %s", l.Pos, l.BigValue(), kind)
+		}
+	}
+	for _, f := range p.Funcs {
+		if f.Body != nil {
+			boogie.WalkExpr(f.Body, check)
+		}
+	}
+	for _, proc := range p.Procs {
+		boogie.WalkStmts(proc.Body, nil, check)
+	}
+	return err
+}
+
 // ========================
 // Unary Operators
 // ========================
 
-func emitUnOp(u *boogie.UnOp) string {
-	x := EmitExpr(u.X)
+func (g *emitter) emitUnOp(u *boogie.UnOp) string {
+	if u.Op == boogie.Neg && isReal(u.X) {
+		return "RatNeg(" + g.emitExpr(u.X) + ")"
+	}
+	if u.Op == boogie.Neg && isInt(u.X) {
+		switch g.opts.Ints {
+		case IntBig, IntRange:
+			if g.bigExpr(u) {
+				return "BigNeg(" + g.emitAs(u.X, true) + ")"
+			}
+		case IntChecked:
+			return "CheckedNeg(" + g.emitExpr(u.X) + ", " + posArgs(u.Pos, u) + ")"
+		}
+	}
+
+	x := g.emitExpr(u.X)
 
 	switch u.Op {
 
@@ -103,13 +387,31 @@ func emitUnOp(u *boogie.UnOp) string {
 }
 
 // ========================
-// Heap Access
+// Conditional Expressions
 // ========================
 
-func emitHeapRead(h *boogie.HeapRead) string {
-	obj := EmitExpr(h.Obj)
-	field := strconv.Quote(h.Field)
+// emitIfThenElse emits if c then a else b as an immediately called
+// function literal, Go having no conditional expression. Unlike a
+// helper taking both branches as arguments, it evaluates the condition
+// first and then only the chosen branch, which matters when the other
+// one would fault (if y != 0 then x div y else 0).
+func (g *emitter) emitIfThenElse(e *boogie.IfThenElse) string {
+	ty := g.exprGoType(e)
+	big := isInt(e) && g.bigExpr(e)
+	return "func() " + ty + " { if " + g.emitExpr(e.Cond) + " { return " + g.emitAs(e.Then, big) +
+		" }; return " + g.emitAs(e.Else, big) + " }()"
+}
 
-	// heapRead(obj, "field")
-	return "heapRead(" + obj + ", " + field + ")"
+// exprGoType is the Go type e evaluates to.
+func (g *emitter) exprGoType(e boogie.Expr) string {
+	if !isInt(e) {
+		return g.goType(boogie.Var{Ty: e.Type()})
+	}
+	switch {
+	case g.bigExpr(e):
+		return "*big.Int"
+	case g.opts.Ints == IntNative:
+		return "int"
+	}
+	return "int64"
 }
@@ -0,0 +1,125 @@
+package codegen
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// Functions and procedures bound to Go with {:extern} become Go
+// functions calling the ones they are bound to, whose packages are
+// imported under aliases of their own (see ExternImports). The Go
+// signature a binding must have is derived from the Boogie one, as
+// goType maps it under the Options: under IntBig, for example, an int
+// parameter is a *big.Int. Each binding is assigned to a variable of
+// that exact type, so that the Go compiler rejects any other, naming
+// both.
+//
+// A bound procedure takes no Context: it is Go code of its own, not
+// the generated code that owns the state.
+
+// emitExternProc emits the Go function a bodiless procedure runs as.
+func (g *emitter) emitExternProc(p *boogie.Procedure) string {
+	ext, _ := boogie.FindProcExtern(p)
+	name := GoName(p.Name)
+
+	var args, rets []string
+	for _, v := range p.Params {
+		args = append(args, GoName(v.Name))
+	}
+	for _, v := range p.Rets {
+		rets = append(rets, g.goType(v))
+	}
+	call := externAlias(ext.Path) + "." + ext.Name + "(" + strings.Join(args, ", ") + ")"
+
+	var b strings.Builder
+	b.WriteString("// " + name + " is bound to " + ext.String() + ".\n")
+	b.WriteString("func " + name + "(" + g.emitCtxParams(p.Params) + ")")
+	if len(p.Rets) > 0 {
+		b.WriteString(" " + g.emitReturns(p.Rets) + " {\n")
+		b.WriteString("\treturn " + call + "\n")
+	} else {
+		b.WriteString(" {\n")
+		b.WriteString("\t" + call + "\n")
+	}
+	b.WriteString("}\n\n")
+	b.WriteString(g.emitExternCheck(ext, p.Params, rets))
+	return b.String()
+}
+
+// emitExternCheck emits the assignment of the Go function ext to a
+// variable of the type it must have, given the parameters and result
+// types it is called with.
+func (g *emitter) emitExternCheck(ext boogie.Extern, params []boogie.Var, rets []string) string {
+	var ps []string
+	for _, v := range params {
+		ps = append(ps, g.goType(v))
+	}
+	ty := "func(" + strings.Join(ps, ", ") + ")"
+	switch len(rets) {
+	case 0:
+	case 1:
+		ty += " " + rets[0]
+	default:
+		ty += " (" + strings.Join(rets, ", ") + ")"
+	}
+	return "var _ " + ty + " = " + externAlias(ext.Path) + "." + ext.Name + "\n\n"
+}
+
+// externParams names the parameters of an {:extern} function, which
+// may be unnamed: x0, x1, ... unless taken.
+func externParams(params []boogie.Var) []boogie.Var {
+	taken := map[string]bool{}
+	for _, v := range params {
+		taken[v.Name] = true
+	}
+	named := make([]boogie.Var, len(params))
+	for i, v := range params {
+		if v.Name == "" {
+			v.Name = "x" + strconv.Itoa(i)
+			for taken[v.Name] {
+				v.Name += "_"
+			}
+			taken[v.Name] = true
+		}
+		named[i] = v
+	}
+	return named
+}
+
+// externPrefix starts every externAlias.
+const externPrefix = "ext_"
+
+// externAlias is the name the package at path is imported under: ext_
+// and the path as GoName escapes it, as in ext_math_2f_bits_, which
+// stays clear of the names of generated code, of the imports the
+// runtime needs and of other paths.
+func externAlias(path string) string {
+	return externPrefix + GoName(path)
+}
+
+// ExternImports returns the import specs of the packages the {:extern}
+// functions and procedures of p are bound to, sorted by path.
+func ExternImports(p *boogie.Program) []string {
+	var paths []string
+	for _, f := range p.Funcs {
+		if e, ok := boogie.FindExtern(f); ok {
+			paths = append(paths, e.Path)
+		}
+	}
+	for _, proc := range p.Procs {
+		if e, ok := boogie.FindProcExtern(proc); ok && proc.Bodiless {
+			paths = append(paths, e.Path)
+		}
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	var imports []string
+	for _, path := range paths {
+		imports = append(imports, externAlias(path)+" "+strconv.Quote(path))
+	}
+	return imports
+}
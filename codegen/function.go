@@ -0,0 +1,81 @@
+package codegen
+
+import (
+	"strings"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// Functions with a body become Go functions of their parameters; the
+// checker made sure the body reads nothing else. Their ints are
+// represented as procedure parameters are (see bigParams), so that
+// applications need no analysis of the function. Type parameters become
+// Go type parameters, as for procedures (see types.go), instantiated
+// explicitly at each application. A {:memoize} function caches its
+// results in a runtime Memo of its own.
+//
+// An {:extern} function becomes a Go function calling the one it is
+// bound to (see extern.go).
+
+// EmitFunc emits Go code for a Boogie function, or nothing for a
+// {:bvbuiltin} one, whose applications are emitted inline.
+func EmitFunc(f *boogie.Function, opts Options) string {
+	ext, extern := boogie.FindExtern(f)
+	if f.Body == nil && !extern {
+		return ""
+	}
+	g := &emitter{opts: opts}
+	name := GoName(f.Name)
+	ret := g.goType(boogie.Var{Ty: f.Ret})
+	params := f.Params
+
+	var body string
+	if extern {
+		params = externParams(f.Params)
+		var args []string
+		for _, v := range params {
+			args = append(args, GoName(v.Name))
+		}
+		body = externAlias(ext.Path) + "." + ext.Name + "(" + strings.Join(args, ", ") + ")"
+	} else {
+		body = g.emitAs(f.Body, g.bigParams())
+	}
+
+	var b strings.Builder
+	_, memoize := boogie.FindAttr(f.Attrs, "memoize")
+	if memoize {
+		b.WriteString("var memo_" + name + " Memo[" + ret + "]\n\n")
+	}
+
+	if extern {
+		b.WriteString("// " + name + " is bound to " + ext.String() + ".\n")
+	}
+	b.WriteString("func " + name + emitTypeParams(f.TypeParams) + "(" + g.emitParams(params) + ") " + ret + " {\n")
+	if memoize {
+		args := []string{"func() " + ret + " {\n\t\treturn " + body + "\n\t}"}
+		for _, v := range params {
+			args = append(args, GoName(v.Name))
+		}
+		b.WriteString("\treturn memo_" + name + ".Do(" + strings.Join(args, ", ") + ")\n")
+	} else {
+		b.WriteString("\treturn " + body + "\n")
+	}
+	b.WriteString("}\n\n")
+	if extern {
+		b.WriteString(g.emitExternCheck(ext, params, []string{ret}))
+	}
+	return b.String()
+}
+
+// emitFuncApp emits an application of a function: a call of its Go
+// function, or the operation of a {:bvbuiltin} one.
+func (g *emitter) emitFuncApp(f *boogie.FuncApp) string {
+	if _, ok := boogie.FindAttr(f.Func.Attrs, "bvbuiltin"); ok {
+		return g.emitBvFuncApp(f)
+	}
+	var args []string
+	for _, a := range f.Args {
+		args = append(args, g.emitAs(a, g.bigParams()))
+	}
+	return GoName(f.Func.Name) + g.emitTypeArgs(f.TypeArgs()) + "(" + strings.Join(args, ", ") + ")"
+}
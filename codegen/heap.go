@@ -1,55 +1,401 @@
 package codegen
 
-import "fmt"
+import (
+	"strconv"
+	"strings"
 
-// We model the heap as:
-// map[int]map[string]interface{}
-//
-// Why?
-// - Object identity = int (Boogie-style references)
-// - Fields are named (string)
-// - Values may be int or bool
-// - Type safety is enforced by the EBS checker, not at runtime
+	"github.com/ezrantn/boogo/boogie"
+)
 
-// Heap represents a simple object heap:
+// The heap maps each object, a ref, to a heapObject: a Go struct with
+// one field per Boogie heap field, of the field's type (see ebs.Check
+// for how field types are settled). Each field has its own accessors,
+// heapRead_f and heapWrite_f, so reads need no type assertions.
+//
+// Boogie's heap is total: an object never written has every field at
+// its zero, and only comes into being on its first write. Field values
+// are stored like map values, in the representation of parameters.
 //
-//	object id -> field name -> value
-var heap = make(map[int]map[string]interface{})
+// References are ints. null is 0, and reading or writing a field of
+// null panics with the runtime's NullDereferenceError. New hands out
+// references counting up from 1, skipping any object already in the
+// heap, so a reference it returns was never returned before nor
+// written through.
+//
+// Every object carries whether it is allocated: New allocates it, and
+// a field alloc of type bool, as Boogie encodings declare, reads and
+// writes that bit. Under HeapChecked, accessing a field of an object
+// not allocated, or reading a field never written, panics with the
+// runtime's HeapError, and BOOGO_HEAPSTATS reports the heap at exit.
+
+// EmitHeap emits the heap of p and its accessors.
+func EmitHeap(p *boogie.Program, opts Options) string {
+	g := &emitter{opts: opts}
+	checked := opts.Heap == HeapChecked
+	nfields := strconv.Itoa(len(p.Fields))
+	var b strings.Builder
+
+	b.WriteString("// ========================\n")
+	b.WriteString("// Heap\n")
+	b.WriteString("// ========================\n\n")
+
+	var fields []*boogie.FieldDecl // stored in fields of their own
+	for _, f := range p.Fields {
+		if !isAllocField(f) {
+			fields = append(fields, f)
+		}
+	}
+
+	b.WriteString("// heapObject holds the fields of one object.\n")
+	b.WriteString("type heapObject struct {\n")
+	b.WriteString("\tallocated bool\n")
+	for _, f := range fields {
+		b.WriteString("\t" + GoName(f.Name) + " " + g.goType(valueVar(f.Ty)) + "\n")
+	}
+	if checked {
+		b.WriteString("\n\t// written[i] is whether field i was ever written.\n")
+		b.WriteString("\twritten [" + nfields + "]bool\n")
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("const nullRef = 0\n\n")
+
+	switch opts.State {
+	case StateGlobal:
+		b.WriteString("var heap = map[int]*heapObject{}\n\n")
+		b.WriteString("// heapNext is the next reference New may return.\n")
+		b.WriteString("var heapNext = 1\n\n")
+		if checked {
+			b.WriteString("// heapWrites counts the writes to each field.\n")
+			b.WriteString("var heapWrites [" + nfields + "]int\n\n")
+		}
+
+		b.WriteString("func heapReset() {\n")
+		b.WriteString("\theap = map[int]*heapObject{}\n")
+		b.WriteString("\theapNext = 1\n")
+		if checked {
+			b.WriteString("\theapWrites = [" + nfields + "]int{}\n")
+		}
+		b.WriteString("}\n\n")
+
+		// Explored paths each start from an empty heap.
+		b.WriteString("func init() {\n")
+		b.WriteString("\tOnReset(heapReset)\n")
+		b.WriteString("\tOnSnapshot(heapSnapshot)\n")
+		if checked {
+			b.WriteString("\tOnExit(heapStats)\n")
+		}
+		b.WriteString("}\n\n")
+
+	case StateContext:
+		b.WriteString("// Context holds the state of one execution, the heap. Procedures\n")
+		b.WriteString("// take it first, so executions with contexts of their own can run\n")
+		b.WriteString("// in parallel.\n")
+		b.WriteString("type Context struct {\n")
+		b.WriteString("\theap     map[int]*heapObject\n")
+		b.WriteString("\theapNext int // the next reference New may return\n")
+		if checked {
+			b.WriteString("\n\t// heapWrites counts the writes to each field.\n")
+			b.WriteString("\theapWrites [" + nfields + "]int\n")
+		}
+		b.WriteString("}\n\n")
+
+		b.WriteString("func NewContext() *Context {\n")
+		b.WriteString("\treturn &Context{heap: map[int]*heapObject{}, heapNext: 1}\n")
+		b.WriteString("}\n\n")
+		b.WriteString(g.emitMainContext())
+
+	case StateShared:
+		b.WriteString("// Context holds the state of executions, the heap. Procedures take\n")
+		b.WriteString("// it first; executions may share one, since its heap is locked per\n")
+		b.WriteString("// shard of objects.\n")
+		b.WriteString("type Context struct {\n")
+		b.WriteString("\theap *HeapShards[heapObject]\n")
+		b.WriteString("}\n\n")
+
+		b.WriteString("func NewContext() *Context {\n")
+		b.WriteString("\treturn &Context{heap: NewHeapShards[heapObject](" + nfields + ")}\n")
+		b.WriteString("}\n\n")
+		b.WriteString(g.emitMainContext())
+	}
+
+	b.WriteString(g.heapFunc("heapAlloc", "") + " int {\n")
+	if opts.State == StateShared {
+		b.WriteString("\tobj := newHeapObject()\n")
+		b.WriteString("\tobj.allocated = true\n")
+		b.WriteString("\treturn ctx.heap.Alloc(obj)\n")
+	} else {
+		heap, next := g.heapState("heap"), g.heapState("heapNext")
+		b.WriteString("\tfor " + heap + "[" + next + "] != nil {\n")
+		b.WriteString("\t\t" + next + "++\n")
+		b.WriteString("\t}\n")
+		b.WriteString("\to := " + next + "\n")
+		b.WriteString("\t" + next + "++\n")
+		b.WriteString("\tobj := newHeapObject()\n")
+		b.WriteString("\tobj.allocated = true\n")
+		b.WriteString("\t" + heap + "[o] = obj\n")
+		b.WriteString("\treturn o\n")
+	}
+	b.WriteString("}\n\n")
+
+	// heapZero is the object never written, read in place of a
+	// missing one. Executions that may run in parallel get one each,
+	// fresh, since reading a map also updates it (see runtime.Map).
+	if opts.State == StateGlobal {
+		b.WriteString("var heapZero = newHeapObject()\n\n")
+	}
+	b.WriteString("func newHeapObject() *heapObject {\n")
+	b.WriteString("\treturn &heapObject{\n")
+	for _, f := range fields {
+		if z := g.zeroValue(valueVar(f.Ty)); z != "" {
+			b.WriteString("\t\t" + GoName(f.Name) + ": " + z + ",\n")
+		}
+	}
+	b.WriteString("\t}\n")
+	b.WriteString("}\n\n")
+
+	b.WriteString("func heapObj(objs map[int]*heapObject, o int, pos, expr string) *heapObject {\n")
+	b.WriteString("\tif o == nullRef {\n")
+	b.WriteString("\t\tpanic(&NullDereferenceError{Pos: pos, Expr: expr})\n")
+	b.WriteString("\t}\n")
+	b.WriteString("\tif obj, ok := objs[o]; ok {\n")
+	b.WriteString("\t\treturn obj\n")
+	b.WriteString("\t}\n")
+	if opts.State == StateGlobal {
+		b.WriteString("\treturn heapZero\n")
+	} else {
+		b.WriteString("\treturn newHeapObject()\n")
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("func heapObjForWrite(objs map[int]*heapObject, o int, pos, expr string) *heapObject {\n")
+	b.WriteString("\tif o == nullRef {\n")
+	b.WriteString("\t\tpanic(&NullDereferenceError{Pos: pos, Expr: expr})\n")
+	b.WriteString("\t}\n")
+	b.WriteString("\tobj, ok := objs[o]\n")
+	b.WriteString("\tif !ok {\n")
+	b.WriteString("\t\tobj = newHeapObject()\n")
+	b.WriteString("\t\tobjs[o] = obj\n")
+	b.WriteString("\t}\n")
+	b.WriteString("\treturn obj\n")
+	b.WriteString("}\n\n")
+
+	if checked {
+		b.WriteString("// heapObjAllocated is heapObj for a field other than alloc, which\n")
+		b.WriteString("// only allocated objects have.\n")
+		b.WriteString("func heapObjAllocated(objs map[int]*heapObject, o int, pos, expr, access string) *heapObject {\n")
+		b.WriteString("\tobj := heapObj(objs, o, pos, expr)\n")
+		b.WriteString("\tif !obj.allocated {\n")
+		b.WriteString("\t\tpanic(&HeapError{Pos: pos, Msg: access + \" unallocated object\", Expr: expr})\n")
+		b.WriteString("\t}\n")
+		b.WriteString("\treturn obj\n")
+		b.WriteString("}\n\n")
+	}
 
-// heapRead reads a field from an object.
-// Panics if the object or field is missing.
-func heapRead(obj interface{}, field string) interface{} {
-	id, ok := obj.(int)
-	if !ok {
-		panic("heapRead: object is not an int reference")
+	for i, f := range p.Fields {
+		name := GoName(f.Name)
+		ty := g.goType(valueVar(f.Ty))
+		index := strconv.Itoa(i)
+
+		// A map in a shared heap is only used under its shard's lock:
+		// accessors hand out and store copies of their own.
+		clone := ""
+		if _, ok := f.Ty.(boogie.MapType); ok && opts.State == StateShared {
+			clone = ".Clone()"
+		}
+
+		b.WriteString(g.heapFunc("heapRead_"+name, "o int, pos, expr string") + " " + ty + " {\n")
+		objs := g.heapObjects(&b)
+		switch {
+		case isAllocField(f):
+			b.WriteString("\treturn heapObj(" + objs + ", o, pos, expr).allocated\n")
+		case checked:
+			b.WriteString("\tobj := heapObjAllocated(" + objs + ", o, pos, expr, \"read of\")\n")
+			b.WriteString("\tif !obj.written[" + index + "] {\n")
+			b.WriteString("\t\tpanic(&HeapError{Pos: pos, Msg: \"read of uninitialised field\", Expr: expr})\n")
+			b.WriteString("\t}\n")
+			b.WriteString("\treturn obj." + name + clone + "\n")
+		default:
+			b.WriteString("\treturn heapObj(" + objs + ", o, pos, expr)." + name + clone + "\n")
+		}
+		b.WriteString("}\n\n")
+
+		b.WriteString(g.heapFunc("heapWrite_"+name, "o int, v "+ty+", pos, expr string") + " {\n")
+		objs = g.heapObjects(&b)
+		switch {
+		case isAllocField(f):
+			b.WriteString("\theapObjForWrite(" + objs + ", o, pos, expr).allocated = v\n")
+		case checked:
+			b.WriteString("\tobj := heapObjAllocated(" + objs + ", o, pos, expr, \"write to\")\n")
+			b.WriteString("\tobj." + name + " = v" + clone + "\n")
+			b.WriteString("\tobj.written[" + index + "] = true\n")
+		default:
+			b.WriteString("\theapObjForWrite(" + objs + ", o, pos, expr)." + name + " = v" + clone + "\n")
+		}
+		if checked {
+			if opts.State == StateShared {
+				b.WriteString("\ts.Writes[" + index + "]++\n")
+			} else {
+				b.WriteString("\t" + g.heapState("heapWrites") + "[" + index + "]++\n")
+			}
+		}
+		b.WriteString("}\n\n")
 	}
 
-	fields, ok := heap[id]
-	if !ok {
-		panic(fmt.Sprintf("heapRead: unknown object %d", id))
+	b.WriteString(g.emitHeapSnapshot(p.Fields))
+
+	if checked {
+		var names []string
+		for _, f := range p.Fields {
+			names = append(names, strconv.Quote(f.Name))
+		}
+		b.WriteString(g.heapFunc("heapStats", "") + " {\n")
+		b.WriteString("\tlive := 0\n")
+		b.WriteString(g.heapEach("_", "\tif obj.allocated {\n\t\tlive++\n\t}\n"))
+		writes := g.heapState("heapWrites") + "[:]"
+		if opts.State == StateShared {
+			writes = "ctx.heap.Writes()"
+		}
+		b.WriteString("\tReportHeap(live, []string{" + strings.Join(names, ", ") + "}, " + writes + ")\n")
+		b.WriteString("}\n\n")
 	}
 
-	val, ok := fields[field]
-	if !ok {
-		panic(fmt.Sprintf("heapRead: unknown field %s on object %d", field, id))
+	return b.String()
+}
+
+// The heap code below is written once for all three StateModes: the
+// heap state is a package variable or a field of the Context, and heap
+// functions are plain functions or methods of the Context.
+
+// heapState names a heap variable: heap, heapNext or heapWrites.
+func (g *emitter) heapState(name string) string {
+	if g.opts.State == StateGlobal {
+		return name
 	}
+	return "ctx." + name
+}
 
-	return val
+// heapFunc emits the signature of heap function name, up to the
+// results.
+func (g *emitter) heapFunc(name, params string) string {
+	if g.opts.State == StateGlobal {
+		return "func " + name + "(" + params + ")"
+	}
+	return "func (ctx *Context) " + name + "(" + params + ")"
 }
 
-// heapWrite writes a field on an object.
-// Allocates the object if it does not exist.
-func heapWrite(obj interface{}, field string, value interface{}) {
-	id, ok := obj.(int)
-	if !ok {
-		panic("heapWrite: object is not an int reference")
+// heapCall emits a call of heap function name.
+func (g *emitter) heapCall(name string, args ...string) string {
+	if g.opts.State != StateGlobal {
+		name = "ctx." + name
 	}
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
 
-	fields, ok := heap[id]
-	if !ok {
-		fields = make(map[string]interface{})
-		heap[id] = fields
+// heapObjects emits the start of an accessor of object o and returns
+// the map holding o: under StateShared, that of the shard of o, locked
+// until the accessor returns.
+func (g *emitter) heapObjects(b *strings.Builder) string {
+	if g.opts.State != StateShared {
+		return g.heapState("heap")
 	}
+	b.WriteString("\ts := ctx.heap.Shard(o)\n")
+	b.WriteString("\ts.Lock()\n")
+	b.WriteString("\tdefer s.Unlock()\n")
+	return "s.Objects"
+}
+
+// heapEach emits a loop running body, at one level of indentation, on
+// each object of the heap: obj, with reference o (or _, when o is "_").
+func (g *emitter) heapEach(o, body string) string {
+	indented := strings.ReplaceAll(strings.TrimSuffix(body, "\n"), "\n", "\n\t") + "\n"
+	if g.opts.State == StateShared {
+		return "\tctx.heap.Each(func(" + o + " int, obj *heapObject) {\n\t" + indented + "\t})\n"
+	}
+	return "\tfor " + o + ", obj := range " + g.heapState("heap") + " {\n\t" + indented + "\t}\n"
+}
+
+// emitMainContext emits mainContext, the context of the execution of
+// the entry point, for the runtime's debugging hooks to look at.
+func (g *emitter) emitMainContext() string {
+	var b strings.Builder
+	b.WriteString("// mainContext is the context of the execution of the entry point,\n")
+	b.WriteString("// which the runtime's heap dumps and statistics look at.\n")
+	b.WriteString("var mainContext = NewContext()\n\n")
+	b.WriteString("// Explored paths each start from a context of their own.\n")
+	b.WriteString("func init() {\n")
+	b.WriteString("\tOnReset(func() { mainContext = NewContext() })\n")
+	b.WriteString("\tOnSnapshot(func() HeapSnapshot { return mainContext.heapSnapshot() })\n")
+	if g.opts.Heap == HeapChecked {
+		b.WriteString("\tOnExit(func() { mainContext.heapStats() })\n")
+	}
+	b.WriteString("}\n\n")
+	return b.String()
+}
+
+// emitHeapSnapshot emits heapSnapshot, which copies the heap out for
+// the runtime's debugging (see runtime.HeapSnapshot).
+func (g *emitter) emitHeapSnapshot(fields []*boogie.FieldDecl) string {
+	var body strings.Builder
+	body.WriteString("\tsnap := &ObjectSnapshot{Allocated: obj.allocated, Fields: map[string]string{}, Refs: map[string]int{}}\n")
+	for i, f := range fields {
+		if isAllocField(f) {
+			continue
+		}
+		indent := "\t"
+		if g.opts.Heap == HeapChecked {
+			body.WriteString("\tif obj.written[" + strconv.Itoa(i) + "] {\n")
+			indent = "\t\t"
+		}
+
+		value := "obj." + GoName(f.Name)
+		name := strconv.Quote(f.Name)
+		switch t := f.Ty.(type) {
+		case boogie.RefType:
+			body.WriteString(indent + "snap.Refs[" + name + "] = " + value + "\n")
+		case boogie.BvType:
+			w := strconv.Itoa(t.Width)
+			if bvBig(t.Width) {
+				body.WriteString(indent + "snap.Fields[" + name + "] = FormatBigBv(" + value + ", " + w + ")\n")
+			} else {
+				body.WriteString(indent + "snap.Fields[" + name + "] = FormatBv(uint64(" + value + "), " + w + ")\n")
+			}
+		default:
+			body.WriteString(indent + "snap.Fields[" + name + "] = FormatValue(" + value + ")\n")
+		}
+
+		if g.opts.Heap == HeapChecked {
+			body.WriteString("\t}\n")
+		}
+	}
+	body.WriteString("\ts[o] = snap\n")
+
+	var b strings.Builder
+	b.WriteString(g.heapFunc("heapSnapshot", "") + " HeapSnapshot {\n")
+	b.WriteString("\ts := HeapSnapshot{}\n")
+	b.WriteString(g.heapEach("o", body.String()))
+	b.WriteString("\treturn s\n")
+	b.WriteString("}\n\n")
+	return b.String()
+}
+
+// isAllocField reports whether f is the allocation bit of Boogie heap
+// encodings, const alloc: Field bool.
+func isAllocField(f *boogie.FieldDecl) bool {
+	_, ok := f.Ty.(boogie.BoolType)
+	return ok && f.Name == "alloc"
+}
+
+func (g *emitter) emitHeapRead(h *boogie.HeapRead) string {
+	return g.heapCall("heapRead_"+GoName(h.Field), g.emitExpr(h.Obj), posArgs(h.Pos, h))
+}
+
+func (g *emitter) emitHeapWrite(h *boogie.HeapWrite, indent int) string {
+	target := &boogie.HeapRead{Obj: h.Obj, Field: h.Field}
+	return indentStr(indent) +
+		g.heapCall("heapWrite_"+GoName(h.Field), g.emitExpr(h.Obj), g.emitMapValue(h.Value), posArgs(h.Pos, target)) + "\n"
+}
 
-	fields[field] = value
+func (g *emitter) emitAlloc(a *boogie.Alloc, indent int) string {
+	return indentStr(indent) + GoName(a.V.Name) + " = " + g.heapCall("heapAlloc") + "\n"
 }
@@ -0,0 +1,137 @@
+package codegen
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// Maps are the runtime's persistent Map[K, V]: m[i := v] yields a new
+// map and leaves m unchanged, so copying a map never aliases it.
+//
+// Keys must be comparable Go values. Arbitrary precision ints, reals
+// and wide bitvectors are keyed by their canonical decimal string;
+// maps of several arguments by an array of their keys. Values use the
+// representation of parameters (bigParams): they are not analysed.
+//
+// A polymorphic map, <a>[Field a]a, holds values of every instantiation
+// in one Map[any, any]. Keys of different instantiations have different
+// Go types (Field[*big.Int], Field[bool]) and so never collide, and
+// each select asserts the value back to the type it instantiates. A key
+// never set reads as the zero of that type, which the select supplies.
+
+// valueVar stands for a value of type t held in a map, which has the
+// representation of an unanalysed variable.
+func valueVar(t boogie.Type) boogie.Var {
+	return boogie.Var{Ty: t}
+}
+
+func (g *emitter) mapGoType(t boogie.MapType) string {
+	if len(t.TypeParams) > 0 {
+		return "Map[any, any]"
+	}
+	return "Map[" + g.mapKeyType(t.Args) + ", " + g.goType(valueVar(t.Result)) + "]"
+}
+
+func (g *emitter) mapKeyType(args []boogie.Type) string {
+	if len(args) > 1 {
+		return "[" + strconv.Itoa(len(args)) + "]any"
+	}
+	switch t := args[0].(type) {
+	case boogie.IntType:
+		switch {
+		case g.bigParams():
+			return "string"
+		case g.opts.Ints == IntNative:
+			return "int"
+		}
+		return "int64"
+	case boogie.RealType:
+		return "string"
+	case boogie.BvType:
+		if bvBig(t.Width) {
+			return "string"
+		}
+	}
+	return g.goType(valueVar(args[0]))
+}
+
+// mapZero emits a map holding def everywhere. A polymorphic map
+// ignores def: its selects supply their own.
+func (g *emitter) mapZero(t boogie.MapType, def string) string {
+	if len(t.TypeParams) > 0 {
+		return "MapNew[any, any](nil)"
+	}
+	return "MapNew[" + g.mapKeyType(t.Args) + ", " + g.goType(valueVar(t.Result)) + "](" + def + ")"
+}
+
+// zeroLiteral is the initial value of a variable of type t, spelled
+// out even where Go's zero value would do.
+func (g *emitter) zeroLiteral(t boogie.Type) string {
+	if z := g.zeroValue(valueVar(t)); z != "" {
+		return z
+	}
+	if _, ok := t.(boogie.BoolType); ok {
+		return "false"
+	}
+	return "0"
+}
+
+func (g *emitter) emitMapKey(args []boogie.Expr) string {
+	if len(args) == 1 {
+		return g.emitKeyPart(args[0])
+	}
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = g.emitKeyPart(a)
+
+		// Boxed in an any, an untyped constant would keep Go's default
+		// type, int, and never equal an int64 variable's value.
+		if _, ok := a.(*boogie.VarExpr); !ok && isInt(a) && !g.bigParams() {
+			parts[i] = g.mapKeyType([]boogie.Type{a.Type()}) + "(" + parts[i] + ")"
+		}
+	}
+	return "[" + strconv.Itoa(len(args)) + "]any{" + strings.Join(parts, ", ") + "}"
+}
+
+func (g *emitter) emitKeyPart(e boogie.Expr) string {
+	switch t := e.Type().(type) {
+	case boogie.IntType:
+		if lit, ok := e.(*boogie.IntLit); ok && g.bigParams() {
+			return strconv.Quote(lit.BigValue().String())
+		}
+		if g.bigParams() {
+			return g.emitAs(e, true) + ".String()"
+		}
+	case boogie.RealType:
+		return g.emitExpr(e) + ".RatString()"
+	case boogie.BvType:
+		if bvBig(t.Width) {
+			return g.emitExpr(e) + ".String()"
+		}
+	}
+	return g.emitExpr(e)
+}
+
+// emitMapValue emits v, to be stored in a map.
+func (g *emitter) emitMapValue(v boogie.Expr) string {
+	return g.emitAs(v, g.bigParams())
+}
+
+func (g *emitter) emitMapSelect(s *boogie.MapSelect) string {
+	if t := s.Map.Type().(boogie.MapType); len(t.TypeParams) > 0 {
+		v := s.Type()
+		return "PolyGet[" + g.goType(valueVar(v)) + "](" + g.emitExpr(s.Map) + ", " +
+			g.emitMapKey(s.Args) + ", " + g.zeroLiteral(v) + ")"
+	}
+	return g.emitExpr(s.Map) + ".Get(" + g.emitMapKey(s.Args) + ")"
+}
+
+func (g *emitter) emitMapStore(s *boogie.MapStore) string {
+	if t := s.Map.Type().(boogie.MapType); len(t.TypeParams) > 0 {
+		return "PolySet[" + g.goType(valueVar(s.Value.Type())) + "](" + g.emitExpr(s.Map) + ", " +
+			g.emitMapKey(s.Args) + ", " + g.emitMapValue(s.Value) + ")"
+	}
+	return g.emitExpr(s.Map) + ".Set(" + g.emitMapKey(s.Args) + ", " + g.emitMapValue(s.Value) + ")"
+}
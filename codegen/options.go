@@ -0,0 +1,48 @@
+package codegen
+
+import "github.com/ezrantn/boogo/boogie/ranges"
+
+// IntMode selects the Go representation of Boogie's unbounded int.
+type IntMode int
+
+const (
+	IntBig     IntMode = iota // *big.Int: exact, as verified (default)
+	IntNative                 // Go int: fast, but silently wraps on overflow
+	IntChecked                // int64: fast, panics where it would diverge from Boogie
+	IntRange                  // int64 where range analysis proves it exact, *big.Int elsewhere
+)
+
+// HeapMode selects how the heap treats accesses Boogie leaves
+// unconstrained.
+type HeapMode int
+
+const (
+	HeapTotal   HeapMode = iota // every field of every object reads as its zero until written (default)
+	HeapChecked                 // accesses to unallocated objects and reads of unwritten fields panic
+)
+
+// StateMode selects where the state of an execution, the heap, lives.
+type StateMode int
+
+const (
+	StateGlobal  StateMode = iota // package variables: one execution at a time (default)
+	StateContext                  // a Context procedures take first: one per concurrent execution
+	StateShared                   // a Context whose heap is locked, for executions to share
+)
+
+// Options selects how Boogie semantics are mapped onto Go.
+// The zero Options is faithful to Boogie.
+type Options struct {
+	Ints  IntMode
+	Heap  HeapMode
+	State StateMode
+}
+
+// emitter carries the Options through code generation.
+type emitter struct {
+	opts Options
+
+	// ranges is the analysis of the procedure being emitted (IntRange
+	// only); without one, every int is big.
+	ranges *ranges.Result
+}
@@ -1,43 +1,90 @@
 package codegen
 
 import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/ezrantn/boogo/boogie"
+	"github.com/ezrantn/boogo/boogie/ranges"
+	"github.com/ezrantn/boogo/runtime"
 )
 
-// EmitProc emits Go code for a Boogie procedure.
+// EmitProc emits Go code for a Boogie procedure, using the default
+// Options.
 func EmitProc(p *boogie.Procedure) string {
+	return EmitProcWithOptions(p, Options{})
+}
+
+// EmitProcWithOptions emits Go code for a Boogie procedure.
+func EmitProcWithOptions(p *boogie.Procedure, opts Options) string {
+	g := &emitter{opts: opts}
+	if opts.Ints == IntRange {
+		g.ranges = ranges.Analyze(p)
+	}
+	return g.emitProc(p)
+}
+
+func (g *emitter) emitProc(p *boogie.Procedure) string {
+	if p.Bodiless {
+		return g.emitExternProc(p)
+	}
+
 	var b strings.Builder
 
 	// Function signature
 	b.WriteString("func ")
-	b.WriteString(p.Name)
+	b.WriteString(GoName(p.Name))
+	b.WriteString(emitTypeParams(p.TypeParams))
 	b.WriteString("(")
-	b.WriteString(emitParams(p.Params))
+	b.WriteString(g.emitCtxParams(p.Params))
 	b.WriteString(")")
 
 	if len(p.Rets) > 0 {
 		b.WriteString(" ")
-		b.WriteString(emitReturns(p.Rets))
+		b.WriteString(g.emitReturns(p.Rets))
 	}
 
 	b.WriteString(" {\n")
 
-	// Local variable declarations
+	// Out-parameters whose Go zero value is not a usable value
+	// (nil *big.Int) start out as Boogie's zero instead.
+	for _, v := range p.Rets {
+		if zero := g.zeroValue(v); zero != "" {
+			b.WriteString("\t" + GoName(v.Name) + " = " + zero + "\n")
+		}
+	}
+
+	// Local variable declarations. Boogie allows locals that are never
+	// read; Go does not, so each one is marked as used.
 	if len(p.Locals) > 0 {
 		for _, v := range p.Locals {
-			b.WriteString("\tvar ")
-			b.WriteString(v.Name)
-			b.WriteString(" ")
-			b.WriteString(goType(v.Ty))
-			b.WriteString("\n")
+			if zero := g.zeroValue(v); zero != "" {
+				b.WriteString("\t" + GoName(v.Name) + " := " + zero + "\n")
+			} else {
+				b.WriteString("\tvar ")
+				b.WriteString(GoName(v.Name))
+				b.WriteString(" ")
+				b.WriteString(g.goType(v))
+				b.WriteString("\n")
+			}
+			b.WriteString("\t_ = " + GoName(v.Name) + "\n")
 		}
 		b.WriteString("\n")
 	}
 
 	// Body
-	b.WriteString(EmitStmts(p.Body, 1))
+	b.WriteString(g.emitStmts(p.Body, 1))
+
+	// Out-parameters are named results, so falling off the end of a
+	// Boogie procedure is a bare return.
+	if len(p.Rets) > 0 && !endsWithReturn(p.Body) {
+		b.WriteString("\treturn\n")
+	}
 
 	b.WriteString("}\n\n")
 	return b.String()
@@ -47,40 +94,258 @@ func EmitProc(p *boogie.Procedure) string {
 // Helpers
 // ========================
 
-func emitParams(vars []boogie.Var) string {
+func (g *emitter) emitParams(vars []boogie.Var) string {
 	var ps []string
 	for _, v := range vars {
-		ps = append(ps, v.Name+" "+goType(v.Ty))
+		ps = append(ps, GoName(v.Name)+" "+g.goType(v))
 	}
 	return strings.Join(ps, ", ")
 }
 
-func emitReturns(vars []boogie.Var) string {
-	if len(vars) == 1 {
-		return goType(vars[0].Ty)
+// emitCtxParams emits the in-parameters, after the Context outside
+// StateGlobal.
+func (g *emitter) emitCtxParams(vars []boogie.Var) string {
+	ps := g.emitParams(vars)
+	if g.opts.State == StateGlobal {
+		return ps
+	}
+	if ps == "" {
+		return "ctx *Context"
 	}
+	return "ctx *Context, " + ps
+}
 
-	var rs []string
-	for _, v := range vars {
-		rs = append(rs, goType(v.Ty))
+// emitReturns emits the out-parameters as named results, which is
+// what lets a bare Boogie "return;" work unchanged in Go.
+func (g *emitter) emitReturns(vars []boogie.Var) string {
+	return "(" + g.emitParams(vars) + ")"
+}
+
+// bigParams reports whether int parameters and results are *big.Int.
+// Under IntRange they always are, so that callers and callees agree
+// without analysing across procedures.
+func (g *emitter) bigParams() bool {
+	return g.opts.Ints == IntBig || g.opts.Ints == IntRange
+}
+
+func endsWithReturn(stmts []boogie.Stmt) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	_, ok := stmts[len(stmts)-1].(*boogie.Return)
+	return ok
+}
+
+// goReserved are names a Boogie identifier cannot keep in Go: keywords,
+// the predeclared identifiers, which generated code uses unqualified
+// (int64, uint8, any, nil, len, ...), plus main and init, which the
+// generated package needs for itself, and ctx, the Context procedures
+// may take.
+var goReserved = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true,
+	"continue": true, "default": true, "defer": true, "else": true,
+	"fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true,
+	"map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true,
+	"var": true, "main": true, "init": true, "ctx": true,
+
+	"any": true, "bool": true, "byte": true, "comparable": true,
+	"complex64": true, "complex128": true, "error": true,
+	"float32": true, "float64": true, "int": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "rune": true,
+	"string": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "uintptr": true,
+
+	"true": true, "false": true, "iota": true, "nil": true,
+
+	"append": true, "cap": true, "clear": true, "close": true,
+	"complex": true, "copy": true, "delete": true, "imag": true,
+	"len": true, "make": true, "max": true, "min": true, "new": true,
+	"panic": true, "print": true, "println": true, "real": true,
+	"recover": true,
+}
+
+// runtimeNames are the package-level identifiers of the runtime, which
+// generated code sees unqualified, dot-imported or inlined, and the
+// packages it imports, which inlined code sees too: read off its
+// source, so that no Boogie identifier can redeclare or shadow one.
+var runtimeNames = declaredNames(runtime.Source)
+
+// importedNames are the packages generated code itself may import.
+// Extern packages are imported under an externAlias, all of which start
+// with externPrefix, which GoName escapes Boogie names starting with.
+var importedNames = map[string]bool{"big": true}
+
+// generatedNames are the identifiers generated code declares for
+// itself, besides those derived from Boogie names: package-level ones,
+// and the bookkeeping fields heapObject has next to the heap fields.
+var generatedNames = map[string]bool{
+	"allocated": true, "written": true,
+	"heapObject": true, "nullRef": true, "heap": true, "heapNext": true,
+	"heapWrites": true, "heapReset": true, "heapAlloc": true,
+	"heapZero": true, "newHeapObject": true, "heapObj": true,
+	"heapObjForWrite": true, "heapObjAllocated": true,
+	"heapSnapshot": true, "heapStats": true,
+	"Context": true, "NewContext": true, "mainContext": true,
+}
+
+// declaredNames returns the package-level identifiers declared by the
+// Go files of fsys, tests aside, and the names of the packages they
+// import.
+func declaredNames(fsys fs.FS) map[string]bool {
+	files, err := fs.Glob(fsys, "*.go")
+	if err != nil {
+		panic(err)
+	}
+
+	names := map[string]bool{}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			panic(err)
+		}
+		f, err := parser.ParseFile(fset, file, src, parser.SkipObjectResolution)
+		if err != nil {
+			panic(err)
+		}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					names[d.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.ImportSpec:
+						path, _ := strconv.Unquote(s.Path.Value)
+						names[path[strings.LastIndex(path, "/")+1:]] = true
+					case *ast.TypeSpec:
+						names[s.Name.Name] = true
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							names[n.Name] = true
+						}
+					}
+				}
+			}
+		}
 	}
-	return "(" + strings.Join(rs, ", ") + ")"
+	return names
 }
 
-// goType maps Boogie types to Go types (EBS v1).
-func goType(t boogie.Type) string {
-	switch t {
+// GoName maps a Boogie identifier to a valid Go identifier, one to
+// one, so that distinct Boogie names never meet in Go. A name made of
+// Go's letters, digits and '_' is kept, unless it is reserved, including
+// those of the runtime, of generated declarations and of imported
+// packages, starts with a generatedPrefix, or ends in '_'. Any other is
+// escaped: each '_' doubled, each rune Go does not allow (dots,
+// dollars, primes, ...) written as its hex code between '_'s, and a
+// '_' appended, so that len is len_, len_ is len___ and a.b is a_2e_b_.
+func GoName(name string) string {
+	if !needsEscape(name) {
+		return name
+	}
 
-	case boogie.IntType{}:
-		return "int"
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '_':
+			b.WriteString("__")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteString("_" + strconv.FormatInt(int64(r), 16) + "_")
+		}
+	}
+	b.WriteByte('_')
+	return b.String()
+}
+
+// generatedPrefixes start the names generated code derives from GoName
+// results, as heapRead_next is from the field next.
+var generatedPrefixes = []string{"heapRead_", "heapWrite_", "memo_", "T_", externPrefix}
 
-	case boogie.BoolType{}:
+// needsEscape reports whether GoName cannot keep name as it is.
+func needsEscape(name string) bool {
+	if goReserved[name] || runtimeNames[name] || generatedNames[name] ||
+		importedNames[name] || strings.HasSuffix(name, "_") {
+		return true
+	}
+	for _, p := range generatedPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	for _, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// goType maps the Boogie type of v to a Go type (EBS v1).
+func (g *emitter) goType(v boogie.Var) string {
+	switch t := v.Ty.(type) {
+
+	case boogie.IntType:
+		switch {
+		case g.bigVar(v):
+			return "*big.Int"
+		case g.opts.Ints == IntNative:
+			return "int"
+		}
+		return "int64"
+
+	case boogie.RealType:
+		return "*big.Rat"
+
+	case boogie.BoolType:
 		return "bool"
 
-	case boogie.RefType{}:
+	case boogie.RefType:
 		return "int" // object references
 
+	case boogie.BvType:
+		return bvGoType(t.Width)
+
+	case boogie.MapType:
+		return g.mapGoType(t)
+
+	case boogie.TypeVar:
+		return typeParamName(t.Name)
+
+	case boogie.CtorType:
+		return g.ctorGoType(t)
+
 	default:
 		panic("unsupported type in codegen")
 	}
 }
+
+// zeroValue returns the Go expression that initializes v, or "" when
+// Go's zero value already serves.
+func (g *emitter) zeroValue(v boogie.Var) string {
+	if m, ok := v.Ty.(boogie.MapType); ok {
+		return g.mapZero(m, g.zeroLiteral(m.Result))
+	}
+	if tv, ok := v.Ty.(boogie.TypeVar); ok {
+		return "ZeroOf[" + typeParamName(tv.Name) + "]()"
+	}
+	if _, ok := v.Ty.(boogie.RealType); ok {
+		return "new(big.Rat)"
+	}
+	if bv, ok := v.Ty.(boogie.BvType); ok && bvBig(bv.Width) {
+		return "new(big.Int)"
+	}
+	if g.bigVar(v) {
+		return "new(big.Int)"
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package codegen
+
+import (
+	"strconv"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// Reals are exact rationals, *big.Rat. Like *big.Int values they are
+// never mutated once created: every operation allocates its result.
+
+func isReal(e boogie.Expr) bool {
+	_, ok := e.Type().(boogie.RealType)
+	return ok
+}
+
+// ratBinOps names the runtime helpers for real arithmetic; division,
+// which can fault, is emitted separately.
+var ratBinOps = map[boogie.BinOpKind]string{
+	boogie.Add: "RatAdd",
+	boogie.Sub: "RatSub",
+	boogie.Mul: "RatMul",
+}
+
+func (g *emitter) emitRatBinOp(b *boogie.BinOp) string {
+	l, r := g.emitExpr(b.Left), g.emitExpr(b.Right)
+	if b.Op == boogie.Div {
+		return "RatQuo(" + l + ", " + r + ", " + posArgs(b.Pos, b) + ")"
+	}
+	if fn, ok := ratBinOps[b.Op]; ok {
+		return fn + "(" + l + ", " + r + ")"
+	}
+	if cmp, ok := bigCmpOps[b.Op]; ok {
+		return "(" + l + ".Cmp(" + r + ") " + cmp + " 0)"
+	}
+	panic("unsupported binary operator on reals")
+}
+
+func (g *emitter) emitRealLit(l *boogie.RealLit) string {
+	return "RatLit(" + strconv.Quote(l.Value.RatString()) + ")"
+}
+
+// emitConvert emits real(i), exact, and int(r), which rounds down.
+func (g *emitter) emitConvert(c *boogie.Convert) string {
+	if _, ok := c.To.(boogie.RealType); ok {
+		if g.bigExpr(c.X) {
+			return "new(big.Rat).SetInt(" + g.emitExpr(c.X) + ")"
+		}
+		return "new(big.Rat).SetInt64(int64(" + g.emitExpr(c.X) + "))"
+	}
+
+	floor := "RatFloor(" + g.emitExpr(c.X) + ")"
+	switch g.opts.Ints {
+	case IntNative:
+		return "int(" + floor + ".Int64())"
+	case IntChecked:
+		return "CheckedInt64(" + floor + ", " + posArgs(c.Pos, c) + ")"
+	}
+	return floor
+}
@@ -8,38 +8,59 @@ import (
 	"github.com/ezrantn/boogo/boogie"
 )
 
-// EmitStmt emits Go code for a single Boogie statement.
+// EmitStmt emits Go code for a single Boogie statement, using the
+// default Options.
 func EmitStmt(s boogie.Stmt, indent int) string {
+	return (&emitter{}).emitStmt(s, indent)
+}
+
+// EmitStmts emits a sequence of statements, using the default Options.
+func EmitStmts(stmts []boogie.Stmt, indent int) string {
+	return (&emitter{}).emitStmts(stmts, indent)
+}
+
+func (g *emitter) emitStmt(s boogie.Stmt, indent int) string {
 	switch st := s.(type) {
 
 	case *boogie.Assign:
-		return emitAssign(st, indent)
+		return g.emitAssign(st, indent)
 
 	case *boogie.If:
-		return emitIf(st, indent)
+		return g.emitIf(st, indent)
 
 	case *boogie.While:
-		return emitWhile(st, indent)
+		return g.emitWhile(st, indent)
 
 	case *boogie.Call:
-		return emitCall(st, indent)
+		return g.emitCall(st, indent)
 
 	case *boogie.Return:
-		return emitReturn(st, indent)
+		return g.emitReturn(st, indent)
 
 	case *boogie.HeapWrite:
-		return emitHeapWrite(st, indent)
+		return g.emitHeapWrite(st, indent)
+
+	case *boogie.Alloc:
+		return g.emitAlloc(st, indent)
+
+	case *boogie.Check:
+		return g.emitCheck(st, indent)
+
+	case *boogie.Havoc:
+		return g.emitHavoc(st, indent)
+
+	case *boogie.Choice:
+		return g.emitChoice(st, indent)
 
 	default:
 		panic(fmt.Sprintf("unsupported statement in codegen: %T", s))
 	}
 }
 
-// EmitStmts emits a sequence of statements.
-func EmitStmts(stmts []boogie.Stmt, indent int) string {
+func (g *emitter) emitStmts(stmts []boogie.Stmt, indent int) string {
 	var b strings.Builder
 	for _, s := range stmts {
-		b.WriteString(EmitStmt(s, indent))
+		b.WriteString(g.emitStmt(s, indent))
 	}
 	return b.String()
 }
@@ -48,69 +69,187 @@ func EmitStmts(stmts []boogie.Stmt, indent int) string {
 // Individual statements
 // ========================
 
-func emitAssign(a *boogie.Assign, indent int) string {
-	lhs := EmitExpr(a.Lhs)
-	rhs := EmitExpr(a.Rhs)
+func (g *emitter) emitAssign(a *boogie.Assign, indent int) string {
+	lhs := g.emitExpr(a.Lhs)
+	rhs := g.emitExpr(a.Rhs)
+	if x, ok := a.Lhs.(*boogie.VarExpr); ok {
+		rhs = g.emitAs(a.Rhs, g.bigVar(x.V))
+	}
 	return indentStr(indent) + lhs + " = " + rhs + "\n"
 }
 
-func emitIf(i *boogie.If, indent int) string {
+func (g *emitter) emitIf(i *boogie.If, indent int) string {
 	var b strings.Builder
 
-	cond := EmitExpr(i.Cond)
+	cond := g.emitExpr(i.Cond)
 	b.WriteString(indentStr(indent) + "if " + cond + " {\n")
-	b.WriteString(EmitStmts(i.Then, indent+1))
+	b.WriteString(g.emitStmts(i.Then, indent+1))
 	b.WriteString(indentStr(indent) + "} else {\n")
-	b.WriteString(EmitStmts(i.Else, indent+1))
+	b.WriteString(g.emitStmts(i.Else, indent+1))
 	b.WriteString(indentStr(indent) + "}\n")
 
 	return b.String()
 }
 
-func emitWhile(w *boogie.While, indent int) string {
+func (g *emitter) emitWhile(w *boogie.While, indent int) string {
 	var b strings.Builder
 
-	cond := EmitExpr(w.Cond)
+	cond := g.emitExpr(w.Cond)
 	b.WriteString(indentStr(indent) + "for " + cond + " {\n")
-	b.WriteString(EmitStmts(w.Body, indent+1))
+	b.WriteString(g.emitStmts(w.Body, indent+1))
 	b.WriteString(indentStr(indent) + "}\n")
 
 	return b.String()
 }
 
-func emitCall(c *boogie.Call, indent int) string {
+// emitCall emits a call, assigning its results to c.Rets. Results are
+// unbounded (see ranges), so their variables use the representation of
+// parameters.
+func (g *emitter) emitCall(c *boogie.Call, indent int) string {
 	var args []string
+	if g.opts.State != StateGlobal {
+		args = append(args, "ctx")
+	}
 	for _, a := range c.Args {
-		args = append(args, EmitExpr(a))
+		args = append(args, g.emitAs(a, g.bigParams()))
 	}
 
-	return indentStr(indent) +
-		c.Name +
+	var lhs string
+	if len(c.Rets) > 0 {
+		rets := make([]string, len(c.Rets))
+		for i, v := range c.Rets {
+			rets[i] = GoName(v.Name)
+		}
+		lhs = strings.Join(rets, ", ") + " = "
+	}
+
+	return indentStr(indent) + lhs +
+		GoName(c.Name) + g.emitTypeArgs(c.TypeArgs) +
 		"(" + strings.Join(args, ", ") + ")\n"
 }
 
-func emitReturn(r *boogie.Return, indent int) string {
+func (g *emitter) emitReturn(r *boogie.Return, indent int) string {
 	if len(r.Values) == 0 {
 		return indentStr(indent) + "return\n"
 	}
 
 	var vals []string
 	for _, v := range r.Values {
-		vals = append(vals, EmitExpr(v))
+		vals = append(vals, g.emitAs(v, g.bigParams()))
 	}
 
 	return indentStr(indent) +
 		"return " + strings.Join(vals, ", ") + "\n"
 }
 
-func emitHeapWrite(h *boogie.HeapWrite, indent int) string {
-	obj := EmitExpr(h.Obj)
-	field := strconv.Quote(h.Field)
-	val := EmitExpr(h.Value)
+// checkFuncs names the runtime helper called when a Check fails.
+var checkFuncs = map[boogie.CheckAction]string{
+	boogie.CheckPanic:  "AssertPanic",
+	boogie.CheckLog:    "AssertLog",
+	boogie.CheckCount:  "AssertCount",
+	boogie.CheckAssume: "AssumeGuard",
+	boogie.CheckFilter: "AssumeFilter",
+}
+
+func (g *emitter) emitCheck(c *boogie.Check, indent int) string {
+	var b strings.Builder
 
-	// heapWrite(obj, "field", value)
-	return indentStr(indent) +
-		"heapWrite(" + obj + ", " + field + ", " + val + ")\n"
+	cond := g.emitExpr(c.Cond)
+	pos := strconv.Quote(c.Pos.String())
+	text := strconv.Quote(boogie.FormatExpr(c.Cond))
+
+	// if !cond { AssertPanic("file:3:5", "x > 0") }
+	b.WriteString(indentStr(indent) + "if !" + cond + " {\n")
+	b.WriteString(indentStr(indent+1) + checkFuncs[c.Action] + "(" + pos + ", " + text + ")\n")
+	b.WriteString(indentStr(indent) + "}\n")
+
+	return b.String()
+}
+
+// emitHavoc draws each variable from the runtime oracle. The site
+// string ("file:3:5 x") lets oracles tell choice points apart.
+func (g *emitter) emitHavoc(h *boogie.Havoc, indent int) string {
+	var b strings.Builder
+
+	for _, v := range h.Vars {
+		site := strconv.Quote(h.Pos.String() + " " + v.Name)
+		b.WriteString(indentStr(indent) + GoName(v.Name) + " = " + g.havocValue(v, site) + "\n")
+	}
+
+	return b.String()
+}
+
+// emitChoice emits a nondeterministic goto as a switch over the branch
+// the oracle picks among those whose guard holds.
+func (g *emitter) emitChoice(c *boogie.Choice, indent int) string {
+	var b strings.Builder
+
+	site := strconv.Quote(c.Pos.String() + " goto")
+	args := []string{site}
+	for _, guard := range c.Guards {
+		if guard == nil {
+			args = append(args, "true")
+		} else {
+			args = append(args, g.emitExpr(guard))
+		}
+	}
+
+	// switch ChooseBranch("file:3:5 goto", true, (x > 0)) { ... }
+	b.WriteString(indentStr(indent) + "switch ChooseBranch(" + strings.Join(args, ", ") + ") {\n")
+	for i, br := range c.Branches {
+		b.WriteString(indentStr(indent) + "case " + strconv.Itoa(i) + ":\n")
+		b.WriteString(g.emitStmts(br, indent+1))
+	}
+	b.WriteString(indentStr(indent) + "default:\n")
+	b.WriteString(indentStr(indent+1) + checkFuncs[c.Blocked] + "(" + site + ", " + strconv.Quote("no feasible goto target") + ")\n")
+	b.WriteString(indentStr(indent) + "}\n")
+
+	return b.String()
+}
+
+// havocValue emits the runtime call producing an arbitrary value for v.
+func (g *emitter) havocValue(v boogie.Var, site string) string {
+	// A havocked map is constant: one arbitrary value everywhere. A
+	// polymorphic map has no single value type, and is left empty.
+	if m, ok := v.Ty.(boogie.MapType); ok {
+		if len(m.TypeParams) > 0 {
+			return g.mapZero(m, "nil")
+		}
+		return g.mapZero(m, g.havocValue(valueVar(m.Result), site))
+	}
+	if bv, ok := v.Ty.(boogie.BvType); ok {
+		w := strconv.Itoa(bv.Width)
+		if bvBig(bv.Width) {
+			return "HavocBigBits(" + site + ", " + w + ")"
+		}
+		return bvGoType(bv.Width) + "(HavocBits(" + site + ", " + w + "))"
+	}
+	if _, ok := v.Ty.(boogie.CtorType); ok {
+		return g.goType(v) + "(HavocInt(" + site + "))"
+	}
+	return g.havocFunc(v) + "(" + site + ")"
+}
+
+// havocFunc names the runtime helper producing an arbitrary value for v.
+func (g *emitter) havocFunc(v boogie.Var) string {
+	switch v.Ty.(type) {
+	case boogie.BoolType:
+		return "HavocBool"
+	case boogie.RealType:
+		return "HavocRat"
+	case boogie.IntType:
+		switch {
+		case g.bigVar(v):
+			return "HavocBigInt"
+		case g.opts.Ints == IntNative:
+			return "HavocInt"
+		}
+		return "HavocInt64"
+	case boogie.RefType:
+		return "HavocInt"
+	default:
+		panic("unsupported havoc type in codegen")
+	}
 }
 
 // ========================
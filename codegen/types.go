@@ -0,0 +1,82 @@
+package codegen
+
+import (
+	"strings"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// Type parameters of procedures become Go type parameters, and calls
+// pass the instantiation the checker inferred explicitly. Generic code
+// cannot use Go operators on a type parameter's values, so comparisons
+// go through the runtime's ValueEq, and the Boogie zero of a type
+// parameter comes from ZeroOf.
+//
+// Declared types are opaque: a value of Field int is an identity, an
+// int whose Go type carries Field's arguments as phantom parameters,
+// so Field int and Field bool values are never mixed up.
+
+// EmitTypes emits a Go type for each type declaration of p.
+func EmitTypes(p *boogie.Program) string {
+	var b strings.Builder
+	for _, d := range p.Types {
+		b.WriteString("type " + GoName(d.Name))
+		if d.Arity > 0 {
+			b.WriteString("[" + strings.Repeat("_, ", d.Arity-1) + "_ any]")
+		}
+		b.WriteString(" int\n\n")
+	}
+	return b.String()
+}
+
+// typeParamName is the Go name of the Boogie type parameter name.
+func typeParamName(name string) string {
+	return "T_" + GoName(name)
+}
+
+// emitTypeParams emits the type parameter list of a generic procedure,
+// or "" if it has none.
+func emitTypeParams(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	ps := make([]string, len(names))
+	for i, n := range names {
+		ps[i] = typeParamName(n)
+	}
+	return "[" + strings.Join(ps, ", ") + " any]"
+}
+
+// emitTypeArgs emits the instantiation of a generic procedure at a
+// call, or "" if it is not generic.
+func (g *emitter) emitTypeArgs(ts []boogie.Type) string {
+	if len(ts) == 0 {
+		return ""
+	}
+	args := make([]string, len(ts))
+	for i, t := range ts {
+		args[i] = g.goType(valueVar(t))
+	}
+	return "[" + strings.Join(args, ", ") + "]"
+}
+
+func (g *emitter) ctorGoType(t boogie.CtorType) string {
+	if len(t.Args) == 0 {
+		return GoName(t.Name)
+	}
+	return GoName(t.Name) + g.emitTypeArgs(t.Args)
+}
+
+func isTypeVar(e boogie.Expr) bool {
+	_, ok := e.Type().(boogie.TypeVar)
+	return ok
+}
+
+// emitTypeVarEq emits == or != on values of a type parameter.
+func (g *emitter) emitTypeVarEq(b *boogie.BinOp) string {
+	eq := "ValueEq(" + g.emitExpr(b.Left) + ", " + g.emitExpr(b.Right) + ")"
+	if b.Op == boogie.Neq {
+		return "!" + eq
+	}
+	return eq
+}
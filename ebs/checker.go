@@ -2,20 +2,39 @@ package ebs
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/ezrantn/boogo/boogie"
 )
 
 func Check(p *boogie.Program) error {
+	funcs := make(map[string]bool)
+	for _, f := range p.Funcs {
+		funcs[f.Name] = true
+		if err := checkFunction(f); err != nil {
+			return fmt.Errorf("function %s: %w", f.Name, err)
+		}
+	}
+
 	procMap := make(map[string]*boogie.Procedure)
 	for _, proc := range p.Procs {
 		if _, ok := procMap[proc.Name]; ok {
 			return fmt.Errorf("duplicate procedure: %s", proc.Name)
 		}
+		if _, ok := funcs[proc.Name]; ok {
+			return fmt.Errorf("procedure %s: a function has the same name", proc.Name)
+		}
+		if proc.Name == "New" {
+			return fmt.Errorf("procedure New: New is the built-in allocator, call o := New()")
+		}
 
 		procMap[proc.Name] = proc
 	}
 
+	if err := checkHeap(p); err != nil {
+		return err
+	}
+
 	for _, proc := range p.Procs {
 		if err := checkProcedure(proc, procMap); err != nil {
 			return fmt.Errorf("procedure %s: %w", proc.Name, err)
@@ -25,6 +44,165 @@ func Check(p *boogie.Program) error {
 	return nil
 }
 
+// ========================
+// Function Checking
+// ========================
+
+// checkFunction accepts the functions EBS v1 can execute: those with a
+// pure body of the result type, those bound to Go with {:extern}, and
+// those bound to a bitvector operation with {:bvbuiltin}, at a
+// matching signature.
+func checkFunction(f *boogie.Function) error {
+	if err := checkMemoize(f); err != nil {
+		return err
+	}
+	if f.Body != nil {
+		if _, ok := boogie.FindAttr(f.Attrs, "extern"); ok {
+			return fmt.Errorf("%v: a function with a body cannot be {:extern}", f.Pos)
+		}
+		return checkFunctionBody(f)
+	}
+
+	if _, ok := boogie.FindAttr(f.Attrs, "extern"); ok {
+		return checkExtern(f)
+	}
+	attr, ok := boogie.FindAttr(f.Attrs, "bvbuiltin")
+	if !ok {
+		return fmt.Errorf("%v: uninterpreted function has no binding; give it a body, bind it to Go with {:extern \"import/path.Func\"}, or to a bitvector operation with {:bvbuiltin}", f.Pos)
+	}
+	if len(f.TypeParams) > 0 {
+		return fmt.Errorf("%v: {:bvbuiltin} functions cannot have type parameters", f.Pos)
+	}
+	if len(attr.Args) != 1 {
+		return fmt.Errorf("%v: {:bvbuiltin} takes exactly one operation name", f.Pos)
+	}
+	b, err := boogie.ParseBvBuiltin(attr.Args[0])
+	if err != nil {
+		return fmt.Errorf("%v: %w", f.Pos, err)
+	}
+
+	if len(f.Params) != b.Arity() {
+		return fmt.Errorf("%v: %s takes %d arguments, got %d", f.Pos, b.Op, b.Arity(), len(f.Params))
+	}
+	bv, ok := f.Params[0].Ty.(boogie.BvType)
+	if !ok {
+		return fmt.Errorf("%v: %s needs bitvector arguments, got %v", f.Pos, b.Op, f.Params[0].Ty)
+	}
+	for _, p := range f.Params[1:] {
+		if !sameType(p.Ty, bv) {
+			return fmt.Errorf("%v: %s needs arguments of one type, got %v and %v", f.Pos, b.Op, bv, p.Ty)
+		}
+	}
+	if want := b.ResultType(bv.Width); !sameType(f.Ret, want) {
+		return fmt.Errorf("%v: %s on %v returns %v, not %v", f.Pos, b.Op, bv, want, f.Ret)
+	}
+
+	return nil
+}
+
+// checkFunctionBody checks a function with a body, which must be pure:
+// it reads nothing but its parameters, and so can run as a Go function
+// of them.
+func checkFunctionBody(f *boogie.Function) error {
+	if _, ok := boogie.FindAttr(f.Attrs, "bvbuiltin"); ok {
+		return fmt.Errorf("%v: a {:bvbuiltin} function cannot have a body", f.Pos)
+	}
+	// Each application instantiates the type parameters from its
+	// arguments.
+	for _, tp := range f.TypeParams {
+		if !mentions(f.Params, tp) {
+			return fmt.Errorf("%v: type parameter %s does not occur in the parameters, so no application could instantiate it", f.Pos, tp)
+		}
+	}
+	for _, v := range f.Params {
+		if err := checkVarType(v.Ty); err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+	}
+	if err := checkVarType(f.Ret); err != nil {
+		return fmt.Errorf("result: %w", err)
+	}
+
+	var err error
+	boogie.WalkExpr(f.Body, func(e boogie.Expr) {
+		if err != nil {
+			return
+		}
+		switch ex := e.(type) {
+		case *boogie.HeapRead:
+			err = fmt.Errorf("%v: function bodies must be pure, but this one reads the heap", ex.Pos)
+		case *boogie.FuncApp:
+			if ex.Func == f {
+				err = fmt.Errorf("%v: recursive application is not allowed in EBS v1", ex.Pos)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := checkExpr(f.Body); err != nil {
+		return err
+	}
+	if !sameType(f.Body.Type(), f.Ret) {
+		return fmt.Errorf("%v: body has type %v, but the function returns %v", f.Pos, f.Body.Type(), f.Ret)
+	}
+	return nil
+}
+
+// checkExtern checks a function bound to Go with {:extern}. The Go
+// compiler checks the Go function against the signature it is called
+// at (see codegen.EmitFunc).
+func checkExtern(f *boogie.Function) error {
+	if err := checkExternAttr(f.Attrs); err != nil {
+		return fmt.Errorf("%v: %w", f.Pos, err)
+	}
+	if _, ok := boogie.FindAttr(f.Attrs, "bvbuiltin"); ok {
+		return fmt.Errorf("%v: a function cannot be both {:extern} and {:bvbuiltin}", f.Pos)
+	}
+	if len(f.TypeParams) > 0 {
+		return fmt.Errorf("%v: {:extern} functions cannot have type parameters", f.Pos)
+	}
+	for _, v := range f.Params {
+		if err := checkVarType(v.Ty); err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+	}
+	if err := checkVarType(f.Ret); err != nil {
+		return fmt.Errorf("result: %w", err)
+	}
+	return nil
+}
+
+// checkExternAttr validates an {:extern "import/path.Func"} attribute.
+func checkExternAttr(attrs []boogie.Attr) error {
+	attr, _ := boogie.FindAttr(attrs, "extern")
+	if len(attr.Args) != 1 {
+		return fmt.Errorf("{:extern} takes exactly one Go function, as in {:extern \"math/bits.Reverse8\"}")
+	}
+	_, err := boogie.ParseExtern(attr.Args[0])
+	return err
+}
+
+// checkMemoize validates a {:memoize} attribute, which only a function
+// with a body, or bound to Go, can carry.
+func checkMemoize(f *boogie.Function) error {
+	attr, ok := boogie.FindAttr(f.Attrs, "memoize")
+	if !ok {
+		return nil
+	}
+	if len(attr.Args) > 0 {
+		return fmt.Errorf("%v: {:memoize} takes no arguments", f.Pos)
+	}
+	if _, extern := boogie.FindAttr(f.Attrs, "extern"); f.Body == nil && !extern {
+		return fmt.Errorf("%v: {:memoize} needs a function with a body or an {:extern} binding", f.Pos)
+	}
+	if len(f.TypeParams) > 0 {
+		return fmt.Errorf("%v: {:memoize} functions cannot have type parameters, since Go has no generic variables to hold their cache", f.Pos)
+	}
+	return nil
+}
+
 // ========================
 // Procedure Checking
 // ========================
@@ -34,6 +212,43 @@ func checkProcedure(
 	procMap map[string]*boogie.Procedure,
 ) error {
 
+	// A procedure declared without a body runs as the Go function it
+	// is bound to, whose signature the Go compiler checks (see
+	// codegen.EmitProc).
+	_, extern := boogie.FindAttr(proc.Attrs, "extern")
+	switch {
+	case proc.Bodiless && !extern:
+		return fmt.Errorf("procedure has no body; bind it to Go with {:extern \"import/path.Func\"}")
+	case extern && !proc.Bodiless:
+		return fmt.Errorf("a procedure with a body cannot be {:extern}")
+	case extern:
+		if err := checkExternAttr(proc.Attrs); err != nil {
+			return err
+		}
+		if len(proc.TypeParams) > 0 {
+			return fmt.Errorf("{:extern} procedures cannot have type parameters")
+		}
+	}
+
+	for _, vars := range [][]boogie.Var{proc.Params, proc.Rets, proc.Locals} {
+		for _, v := range vars {
+			if err := checkVarType(v.Ty); err != nil {
+				return fmt.Errorf("%s: %w", v.Name, err)
+			}
+		}
+	}
+
+	// Each call instantiates the type parameters from its arguments
+	// and the variables its results are assigned to.
+	if proc.Name == "main" && len(proc.TypeParams) > 0 {
+		return fmt.Errorf("the entry point cannot have type parameters")
+	}
+	for _, tp := range proc.TypeParams {
+		if !mentions(proc.Params, tp) && !mentions(proc.Rets, tp) {
+			return fmt.Errorf("type parameter %s does not occur in the parameters or results, so no call could instantiate it", tp)
+		}
+	}
+
 	// Reject recursion (direct)
 	for _, stmt := range proc.Body {
 		if callsSelf(stmt, proc.Name) {
@@ -51,6 +266,43 @@ func checkProcedure(
 	return nil
 }
 
+// checkVarType rejects the map types EBS v1 cannot execute. Maps are
+// keyed by scalar values, not by other maps, nor by values of a type
+// parameter: Go compares the *big.Int and *big.Rat behind ints and
+// reals by pointer, so a generic map could not find its entries again.
+// The type parameters of a polymorphic map must occur in its argument
+// types, from which each select instantiates them.
+func checkVarType(t boogie.Type) error {
+	switch t := t.(type) {
+	case boogie.CtorType:
+		for _, a := range t.Args {
+			if err := checkVarType(a); err != nil {
+				return err
+			}
+		}
+
+	case boogie.MapType:
+		for _, a := range t.Args {
+			switch a.(type) {
+			case boogie.MapType:
+				return fmt.Errorf("map %v: maps cannot be map arguments", t)
+			case boogie.TypeVar:
+				return fmt.Errorf("map %v: type parameter %v cannot be a map argument, since Go generics cannot hash its values by content; wrap it in a declared type such as Field %v", t, a, a)
+			}
+			if err := checkVarType(a); err != nil {
+				return err
+			}
+		}
+		for _, tp := range t.TypeParams {
+			if !slices.ContainsFunc(t.Args, func(a boogie.Type) bool { return boogie.Mentions(a, tp) }) {
+				return fmt.Errorf("map %v: type parameter %s does not occur in the argument types", t, tp)
+			}
+		}
+		return checkVarType(t.Result)
+	}
+	return nil
+}
+
 // ========================
 // Statement Checking
 // ========================
@@ -82,6 +334,24 @@ func checkStmt(
 		}
 		return nil
 
+	case *boogie.Choice:
+		for i, g := range st.Guards {
+			if g == nil {
+				continue
+			}
+			if err := checkExprBool(g); err != nil {
+				return fmt.Errorf("goto guard %d: %w", i, err)
+			}
+		}
+		for _, br := range st.Branches {
+			for _, b := range br {
+				if err := checkStmt(b, proc, procMap); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+
 	case *boogie.While:
 		if err := checkExprBool(st.Cond); err != nil {
 			return fmt.Errorf("while condition: %w", err)
@@ -97,6 +367,11 @@ func checkStmt(
 		return checkCall(st, procMap)
 
 	case *boogie.Return:
+		// A bare return yields the current out-parameters.
+		if len(st.Values) == 0 {
+			return nil
+		}
+
 		if len(st.Values) != len(proc.Rets) {
 			return fmt.Errorf("return arity mismatch: expected %d values, got %d", len(proc.Rets), len(st.Values))
 		}
@@ -114,11 +389,45 @@ func checkStmt(
 
 	case *boogie.Assert:
 		// Verification-only, but must be well-typed
+		if err := checkModeAttr(st.Attrs, st.Pos, func(m string) error {
+			_, err := ParseAssertMode(m)
+			return err
+		}); err != nil {
+			return err
+		}
+		return checkExprBool(st.Cond)
+
+	case *boogie.Assume:
+		// Executed as a guard or filter (see EraseWith)
+		if err := checkModeAttr(st.Attrs, st.Pos, func(m string) error {
+			_, err := ParseAssumeMode(m)
+			return err
+		}); err != nil {
+			return err
+		}
+		return checkExprBool(st.Cond)
+
+	case *boogie.Check:
 		return checkExprBool(st.Cond)
 
 	case *boogie.HeapWrite:
 		return checkHeapWrite(st)
 
+	case *boogie.Alloc:
+		return requireType(st.V.Ty, boogie.RefType{})
+
+	case *boogie.Havoc:
+		for _, v := range st.Vars {
+			switch v.Ty.(type) {
+			case boogie.IntType, boogie.RealType, boogie.BoolType, boogie.RefType, boogie.BvType, boogie.MapType, boogie.CtorType:
+			case boogie.TypeVar:
+				return fmt.Errorf("%v: cannot havoc %s of type parameter %v: Go generic code has no way to make up a value of an unknown type", st.Pos, v.Name, v.Ty)
+			default:
+				return fmt.Errorf("%v: cannot havoc %s of type %T", st.Pos, v.Name, v.Ty)
+			}
+		}
+		return nil
+
 	case *boogie.HeapRead:
 		return fmt.Errorf("heap read cannot be used as a statement")
 
@@ -143,6 +452,31 @@ func checkExpr(e boogie.Expr) error {
 	case *boogie.BoolLit:
 		return nil
 
+	case *boogie.RealLit:
+		return nil
+
+	case *boogie.Convert:
+		if err := checkExpr(ex.X); err != nil {
+			return err
+		}
+		// real(i) takes an int, int(r) a real.
+		if _, ok := ex.To.(boogie.RealType); ok {
+			return requireInt(ex.X)
+		}
+		return requireReal(ex.X)
+
+	case *boogie.BvLit:
+		return nil
+
+	case *boogie.BvExtract:
+		if err := checkExpr(ex.X); err != nil {
+			return err
+		}
+		return checkExtract(ex)
+
+	case *boogie.FuncApp:
+		return checkFuncApp(ex)
+
 	case *boogie.BinOp:
 		if err := checkExpr(ex.Left); err != nil {
 			return err
@@ -158,6 +492,41 @@ func checkExpr(e boogie.Expr) error {
 		}
 		return checkUnOp(ex)
 
+	case *boogie.IfThenElse:
+		if err := checkExprBool(ex.Cond); err != nil {
+			return err
+		}
+		if err := checkExpr(ex.Then); err != nil {
+			return err
+		}
+		if err := checkExpr(ex.Else); err != nil {
+			return err
+		}
+		if !sameType(ex.Then.Type(), ex.Else.Type()) {
+			return fmt.Errorf("%v: branches of if-then-else have different types %v and %v", ex.Pos, ex.Then.Type(), ex.Else.Type())
+		}
+		return nil
+
+	case *boogie.MapSelect:
+		_, err := checkMapAccess(ex.Map, ex.Args, ex.Pos)
+		return err
+
+	case *boogie.MapStore:
+		want, err := checkMapAccess(ex.Map, ex.Args, ex.Pos)
+		if err != nil {
+			return err
+		}
+		if err := checkExpr(ex.Value); err != nil {
+			return err
+		}
+		if !sameType(ex.Value.Type(), want) {
+			return fmt.Errorf("%v: cannot store %v in %v", ex.Pos, ex.Value.Type(), ex.Map.Type())
+		}
+		return nil
+
+	case *boogie.NullLit:
+		return nil
+
 	case *boogie.HeapRead:
 		return checkHeapRead(ex)
 
@@ -204,11 +573,12 @@ func checkCall(c *boogie.Call, procMap map[string]*boogie.Procedure) error {
 		return fmt.Errorf("arity mismatch in call to %s", c.Name)
 	}
 
+	subst := make(map[string]boogie.Type)
 	for i, arg := range c.Args {
 		if err := checkExpr(arg); err != nil {
 			return err
 		}
-		if !sameType(arg.Type(), target.Params[i].Ty) {
+		if !boogie.Unify(target.Params[i].Ty, arg.Type(), target.TypeParams, subst) {
 			return fmt.Errorf("argument %d type mismatch in call to %s", i, c.Name)
 		}
 	}
@@ -217,12 +587,72 @@ func checkCall(c *boogie.Call, procMap map[string]*boogie.Procedure) error {
 		return fmt.Errorf("return arity mismatch in call to %s", c.Name)
 	}
 
+	for i, v := range c.Rets {
+		if !boogie.Unify(target.Rets[i].Ty, v.Ty, target.TypeParams, subst) {
+			return fmt.Errorf("result %d type mismatch in call to %s: %s has type %v", i, c.Name, v.Name, v.Ty)
+		}
+	}
+
+	return inferTypeArgs(c, target, subst)
+}
+
+// inferTypeArgs records in c.TypeArgs how c instantiates the type
+// parameters of target, as bound by matching its arguments and results.
+// The generated Go code passes them explicitly.
+func inferTypeArgs(c *boogie.Call, target *boogie.Procedure, subst map[string]boogie.Type) error {
+	c.TypeArgs = nil
+	for _, tp := range target.TypeParams {
+		t, ok := subst[tp]
+		if !ok {
+			return fmt.Errorf("%v: cannot infer type parameter %s of %s", c.Pos, tp, c.Name)
+		}
+		// Generic code may compare values of a type parameter, which
+		// maps do not support.
+		if _, ok := t.(boogie.MapType); ok {
+			return fmt.Errorf("%v: type parameter %s of %s cannot be instantiated with the map type %v", c.Pos, tp, c.Name, t)
+		}
+		c.TypeArgs = append(c.TypeArgs, t)
+	}
+	return nil
+}
+
+// checkModeAttr validates a {:boogo "<mode>"} attribute, using parse
+// to recognise the modes valid for the annotated statement.
+func checkModeAttr(attrs []boogie.Attr, pos boogie.Pos, parse func(string) error) error {
+	attr, ok := boogie.FindAttr(attrs, "boogo")
+	if !ok {
+		return nil
+	}
+	if len(attr.Args) != 1 {
+		return fmt.Errorf("%v: {:boogo} takes exactly one mode", pos)
+	}
+	if err := parse(attr.Args[0]); err != nil {
+		return fmt.Errorf("%v: %w", pos, err)
+	}
 	return nil
 }
 
 func checkBinOp(b *boogie.BinOp) error {
 	switch b.Op {
 	case boogie.Add, boogie.Sub, boogie.Mul:
+		if err := requireNumeric(b.Left, b.Right); err != nil {
+			return err
+		}
+
+		return requireType(b.Ty, b.Left.Type())
+
+	case boogie.Div:
+		if err := requireReal(b.Left); err != nil {
+			return err
+		}
+
+		if err := requireReal(b.Right); err != nil {
+			return err
+		}
+
+		return requireType(b.Ty, boogie.RealType{})
+
+	case boogie.IntDiv, boogie.Mod:
 		if err := requireInt(b.Left); err != nil {
 			return err
 		}
@@ -233,14 +663,37 @@ func checkBinOp(b *boogie.BinOp) error {
 
 		return requireType(b.Ty, boogie.IntType{})
 
-	case boogie.Eq, boogie.Lt, boogie.Lte:
+	case boogie.Eq, boogie.Neq:
 		if !sameType(b.Left.Type(), b.Right.Type()) {
 			return fmt.Errorf("binary op operands must have same type")
 		}
 
+		// Extensional equality would compare every point of the
+		// functions; running code cannot decide it.
+		if _, ok := b.Left.Type().(boogie.MapType); ok {
+			return fmt.Errorf("%v: maps cannot be compared with %v", b.Pos, b.Op)
+		}
+
 		return requireType(b.Ty, boogie.BoolType{})
 
-	case boogie.And, boogie.Or:
+	case boogie.Lt, boogie.Lte, boogie.Gt, boogie.Gte:
+		// Bitvectors are compared with bvult, bvslt, ... instead.
+		if err := requireNumeric(b.Left, b.Right); err != nil {
+			return err
+		}
+
+		return requireType(b.Ty, boogie.BoolType{})
+
+	case boogie.Concat:
+		l, lok := b.Left.Type().(boogie.BvType)
+		r, rok := b.Right.Type().(boogie.BvType)
+		if !lok || !rok {
+			return fmt.Errorf("++ needs bitvector operands, got %v and %v", b.Left.Type(), b.Right.Type())
+		}
+
+		return requireType(b.Ty, boogie.BvType{Width: l.Width + r.Width})
+
+	case boogie.And, boogie.Or, boogie.Implies, boogie.Explies, boogie.Iff:
 		if err := requireBool(b.Left); err != nil {
 			return err
 		}
@@ -259,18 +712,86 @@ func checkBinOp(b *boogie.BinOp) error {
 func checkUnOp(u *boogie.UnOp) error {
 	switch u.Op {
 	case boogie.Not:
-		requireBool(u.X)
-		requireType(u.Ty, boogie.BoolType{})
-		return nil
+		if err := requireBool(u.X); err != nil {
+			return err
+		}
+
+		return requireType(u.Ty, boogie.BoolType{})
+
 	case boogie.Neg:
-		requireInt(u.X)
-		requireType(u.Ty, boogie.IntType{})
-		return nil
+		if err := requireNumeric(u.X, u.X); err != nil {
+			return err
+		}
+
+		return requireType(u.Ty, u.X.Type())
+
 	default:
 		return fmt.Errorf("unsupported unary operator")
 	}
 }
 
+// checkMapAccess checks the map and arguments of a select or update,
+// and returns the type of the selected value. The arguments of a
+// polymorphic map instantiate its type parameters.
+func checkMapAccess(m boogie.Expr, args []boogie.Expr, pos boogie.Pos) (boogie.Type, error) {
+	if err := checkExpr(m); err != nil {
+		return nil, err
+	}
+	t, ok := m.Type().(boogie.MapType)
+	if !ok {
+		return nil, fmt.Errorf("%v: cannot index %v", pos, m.Type())
+	}
+	if len(args) != len(t.Args) {
+		return nil, fmt.Errorf("%v: %v takes %d arguments, got %d", pos, t, len(t.Args), len(args))
+	}
+	subst := make(map[string]boogie.Type)
+	for i, a := range args {
+		if err := checkExpr(a); err != nil {
+			return nil, err
+		}
+		if !boogie.Unify(t.Args[i], a.Type(), t.TypeParams, subst) {
+			return nil, fmt.Errorf("%v: argument %d of %v: expected %v, got %v", pos, i, t, t.Args[i], a.Type())
+		}
+	}
+	return boogie.Subst(t.Result, subst), nil
+}
+
+func checkExtract(e *boogie.BvExtract) error {
+	bv, ok := e.X.Type().(boogie.BvType)
+	if !ok {
+		return fmt.Errorf("cannot extract bits from %v", e.X.Type())
+	}
+	if e.Lo < 0 || e.Hi <= e.Lo || e.Hi > bv.Width {
+		return fmt.Errorf("extraction [%d:%d] out of range for %v", e.Hi, e.Lo, bv)
+	}
+	return nil
+}
+
+// checkFuncApp checks an application, whose arguments instantiate the
+// type parameters of a generic function.
+func checkFuncApp(f *boogie.FuncApp) error {
+	if len(f.Args) != len(f.Func.Params) {
+		return fmt.Errorf("%v: %s takes %d arguments, got %d", f.Pos, f.Func.Name, len(f.Func.Params), len(f.Args))
+	}
+	subst := make(map[string]boogie.Type)
+	for i, a := range f.Args {
+		if err := checkExpr(a); err != nil {
+			return err
+		}
+		if !boogie.Unify(f.Func.Params[i].Ty, a.Type(), f.Func.TypeParams, subst) {
+			return fmt.Errorf("%v: argument %d of %s: expected %v, got %v", f.Pos, i, f.Func.Name, boogie.Subst(f.Func.Params[i].Ty, subst), a.Type())
+		}
+	}
+	for _, tp := range f.Func.TypeParams {
+		// As for calls (see inferTypeArgs): generic code may compare
+		// values of a type parameter, which maps do not support.
+		if t, ok := subst[tp].(boogie.MapType); ok {
+			return fmt.Errorf("%v: type parameter %s of %s cannot be instantiated with the map type %v", f.Pos, tp, f.Func.Name, t)
+		}
+	}
+	return nil
+}
+
 func checkHeapRead(h *boogie.HeapRead) error {
 	if err := checkExpr(h.Obj); err != nil {
 		return err
@@ -300,13 +821,51 @@ func checkHeapWrite(h *boogie.HeapWrite) error {
 // Utilities
 // ========================
 
+// mentions reports whether the type parameter tp occurs in the type of
+// one of vars.
+func mentions(vars []boogie.Var, tp string) bool {
+	for _, v := range vars {
+		if boogie.Mentions(v.Ty, tp) {
+			return true
+		}
+	}
+	return false
+}
+
 func sameType(a, b boogie.Type) bool {
-	return fmt.Sprintf("%T", a) == fmt.Sprintf("%T", b)
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.String() == b.String()
 }
 
 func requireInt(e boogie.Expr) error {
 	if _, ok := e.Type().(boogie.IntType); !ok {
-		return fmt.Errorf("expected int expression, got %T", e.Type())
+		return fmt.Errorf("expected int expression, got %v", e.Type())
+	}
+
+	return nil
+}
+
+func requireReal(e boogie.Expr) error {
+	if _, ok := e.Type().(boogie.RealType); !ok {
+		return fmt.Errorf("expected real expression, got %v", e.Type())
+	}
+
+	return nil
+}
+
+// requireNumeric accepts two ints or two reals; Boogie never mixes
+// them implicitly.
+func requireNumeric(l, r boogie.Expr) error {
+	switch l.Type().(type) {
+	case boogie.IntType, boogie.RealType:
+	default:
+		return fmt.Errorf("expected int or real expression, got %v", l.Type())
+	}
+
+	if !sameType(l.Type(), r.Type()) {
+		return fmt.Errorf("operands must have the same type, got %v and %v", l.Type(), r.Type())
 	}
 
 	return nil
@@ -314,7 +873,7 @@ func requireInt(e boogie.Expr) error {
 
 func requireBool(e boogie.Expr) error {
 	if _, ok := e.Type().(boogie.BoolType); !ok {
-		return fmt.Errorf("expected bool expression, got %T", e.Type())
+		return fmt.Errorf("expected bool expression, got %v", e.Type())
 	}
 
 	return nil
@@ -349,6 +908,14 @@ func callsSelf(s boogie.Stmt, name string) bool {
 				return true
 			}
 		}
+	case *boogie.Choice:
+		for _, br := range st.Branches {
+			for _, b := range br {
+				if callsSelf(b, name) {
+					return true
+				}
+			}
+		}
 	}
 
 	return false
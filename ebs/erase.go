@@ -1,50 +1,181 @@
 package ebs
 
-import "github.com/ezrantn/boogo/boogie"
+import (
+	"fmt"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// AssertMode selects what erasure does with an assertion.
+type AssertMode int
+
+const (
+	AssertErase AssertMode = iota // drop it (verified code)
+	AssertPanic                   // check it; a failure panics
+	AssertLog                     // check it; a failure is logged and execution continues
+	AssertCount                   // check it; failures are counted and reported at exit
+)
+
+var assertModeNames = map[string]AssertMode{
+	"erase": AssertErase,
+	"panic": AssertPanic,
+	"log":   AssertLog,
+	"count": AssertCount,
+}
+
+func (m AssertMode) String() string {
+	for name, mode := range assertModeNames {
+		if mode == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("AssertMode(%d)", int(m))
+}
+
+// ParseAssertMode maps a mode name ("erase", "panic", "log", "count")
+// to its AssertMode.
+func ParseAssertMode(name string) (AssertMode, error) {
+	if m, ok := assertModeNames[name]; ok {
+		return m, nil
+	}
+	return 0, fmt.Errorf("unknown assertion mode %q (want erase, panic, log or count)", name)
+}
+
+// AssumeMode selects how an assumption behaves at runtime. Assumptions
+// are never erased: executing past a false one would leave the states
+// the program was verified for.
+type AssumeMode int
+
+const (
+	AssumeGuard  AssumeMode = iota // abort the path, reporting "assumption violated"
+	AssumeFilter                   // silently discard the path (test generation, exploration)
+)
+
+var assumeModeNames = map[string]AssumeMode{
+	"guard":  AssumeGuard,
+	"filter": AssumeFilter,
+}
+
+func (m AssumeMode) String() string {
+	for name, mode := range assumeModeNames {
+		if mode == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("AssumeMode(%d)", int(m))
+}
+
+// ParseAssumeMode maps a mode name ("guard", "filter") to its AssumeMode.
+func ParseAssumeMode(name string) (AssumeMode, error) {
+	if m, ok := assumeModeNames[name]; ok {
+		return m, nil
+	}
+	return 0, fmt.Errorf("unknown assumption mode %q (want guard or filter)", name)
+}
+
+// Policy controls which verification-only constructs survive erasure.
+// The zero Policy erases assertions and guards assumptions.
+type Policy struct {
+	// Asserts is the program-wide assertion mode. An individual
+	// assertion can override it with {:boogo "<mode>"}.
+	Asserts AssertMode
+
+	// Assumes is the program-wide assumption mode, likewise
+	// overridable per assumption.
+	Assumes AssumeMode
+}
 
 // Erase removes verification-only constructs from a program,
 // yielding an executable EBS program.
 func Erase(p *boogie.Program) *boogie.Program {
-	out := &boogie.Program{}
+	return EraseWith(p, Policy{})
+}
+
+// EraseWith is like Erase, but keeps assertions as runtime checks
+// where pol (or a per-assertion attribute) asks for it, and turns
+// assumptions into guards or filters as pol says.
+// Attributes are assumed valid; Check reports malformed ones.
+func EraseWith(p *boogie.Program, pol Policy) *boogie.Program {
+	out := &boogie.Program{Types: p.Types, Fields: p.Fields, Funcs: p.Funcs}
 
 	for _, proc := range p.Procs {
-		out.Procs = append(out.Procs, eraseProc(proc))
+		out.Procs = append(out.Procs, eraseProc(proc, pol))
 	}
 
 	return out
 }
 
-func eraseProc(p *boogie.Procedure) *boogie.Procedure {
+func eraseProc(p *boogie.Procedure, pol Policy) *boogie.Procedure {
 	np := &boogie.Procedure{
-		Name:   p.Name,
-		Params: p.Params,
-		Rets:   p.Rets,
-		Locals: p.Locals,
-		Body:   eraseStmts(p.Body),
+		Name:       p.Name,
+		TypeParams: p.TypeParams,
+		Params:     p.Params,
+		Rets:       p.Rets,
+		Locals:     p.Locals,
+		Body:       eraseStmts(p.Body, pol),
+		Bodiless:   p.Bodiless,
+		Attrs:      p.Attrs,
 	}
 	return np
 }
 
-func eraseStmts(stmts []boogie.Stmt) []boogie.Stmt {
+func eraseStmts(stmts []boogie.Stmt, pol Policy) []boogie.Stmt {
 	var out []boogie.Stmt
 	for _, s := range stmts {
 		switch st := s.(type) {
 
 		case *boogie.Assert:
-			// erase verification-only assertion
-			continue
+			mode := assertMode(st, pol)
+			if mode == AssertErase {
+				// erase verification-only assertion
+				continue
+			}
+			out = append(out, &boogie.Check{
+				Cond:   st.Cond,
+				Action: checkAction(mode),
+				Pos:    st.Pos,
+			})
+
+		case *boogie.Assume:
+			action := boogie.CheckAssume
+			if assumeMode(st, pol) == AssumeFilter {
+				action = boogie.CheckFilter
+			}
+			out = append(out, &boogie.Check{
+				Cond:   st.Cond,
+				Action: action,
+				Pos:    st.Pos,
+			})
 
 		case *boogie.If:
 			out = append(out, &boogie.If{
 				Cond: st.Cond,
-				Then: eraseStmts(st.Then),
-				Else: eraseStmts(st.Else),
+				Then: eraseStmts(st.Then, pol),
+				Else: eraseStmts(st.Else, pol),
+			})
+
+		case *boogie.Choice:
+			// A choice with no enabled branch failed its guarding
+			// assumes, so it is reported like one.
+			blocked := boogie.CheckAssume
+			if pol.Assumes == AssumeFilter {
+				blocked = boogie.CheckFilter
+			}
+			branches := make([][]boogie.Stmt, len(st.Branches))
+			for i, br := range st.Branches {
+				branches[i] = eraseStmts(br, pol)
+			}
+			out = append(out, &boogie.Choice{
+				Guards:   st.Guards,
+				Branches: branches,
+				Blocked:  blocked,
+				Pos:      st.Pos,
 			})
 
 		case *boogie.While:
 			out = append(out, &boogie.While{
 				Cond: st.Cond,
-				Body: eraseStmts(st.Body),
+				Body: eraseStmts(st.Body, pol),
 			})
 
 		default:
@@ -54,3 +185,34 @@ func eraseStmts(stmts []boogie.Stmt) []boogie.Stmt {
 	}
 	return out
 }
+
+// assertMode resolves the mode for a, letting {:boogo ...} override pol.
+func assertMode(a *boogie.Assert, pol Policy) AssertMode {
+	if attr, ok := boogie.FindAttr(a.Attrs, "boogo"); ok && len(attr.Args) == 1 {
+		if m, err := ParseAssertMode(attr.Args[0]); err == nil {
+			return m
+		}
+	}
+	return pol.Asserts
+}
+
+// assumeMode resolves the mode for a, letting {:boogo ...} override pol.
+func assumeMode(a *boogie.Assume, pol Policy) AssumeMode {
+	if attr, ok := boogie.FindAttr(a.Attrs, "boogo"); ok && len(attr.Args) == 1 {
+		if m, err := ParseAssumeMode(attr.Args[0]); err == nil {
+			return m
+		}
+	}
+	return pol.Assumes
+}
+
+func checkAction(m AssertMode) boogie.CheckAction {
+	switch m {
+	case AssertLog:
+		return boogie.CheckLog
+	case AssertCount:
+		return boogie.CheckCount
+	default:
+		return boogie.CheckPanic
+	}
+}
@@ -0,0 +1,113 @@
+package ebs
+
+import (
+	"testing"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+func assertProg(attrs ...boogie.Attr) *boogie.Program {
+	return &boogie.Program{
+		Procs: []*boogie.Procedure{
+			{
+				Name: "main",
+				Body: []boogie.Stmt{
+					&boogie.Assert{
+						Cond:  &boogie.BoolLit{Value: true},
+						Attrs: attrs,
+						Pos:   boogie.Pos{Line: 3, Col: 5},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestEraseWithKeepsAssertAsCheck(t *testing.T) {
+	e := EraseWith(assertProg(), Policy{Asserts: AssertLog})
+
+	if len(e.Procs[0].Body) != 1 {
+		t.Fatalf("assert erased under log policy")
+	}
+
+	c, ok := e.Procs[0].Body[0].(*boogie.Check)
+	if !ok {
+		t.Fatalf("expected Check, got %T", e.Procs[0].Body[0])
+	}
+
+	if c.Action != boogie.CheckLog {
+		t.Fatalf("expected log action, got %v", c.Action)
+	}
+
+	if c.Pos.Line != 3 || c.Pos.Col != 5 {
+		t.Fatalf("position not preserved: %v", c.Pos)
+	}
+}
+
+func TestEraseWithAttributeOverridesPolicy(t *testing.T) {
+	p := assertProg(boogie.Attr{Name: "boogo", Args: []string{"count"}})
+
+	e := EraseWith(p, Policy{Asserts: AssertPanic})
+	c, ok := e.Procs[0].Body[0].(*boogie.Check)
+	if !ok || c.Action != boogie.CheckCount {
+		t.Fatalf("expected counted check, got %#v", e.Procs[0].Body[0])
+	}
+
+	p = assertProg(boogie.Attr{Name: "boogo", Args: []string{"erase"}})
+	if e := EraseWith(p, Policy{Asserts: AssertPanic}); len(e.Procs[0].Body) != 0 {
+		t.Fatalf("{:boogo \"erase\"} assertion was kept")
+	}
+}
+
+// ❌ Unknown assertion mode
+func TestRejectUnknownAssertMode(t *testing.T) {
+	mustReject(t, assertProg(boogie.Attr{Name: "boogo", Args: []string{"shout"}}))
+}
+
+func assumeProg(attrs ...boogie.Attr) *boogie.Program {
+	return &boogie.Program{
+		Procs: []*boogie.Procedure{
+			{
+				Name: "main",
+				Body: []boogie.Stmt{
+					&boogie.Assume{
+						Cond:  &boogie.BoolLit{Value: true},
+						Attrs: attrs,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestCheckAcceptsAssume(t *testing.T) {
+	if err := Check(assumeProg()); err != nil {
+		t.Fatalf("assume rejected: %v", err)
+	}
+}
+
+func TestEraseGuardsAssumeByDefault(t *testing.T) {
+	e := Erase(assumeProg())
+
+	c, ok := e.Procs[0].Body[0].(*boogie.Check)
+	if !ok || c.Action != boogie.CheckAssume {
+		t.Fatalf("expected guarding check, got %#v", e.Procs[0].Body[0])
+	}
+}
+
+func TestEraseWithFiltersAssume(t *testing.T) {
+	e := EraseWith(assumeProg(), Policy{Assumes: AssumeFilter})
+	if c := e.Procs[0].Body[0].(*boogie.Check); c.Action != boogie.CheckFilter {
+		t.Fatalf("expected filtering check, got %v", c.Action)
+	}
+
+	p := assumeProg(boogie.Attr{Name: "boogo", Args: []string{"filter"}})
+	if c := Erase(p).Procs[0].Body[0].(*boogie.Check); c.Action != boogie.CheckFilter {
+		t.Fatalf("attribute did not override policy: %v", c.Action)
+	}
+}
+
+// ❌ Assertion mode on an assumption
+func TestRejectAssertModeOnAssume(t *testing.T) {
+	mustReject(t, assumeProg(boogie.Attr{Name: "boogo", Args: []string{"log"}}))
+}
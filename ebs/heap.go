@@ -0,0 +1,87 @@
+package ebs
+
+import (
+	"fmt"
+
+	"github.com/ezrantn/boogo/boogie"
+)
+
+// checkHeap settles the type of every heap field, which the typed heap
+// the generated code keeps needs statically. A field declared as a
+// constant, const f: Field int, has its declared type; any other field
+// takes the type of the values written to it, or else the type its
+// reads claim. Reads without a type get their field's, and every
+// access must agree with it. Fields found this way are added to
+// p.Fields.
+func checkHeap(p *boogie.Program) error {
+	fields := make(map[string]boogie.Type)
+	for _, f := range p.Fields {
+		if _, ok := fields[f.Name]; ok {
+			return fmt.Errorf("%v: heap field %s redeclared", f.Pos, f.Name)
+		}
+		fields[f.Name] = f.Ty
+	}
+
+	var reads []*boogie.HeapRead
+	var writes []*boogie.HeapWrite
+	for _, proc := range p.Procs {
+		boogie.WalkStmts(proc.Body, func(s boogie.Stmt) {
+			if w, ok := s.(*boogie.HeapWrite); ok {
+				writes = append(writes, w)
+			}
+		}, func(e boogie.Expr) {
+			if r, ok := e.(*boogie.HeapRead); ok {
+				reads = append(reads, r)
+			}
+		})
+	}
+
+	infer := func(field string, ty boogie.Type) bool {
+		if _, ok := fields[field]; ok || ty == nil {
+			return false
+		}
+		fields[field] = ty
+		p.Fields = append(p.Fields, &boogie.FieldDecl{Name: field, Ty: ty})
+		return true
+	}
+
+	// A value written may itself be a read whose type is not known
+	// yet, so inference runs until nothing changes.
+	for changed := true; changed; {
+		changed = false
+		for _, w := range writes {
+			changed = infer(w.Field, w.Value.Type()) || changed
+		}
+		for _, r := range reads {
+			changed = infer(r.Field, r.Ty) || changed
+			if r.Ty == nil && fields[r.Field] != nil {
+				r.Ty = fields[r.Field]
+				changed = true
+			}
+		}
+	}
+
+	for _, r := range reads {
+		want, ok := fields[r.Field]
+		if !ok {
+			return unknownField(r.Field, r.Pos)
+		}
+		if !sameType(r.Ty, want) {
+			return fmt.Errorf("%v: heap field %s holds %v, not %v", r.Pos, r.Field, want, r.Ty)
+		}
+	}
+	for _, w := range writes {
+		want, ok := fields[w.Field]
+		if !ok {
+			return unknownField(w.Field, w.Pos)
+		}
+		if got := w.Value.Type(); !sameType(got, want) {
+			return fmt.Errorf("%v: heap field %s holds %v, cannot store %v", w.Pos, w.Field, want, got)
+		}
+	}
+	return nil
+}
+
+func unknownField(field string, pos boogie.Pos) error {
+	return fmt.Errorf("%v: the type of heap field %s is unknown; declare it, as in const %s: Field int", pos, field, field)
+}
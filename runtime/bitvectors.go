@@ -0,0 +1,197 @@
+package runtime
+
+import (
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Bitvectors (see codegen/bv.go): the operations Go has no operator
+// for, and everything on bitvectors wider than 64 bits.
+
+// Bitvectors up to 64 bits are Go unsigned integers; these helpers
+// work on the value widened to uint64. Division by zero follows
+// SMT-LIB: x / 0 is all ones and x % 0 is x.
+
+func bvMask(w uint) uint64 {
+	if w >= 64 {
+		return math.MaxUint64
+	}
+	return 1<<w - 1
+}
+
+// BvSigned reads a as a w-bit two's complement number.
+func BvSigned(a uint64, w uint) int64 {
+	return int64(a<<(64-w)) >> (64 - w)
+}
+
+func BvSignExtend(a uint64, w, to uint) uint64 {
+	return uint64(BvSigned(a, w)) & bvMask(to)
+}
+
+// BvDyn is the identity; it keeps the Go compiler from folding an
+// operation on constants, which would reject its wraparound.
+func BvDyn(a uint64) uint64 {
+	return a
+}
+
+func BvOp(op string, w uint, a, b uint64) uint64 {
+	m := bvMask(w)
+	sa, sb := BvSigned(a, w), BvSigned(b, w)
+	switch op {
+	case "bvudiv":
+		if b == 0 {
+			return m
+		}
+		return a / b
+	case "bvurem":
+		if b == 0 {
+			return a
+		}
+		return a % b
+	case "bvsdiv":
+		if b == 0 {
+			if sa < 0 {
+				return 1
+			}
+			return m
+		}
+		return uint64(sa/sb) & m
+	case "bvsrem":
+		if b == 0 {
+			return a
+		}
+		return uint64(sa%sb) & m
+	case "bvsmod":
+		if b == 0 {
+			return a
+		}
+		r := sa % sb
+		if r != 0 && (r < 0) != (sb < 0) {
+			r += sb
+		}
+		return uint64(r) & m
+	case "bvashr":
+		if b >= uint64(w) {
+			b = uint64(w) - 1
+		}
+		return uint64(sa>>b) & m
+	}
+	panic("unknown bitvector operation " + op)
+}
+
+// Bitvectors wider than 64 bits are *big.Int in [0, 2^w).
+
+func bvBigMod(a *big.Int, w uint) *big.Int {
+	m := new(big.Int).Lsh(big.NewInt(1), w)
+	return new(big.Int).Mod(a, m)
+}
+
+func bvBigSigned(a *big.Int, w uint) *big.Int {
+	if a.Bit(int(w)-1) == 0 {
+		return a
+	}
+	return new(big.Int).Sub(a, new(big.Int).Lsh(big.NewInt(1), w))
+}
+
+func BvBigSignExtend(a *big.Int, w, to uint) *big.Int {
+	return bvBigMod(bvBigSigned(a, w), to)
+}
+
+func BvBigOp(op string, w uint, args ...*big.Int) *big.Int {
+	a, b := args[0], args[len(args)-1]
+	sa, sb := bvBigSigned(a, w), bvBigSigned(b, w)
+	shift := w
+	if b.IsUint64() && b.Uint64() < uint64(w) {
+		shift = uint(b.Uint64())
+	}
+
+	r := new(big.Int)
+	switch op {
+	case "bvadd":
+		r.Add(a, b)
+	case "bvsub":
+		r.Sub(a, b)
+	case "bvmul":
+		r.Mul(a, b)
+	case "bvneg":
+		r.Neg(a)
+	case "bvnot":
+		r.Not(a)
+	case "bvand":
+		r.And(a, b)
+	case "bvor":
+		r.Or(a, b)
+	case "bvxor":
+		r.Xor(a, b)
+	case "bvshl":
+		r.Lsh(a, shift)
+	case "bvlshr":
+		r.Rsh(a, shift)
+	case "bvashr":
+		r.Rsh(sa, shift)
+	case "bvudiv":
+		if b.Sign() == 0 {
+			r.SetInt64(-1)
+		} else {
+			r.Quo(a, b)
+		}
+	case "bvurem":
+		if b.Sign() == 0 {
+			r.Set(a)
+		} else {
+			r.Rem(a, b)
+		}
+	case "bvsdiv":
+		switch {
+		case b.Sign() != 0:
+			r.Quo(sa, sb)
+		case sa.Sign() < 0:
+			r.SetInt64(1)
+		default:
+			r.SetInt64(-1)
+		}
+	case "bvsrem":
+		if b.Sign() == 0 {
+			r.Set(a)
+		} else {
+			r.Rem(sa, sb)
+		}
+	case "bvsmod":
+		if b.Sign() == 0 {
+			r.Set(a)
+		} else if r.Rem(sa, sb); r.Sign() != 0 && r.Sign() != sb.Sign() {
+			r.Add(r, sb)
+		}
+	default:
+		panic("unknown bitvector operation " + op)
+	}
+	return bvBigMod(r, w)
+}
+
+func BvBigRel(op string, w uint, a, b *big.Int) bool {
+	c := a.Cmp(b)
+	if strings.HasPrefix(op, "bvs") {
+		c = bvBigSigned(a, w).Cmp(bvBigSigned(b, w))
+	}
+	switch op[3:] {
+	case "lt":
+		return c < 0
+	case "le":
+		return c <= 0
+	case "gt":
+		return c > 0
+	case "ge":
+		return c >= 0
+	}
+	panic("unknown bitvector comparison " + op)
+}
+
+func BvBigExtract(a *big.Int, hi, lo uint) *big.Int {
+	return bvBigMod(new(big.Int).Rsh(a, lo), hi-lo)
+}
+
+func BvBigConcat(a, b *big.Int, wb uint) *big.Int {
+	r := new(big.Int).Lsh(a, wb)
+	return r.Or(r, b)
+}
@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// Checks are what erasure keeps of assertions and assumptions (see
+// codegen.emitCheck).
+
+// AssertionError is the panic value of a failed assertion.
+type AssertionError struct {
+	Pos  string
+	Expr string
+}
+
+func (e *AssertionError) Error() string {
+	return e.Pos + ": assertion failed: " + e.Expr
+}
+
+// assertFailures counts assertions that failed under the "count" mode.
+var assertFailures atomic.Int64
+
+func AssertPanic(pos, expr string) {
+	dumpHeap("assertion " + pos)
+	panic(&AssertionError{Pos: pos, Expr: expr})
+}
+
+func AssertLog(pos, expr string) {
+	fmt.Fprintf(os.Stderr, "%s: assertion failed: %s\n", pos, expr)
+	dumpHeap("assertion " + pos)
+}
+
+func AssertCount(pos, expr string) {
+	assertFailures.Add(1)
+	dumpHeap("assertion " + pos)
+}
+
+// AssumptionViolated is the panic value of a failed guarded assume:
+// execution reached a state the program is not specified for.
+type AssumptionViolated struct {
+	Pos  string
+	Expr string
+}
+
+func (e *AssumptionViolated) Error() string {
+	return e.Pos + ": assumption violated: " + e.Expr
+}
+
+// pathFiltered is the panic value of a failed filtering assume. It is
+// not an error: the path is simply not one worth executing.
+type pathFiltered struct{}
+
+func AssumeGuard(pos, expr string) {
+	panic(&AssumptionViolated{Pos: pos, Expr: expr})
+}
+
+func AssumeFilter(pos, expr string) {
+	panic(pathFiltered{})
+}
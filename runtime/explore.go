@@ -0,0 +1,196 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Exploration enumerates every choice sequence of a program; it is
+// what BOOGO_EXPLORE switches RunMain to.
+
+// ExploreFailure is a failing path found by Explore: the panic value
+// (or count summary) and the choices that reach it.
+type ExploreFailure struct {
+	Err   any
+	Trace []int
+}
+
+// ExploreResult summarizes an exhaustive exploration.
+type ExploreResult struct {
+	Paths      int // complete executions
+	Infeasible int // paths cut short by a failed assume
+	Cut        int // paths abandoned at the depth bound
+	Failures   []ExploreFailure
+}
+
+// Explore runs entry once per sequence of choices, depth first, so that
+// every path is executed exactly once. Havoced ints range over ints;
+// bools and gotos take every alternative. Paths making more than depth
+// choices are abandoned, which bounds the search as long as every loop
+// makes a choice. Failures are reported once per distinct error, with
+// the first trace reaching them; traces replay with BOOGO_REPLAY.
+func Explore(entry func(), depth int, ints []int) ExploreResult {
+	prev := oracle
+	defer SetOracle(prev)
+
+	o := &exploreOracle{ints: ints, depth: depth}
+	seen := make(map[string]bool)
+	var res ExploreResult
+
+	for {
+		SetOracle(o)
+		resetState()
+
+		switch outcome, err := runPath(entry); outcome {
+		case pathDone:
+			res.Paths++
+		case pathInfeasible:
+			res.Infeasible++
+		case pathCut:
+			res.Cut++
+		case pathFailed:
+			res.Paths++
+			if key := fmt.Sprint(err); !seen[key] {
+				seen[key] = true
+				res.Failures = append(res.Failures, ExploreFailure{Err: err, Trace: Trace()})
+			}
+		}
+
+		if !o.advance() {
+			return res
+		}
+	}
+}
+
+// exploreOracle replays the choices of the current path and extends it
+// with first alternatives until execution ends.
+type exploreOracle struct {
+	ints  []int
+	depth int
+	stack []exploreChoice
+	next  int
+}
+
+type exploreChoice struct {
+	k, n int // chosen alternative, number of alternatives
+}
+
+// depthCut is the panic value that abandons a path at the depth bound.
+type depthCut struct{}
+
+func (o *exploreOracle) choose(n int) int {
+	if o.next == len(o.stack) {
+		if len(o.stack) >= o.depth {
+			panic(depthCut{})
+		}
+		o.stack = append(o.stack, exploreChoice{k: 0, n: n})
+	}
+	c := o.stack[o.next]
+	o.next++
+	return c.k
+}
+
+func (o *exploreOracle) Int(site string) int {
+	return o.ints[o.choose(len(o.ints))]
+}
+
+func (o *exploreOracle) Bool(site string) bool {
+	return o.choose(2) == 1
+}
+
+func (o *exploreOracle) Choose(site string, n int) int {
+	return o.choose(n)
+}
+
+// advance moves to the next unexplored path, reporting false when the
+// search is complete.
+func (o *exploreOracle) advance() bool {
+	o.next = 0
+	for len(o.stack) > 0 {
+		top := &o.stack[len(o.stack)-1]
+		if top.k+1 < top.n {
+			top.k++
+			return true
+		}
+		o.stack = o.stack[:len(o.stack)-1]
+	}
+	return false
+}
+
+type pathOutcome int
+
+const (
+	pathDone pathOutcome = iota
+	pathInfeasible
+	pathCut
+	pathFailed
+)
+
+func runPath(entry func()) (outcome pathOutcome, err any) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case pathFiltered, *AssumptionViolated:
+			outcome = pathInfeasible
+		case depthCut:
+			outcome = pathCut
+		default:
+			outcome, err = pathFailed, r
+		}
+	}()
+
+	entry()
+
+	if n := assertFailures.Load(); n > 0 {
+		return pathFailed, fmt.Sprintf("%d assertion(s) failed", n)
+	}
+	return pathDone, nil
+}
+
+// resetState clears the program state a previous path left behind.
+func resetState() {
+	for _, reset := range resetHooks {
+		reset()
+	}
+	assertFailures.Store(0)
+}
+
+// exploreMain runs Explore as configured by BOOGO_EXPLORE (the depth
+// bound) and BOOGO_EXPLORE_INTS (an int range "lo..hi", default -2..2),
+// prints a report and returns whether no path failed.
+func exploreMain(entry func()) bool {
+	depth, err := strconv.Atoi(os.Getenv("BOOGO_EXPLORE"))
+	if err != nil || depth < 0 {
+		panic("BOOGO_EXPLORE: want a depth bound, got " + os.Getenv("BOOGO_EXPLORE"))
+	}
+
+	lo, hi := -2, 2
+	if s := os.Getenv("BOOGO_EXPLORE_INTS"); s != "" {
+		l, h, ok := strings.Cut(s, "..")
+		lo, err = strconv.Atoi(l)
+		if err == nil {
+			hi, err = strconv.Atoi(h)
+		}
+		if !ok || err != nil || lo > hi {
+			panic("BOOGO_EXPLORE_INTS: want a range lo..hi, got " + s)
+		}
+	}
+
+	var ints []int
+	for v := lo; v <= hi; v++ {
+		ints = append(ints, v)
+	}
+
+	res := Explore(entry, depth, ints)
+
+	fmt.Fprintf(os.Stderr, "boogo: explored %d path(s), %d infeasible, %d cut at depth %d\n",
+		res.Paths, res.Infeasible, res.Cut, depth)
+	for _, f := range res.Failures {
+		fmt.Fprintf(os.Stderr, "boogo: %v\n", f.Err)
+		fmt.Fprintf(os.Stderr, "boogo:   reproduce with BOOGO_REPLAY=%s\n", formatTrace(f.Trace))
+	}
+
+	return len(res.Failures) == 0
+}
@@ -0,0 +1,148 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// The heap itself is generated, typed by the fields of each program
+// (see codegen.EmitHeap); the runtime only knows how to fault on it,
+// how to clear it between explored paths and how to report on it.
+
+// NullDereferenceError is the panic value of a read or write of a field
+// of null.
+type NullDereferenceError struct {
+	Pos  string
+	Expr string
+}
+
+func (e *NullDereferenceError) Error() string {
+	return e.Pos + ": null dereference: " + e.Expr
+}
+
+// HeapError is the panic value of a heap access the checked heap
+// (codegen.HeapChecked) rejects: one to an object not allocated, or a
+// read of a field never written.
+type HeapError struct {
+	Pos  string
+	Msg  string
+	Expr string
+}
+
+func (e *HeapError) Error() string {
+	return e.Pos + ": " + e.Msg + ": " + e.Expr
+}
+
+// ReportHeap prints heap statistics to stderr when BOOGO_HEAPSTATS is
+// set: the objects allocated at exit and the writes to each field.
+func ReportHeap(live int, fields []string, writes []int) {
+	if os.Getenv("BOOGO_HEAPSTATS") == "" {
+		return
+	}
+
+	total := 0
+	var per []string
+	for i, f := range fields {
+		total += writes[i]
+		per = append(per, f+": "+strconv.Itoa(writes[i]))
+	}
+	fmt.Fprintf(os.Stderr, "boogo: heap: %d live object(s), %d field write(s)", live, total)
+	if len(per) > 0 {
+		fmt.Fprintf(os.Stderr, " (%s)", strings.Join(per, ", "))
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+var resetHooks []func()
+
+// OnReset registers reset to clear generated program state, such as
+// the heap, before each path Explore runs.
+func OnReset(reset func()) {
+	resetHooks = append(resetHooks, reset)
+}
+
+// heapShardCount is the number of shards of a HeapShards.
+const heapShardCount = 64
+
+// HeapShards is the heap of objects of type T shared by executions
+// running in parallel (see codegen.StateShared). Objects are spread
+// over shards by reference, each shard with its own lock, so accesses
+// to different objects rarely contend.
+type HeapShards[T any] struct {
+	shards [heapShardCount]HeapShard[T]
+	next   atomic.Int64 // the last reference Alloc tried
+}
+
+// HeapShard is one shard of a HeapShards. Lock it to use Objects or
+// Writes.
+type HeapShard[T any] struct {
+	sync.Mutex
+	Objects map[int]*T
+	Writes  []int // writes to each field, under the checked heap
+}
+
+// NewHeapShards returns an empty heap for objects with the given
+// number of fields.
+func NewHeapShards[T any](fields int) *HeapShards[T] {
+	h := &HeapShards[T]{}
+	for i := range h.shards {
+		h.shards[i].Objects = map[int]*T{}
+		h.shards[i].Writes = make([]int, fields)
+	}
+	return h
+}
+
+// Shard returns the shard holding object o.
+func (h *HeapShards[T]) Shard(o int) *HeapShard[T] {
+	return &h.shards[uint(o)%heapShardCount]
+}
+
+// Alloc stores obj under a fresh reference and returns it: counting up
+// from 1, the first reference not yet in the heap.
+func (h *HeapShards[T]) Alloc(obj *T) int {
+	for {
+		o := int(h.next.Add(1))
+		s := h.Shard(o)
+		s.Lock()
+		if _, ok := s.Objects[o]; !ok {
+			s.Objects[o] = obj
+			s.Unlock()
+			return o
+		}
+		s.Unlock()
+	}
+}
+
+// Each calls f on every object, a shard at a time, with its shard
+// locked.
+func (h *HeapShards[T]) Each(f func(o int, obj *T)) {
+	for i := range h.shards {
+		s := &h.shards[i]
+		s.Lock()
+		for o, obj := range s.Objects {
+			f(o, obj)
+		}
+		s.Unlock()
+	}
+}
+
+// Writes sums the writes to each field over the shards.
+func (h *HeapShards[T]) Writes() []int {
+	var total []int
+	for i := range h.shards {
+		s := &h.shards[i]
+		s.Lock()
+		if total == nil {
+			total = make([]int, len(s.Writes))
+		}
+		for f, n := range s.Writes {
+			total[f] += n
+		}
+		s.Unlock()
+	}
+	return total
+}
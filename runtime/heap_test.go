@@ -0,0 +1,50 @@
+package runtime
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestHeapShards(t *testing.T) {
+	type obj struct{ val int }
+	h := NewHeapShards[obj](2)
+
+	// A reference written to before New hands it out is skipped.
+	h.Shard(2).Objects[2] = &obj{}
+
+	const n = 100
+	var wg sync.WaitGroup
+	refs := make([]int, n)
+	for i := range refs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			refs[i] = h.Alloc(&obj{val: i})
+			s := h.Shard(refs[i])
+			s.Lock()
+			s.Writes[1]++
+			s.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[int]bool{}
+	for i, o := range refs {
+		if o < 1 || o == 2 || seen[o] {
+			t.Fatalf("Alloc returned %d", o)
+		}
+		seen[o] = true
+		if got := h.Shard(o).Objects[o].val; got != i {
+			t.Errorf("object %d holds %d, want %d", o, got, i)
+		}
+	}
+
+	count := 0
+	h.Each(func(o int, obj *obj) { count++ })
+	if count != n+1 {
+		t.Errorf("Each visited %d objects, want %d", count, n+1)
+	}
+	if w := h.Writes(); w[0] != 0 || w[1] != n {
+		t.Errorf("Writes is %v, want [0 %d]", w, n)
+	}
+}
@@ -0,0 +1,155 @@
+package runtime
+
+import (
+	"math"
+	"math/big"
+)
+
+// Integers back Boogie's unbounded ints (see codegen.IntBig) and the
+// overflow-checked int64 mode (codegen.IntChecked).
+
+// Boogie ints are *big.Int values. They are never mutated after
+// creation, so they can be shared freely.
+
+func BigLit(s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("BigLit: malformed literal " + s)
+	}
+	return v
+}
+
+func BigAdd(a, b *big.Int) *big.Int {
+	return new(big.Int).Add(a, b)
+}
+
+func BigSub(a, b *big.Int) *big.Int {
+	return new(big.Int).Sub(a, b)
+}
+
+func BigMul(a, b *big.Int) *big.Int {
+	return new(big.Int).Mul(a, b)
+}
+
+func BigNeg(a *big.Int) *big.Int {
+	return new(big.Int).Neg(a)
+}
+
+// DivisionByZeroError is the panic value of div, mod or / with a zero
+// divisor. Boogie leaves the result unspecified; running code faults.
+type DivisionByZeroError struct {
+	Pos  string
+	Expr string
+}
+
+func (e *DivisionByZeroError) Error() string {
+	return e.Pos + ": division by zero: " + e.Expr
+}
+
+// big.Int's Div and Mod are Euclidean, as Boogie's div and mod are.
+
+func BigDiv(a, b *big.Int, pos, expr string) *big.Int {
+	if b.Sign() == 0 {
+		panic(&DivisionByZeroError{Pos: pos, Expr: expr})
+	}
+	return new(big.Int).Div(a, b)
+}
+
+func BigMod(a, b *big.Int, pos, expr string) *big.Int {
+	if b.Sign() == 0 {
+		panic(&DivisionByZeroError{Pos: pos, Expr: expr})
+	}
+	return new(big.Int).Mod(a, b)
+}
+
+// IntDiv and IntMod are Euclidean division on Go integers, whose / and
+// % truncate: -7 div 2 is -4 and -7 mod 2 is 1.
+
+func IntDiv[T int | int64](a, b T, pos, expr string) T {
+	q, _ := intDivMod(a, b, pos, expr)
+	return q
+}
+
+func IntMod[T int | int64](a, b T, pos, expr string) T {
+	_, r := intDivMod(a, b, pos, expr)
+	return r
+}
+
+func intDivMod[T int | int64](a, b T, pos, expr string) (T, T) {
+	if b == 0 {
+		panic(&DivisionByZeroError{Pos: pos, Expr: expr})
+	}
+	q, r := a/b, a%b
+	if r < 0 {
+		if b > 0 {
+			q, r = q-1, r+b
+		} else {
+			q, r = q+1, r-b
+		}
+	}
+	return q, r
+}
+
+// OverflowError is the panic value of checked int64 arithmetic whose
+// exact (Boogie) result does not fit in an int64.
+type OverflowError struct {
+	Pos  string
+	Expr string
+}
+
+func (e *OverflowError) Error() string {
+	return e.Pos + ": integer overflow: " + e.Expr
+}
+
+func CheckedAdd(a, b int64, pos, expr string) int64 {
+	c := a + b
+	if (a >= 0) == (b >= 0) && (c >= 0) != (a >= 0) {
+		panic(&OverflowError{Pos: pos, Expr: expr})
+	}
+	return c
+}
+
+func CheckedSub(a, b int64, pos, expr string) int64 {
+	c := a - b
+	if (a >= 0) != (b >= 0) && (c >= 0) != (a >= 0) {
+		panic(&OverflowError{Pos: pos, Expr: expr})
+	}
+	return c
+}
+
+func CheckedMul(a, b int64, pos, expr string) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		panic(&OverflowError{Pos: pos, Expr: expr})
+	}
+	return c
+}
+
+func CheckedNeg(a int64, pos, expr string) int64 {
+	if a == math.MinInt64 {
+		panic(&OverflowError{Pos: pos, Expr: expr})
+	}
+	return -a
+}
+
+// CheckedDiv overflows only for math.MinInt64 div -1.
+func CheckedDiv(a, b int64, pos, expr string) int64 {
+	if a == math.MinInt64 && b == -1 {
+		panic(&OverflowError{Pos: pos, Expr: expr})
+	}
+	return IntDiv(a, b, pos, expr)
+}
+
+func CheckedMod(a, b int64, pos, expr string) int64 {
+	return IntMod(a, b, pos, expr)
+}
+
+func CheckedInt64(a *big.Int, pos, expr string) int64 {
+	if !a.IsInt64() {
+		panic(&OverflowError{Pos: pos, Expr: expr})
+	}
+	return a.Int64()
+}
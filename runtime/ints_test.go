@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// Boogie's div and mod are Euclidean: the remainder is never negative.
+func TestDivModEuclidean(t *testing.T) {
+	tests := []struct{ a, b, q, r int64 }{
+		{7, 2, 3, 1},
+		{-7, 2, -4, 1},
+		{7, -2, -3, 1},
+		{-7, -2, 4, 1},
+		{6, 3, 2, 0},
+	}
+
+	for _, tt := range tests {
+		if q, r := IntDiv(tt.a, tt.b, "", ""), IntMod(tt.a, tt.b, "", ""); q != tt.q || r != tt.r {
+			t.Errorf("int64: %d div/mod %d = %d, %d; want %d, %d", tt.a, tt.b, q, r, tt.q, tt.r)
+		}
+		a, b := big.NewInt(tt.a), big.NewInt(tt.b)
+		if q, r := BigDiv(a, b, "", ""), BigMod(a, b, "", ""); q.Int64() != tt.q || r.Int64() != tt.r {
+			t.Errorf("big: %d div/mod %d = %v, %v; want %d, %d", tt.a, tt.b, q, r, tt.q, tt.r)
+		}
+	}
+}
+
+func TestDivisionByZeroPanics(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		var dz *DivisionByZeroError
+		if !errors.As(err, &dz) || err.Error() != "f.bpl:1:2: division by zero: a div b" {
+			t.Fatalf("got panic %v, want a DivisionByZeroError", err)
+		}
+	}()
+	IntDiv(1, 0, "f.bpl:1:2", "a div b")
+}
+
+func TestCheckedOverflow(t *testing.T) {
+	defer func() {
+		if _, ok := recover().(*OverflowError); !ok {
+			t.Fatal("expected an OverflowError")
+		}
+	}()
+	CheckedAdd(math.MaxInt64, 1, "", "")
+}
@@ -0,0 +1,159 @@
+package runtime
+
+import (
+	"slices"
+	"strings"
+)
+
+// Maps back Boogie's map types (see codegen/map.go).
+
+// Map is a persistent map: Set returns a new map and leaves the
+// receiver unchanged, as Boogie's m[i := v] does. Every version of a
+// map shares one Go map, which holds the version used last; the other
+// versions are chains of single-key diffs leading to it (Baker's
+// trick). Reading and updating the latest version, as a loop doing
+// m := m[i := v] does, costs no more than a Go map; going back to an
+// older version costs one step per intervening update.
+//
+// Maps are not safe for concurrent use, even for reading; Clone one to
+// hand it to another goroutine.
+type Map[K comparable, V any] struct {
+	node *mapNode[K, V]
+	def  V // the value at every key never set
+}
+
+// mapNode is one version of a map. The current version holds data;
+// any other holds the one binding in which it differs from next.
+type mapNode[K comparable, V any] struct {
+	data map[K]V
+
+	next  *mapNode[K, V]
+	key   K
+	val   V
+	bound bool // whether key is bound to val in this version
+}
+
+func MapNew[K comparable, V any](def V) Map[K, V] {
+	return Map[K, V]{node: &mapNode[K, V]{data: map[K]V{}}, def: def}
+}
+
+func (m Map[K, V]) Get(k K) V {
+	if m.node == nil {
+		return m.def
+	}
+	m.node.reroot()
+	if v, ok := m.node.data[k]; ok {
+		return v
+	}
+	return m.def
+}
+
+func (m Map[K, V]) Set(k K, v V) Map[K, V] {
+	n := m.node
+	if n == nil {
+		n = &mapNode[K, V]{data: map[K]V{}}
+	}
+	n.reroot()
+
+	data := n.data
+	old, bound := data[k]
+	data[k] = v
+
+	next := &mapNode[K, V]{data: data}
+	n.data, n.next, n.key, n.val, n.bound = nil, next, k, old, bound
+	return Map[K, V]{node: next, def: m.def}
+}
+
+// Clone returns a copy of m sharing no state with it, so that another
+// goroutine may use one while this one uses the other. Map values are
+// cloned too.
+func (m Map[K, V]) Clone() Map[K, V] {
+	c := Map[K, V]{def: cloneValue(m.def)}
+	if m.node == nil {
+		return c
+	}
+	m.node.reroot()
+	data := make(map[K]V, len(m.node.data))
+	for k, v := range m.node.data {
+		data[k] = cloneValue(v)
+	}
+	c.node = &mapNode[K, V]{data: data}
+	return c
+}
+
+func (m Map[K, V]) cloneAny() any {
+	return m.Clone()
+}
+
+// cloneValue clones v if it is a Map, directly or held in an any.
+func cloneValue[V any](v V) V {
+	if m, ok := any(v).(interface{ cloneAny() any }); ok {
+		return m.cloneAny().(V)
+	}
+	return v
+}
+
+// String formats m as [k: v, ...], keys in the order of their
+// formatting, followed by the value at every other key.
+func (m Map[K, V]) String() string {
+	var data map[K]V
+	if m.node != nil {
+		m.node.reroot()
+		data = m.node.data
+	}
+
+	entries := make([]string, 0, len(data)+1)
+	for k, v := range data {
+		entries = append(entries, FormatValue(k)+": "+FormatValue(v))
+	}
+	slices.Sort(entries)
+	entries = append(entries, "default: "+FormatValue(m.def))
+	return "[" + strings.Join(entries, ", ") + "]"
+}
+
+// reroot makes n the current version, reversing the diffs between it
+// and the current one so that they lead to n instead.
+func (n *mapNode[K, V]) reroot() {
+	var path []*mapNode[K, V]
+	for x := n; x.data == nil; x = x.next {
+		path = append(path, x)
+	}
+
+	for i := len(path) - 1; i >= 0; i-- {
+		x := path[i]
+		cur := x.next
+		data := cur.data
+
+		old, bound := data[x.key]
+		if x.bound {
+			data[x.key] = x.val
+		} else {
+			delete(data, x.key)
+		}
+
+		var zero V
+		cur.data, cur.next, cur.key, cur.val, cur.bound = nil, x, x.key, old, bound
+		x.data, x.next, x.val = data, nil, zero
+	}
+}
+
+// PolyGet reads a polymorphic map, which holds the values of all its
+// instantiations as any. V is the instantiation read, and def its
+// value at keys never set.
+func PolyGet[V any](m Map[any, any], k any, def V) V {
+	if m.node == nil {
+		return def
+	}
+	m.node.reroot()
+	if v, ok := m.node.data[k]; ok {
+		return v.(V)
+	}
+	return def
+}
+
+// PolySet updates a polymorphic map. V fixes the Go type of v, which
+// PolyGet asserts it back to, where v would otherwise be an untyped
+// constant.
+func PolySet[V any](m Map[any, any], k any, v V) Map[any, any] {
+	return m.Set(k, v)
+}
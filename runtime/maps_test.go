@@ -0,0 +1,59 @@
+package runtime
+
+import "testing"
+
+// Every version of a map keeps its bindings, however versions are
+// interleaved.
+func TestMapPersistent(t *testing.T) {
+	m0 := MapNew[int, string]("none")
+	m1 := m0.Set(1, "a")
+	m2 := m1.Set(1, "b")
+	m3 := m1.Set(2, "c")
+
+	tests := []struct {
+		m    Map[int, string]
+		k    int
+		want string
+	}{
+		{m2, 1, "b"},
+		{m0, 1, "none"},
+		{m3, 1, "a"},
+		{m3, 2, "c"},
+		{m1, 2, "none"},
+		{m2, 2, "none"},
+		{m1, 1, "a"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.m.Get(tt.k); got != tt.want {
+			t.Errorf("%d: got %q at %d, want %q", i, got, tt.k, tt.want)
+		}
+	}
+}
+
+func TestMapZeroValue(t *testing.T) {
+	var m Map[int, int]
+	if m.Get(3) != 0 || m.Set(3, 4).Get(3) != 4 || m.Get(3) != 0 {
+		t.Fatal("the zero Map is not an empty map")
+	}
+}
+
+func TestMapClone(t *testing.T) {
+	inner := MapNew[int, int](0).Set(1, 10)
+	m := MapNew[int, Map[int, int]](MapNew[int, int](0)).Set(1, inner)
+	old := m
+	m = m.Set(2, inner.Set(2, 20))
+
+	// A clone of an old version holds that version, and shares no
+	// nodes with the original, down to the inner maps.
+	c := old.Clone()
+	if got := c.String(); got != "[1: [1: 10, default: 0], default: [default: 0]]" {
+		t.Errorf("clone is %s", got)
+	}
+	if c.node == old.node || c.Get(1).node == inner.node {
+		t.Error("clone shares nodes with the original")
+	}
+	if got := m.Get(2).Get(2); got != 20 {
+		t.Errorf("original changed by cloning: m[2][2] = %d", got)
+	}
+}
@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	"strings"
+	"sync"
+)
+
+// Memo caches the results of a {:memoize} function by its arguments
+// (see codegen.EmitFunc). Arguments are told apart by FormatValue, so
+// that big.Int, big.Rat and Map arguments compare by value. A Memo is
+// safe for executions running in parallel.
+type Memo[R any] struct {
+	mu      sync.Mutex
+	results map[string]R
+}
+
+// Do returns the result for args, computing it with f only if it is
+// not cached yet. f runs unlocked, so it may apply other memoized
+// functions.
+func (m *Memo[R]) Do(f func() R, args ...any) R {
+	keys := make([]string, len(args))
+	for i, a := range args {
+		keys[i] = FormatValue(a)
+	}
+	key := strings.Join(keys, ", ")
+
+	m.mu.Lock()
+	r, ok := m.results[key]
+	m.mu.Unlock()
+	if ok {
+		return r
+	}
+
+	r = f()
+	m.mu.Lock()
+	if m.results == nil {
+		m.results = map[string]R{}
+	}
+	m.results[key] = r
+	m.mu.Unlock()
+	return r
+}
+
+// Len returns the number of results cached.
+func (m *Memo[R]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.results)
+}
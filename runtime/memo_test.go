@@ -0,0 +1,35 @@
+package runtime
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestMemo(t *testing.T) {
+	var m Memo[*big.Int]
+	calls := 0
+	sq := func(x *big.Int) *big.Int {
+		return m.Do(func() *big.Int {
+			calls++
+			return new(big.Int).Mul(x, x)
+		}, x)
+	}
+
+	for _, x := range []int64{3, 3, -3, 4} {
+		if got, want := sq(big.NewInt(x)).Int64(), x*x; got != want {
+			t.Errorf("sq(%d) = %d, want %d", x, got, want)
+		}
+	}
+	// Equal big.Ints are the same argument.
+	if calls != 3 || m.Len() != 3 {
+		t.Errorf("computed %d results and cached %d, want 3", calls, m.Len())
+	}
+
+	var maps Memo[int]
+	a := MapNew[int, int](0).Set(1, 10)
+	b := MapNew[int, int](0).Set(1, 10)
+	maps.Do(func() int { return 1 }, a)
+	if got := maps.Do(func() int { return 2 }, b); got != 1 {
+		t.Error("equal maps are different arguments")
+	}
+}
@@ -0,0 +1,271 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The oracle resolves nondeterminism (see codegen.emitHavoc).
+
+// Oracle supplies the values of nondeterministic choices. site names
+// the choice point, e.g. "prog.bpl:7:3 x" for "havoc x;" at 7:3, or
+// "prog.bpl:9:3 goto" for a multi-target goto.
+//
+// Install a custom Oracle with SetOracle before running any procedure.
+type Oracle interface {
+	Int(site string) int
+	Bool(site string) bool
+	// Choose picks one of n > 1 alternatives, returning 0 <= i < n.
+	Choose(site string, n int) int
+}
+
+// RandOracle draws choices from a seeded PRNG. Ints are mostly small,
+// with occasional boundary values.
+type RandOracle struct {
+	rng *rand.Rand
+}
+
+func NewRandOracle(seed int64) *RandOracle {
+	return &RandOracle{rng: rand.New(rand.NewSource(seed))}
+}
+
+var edgeInts = []int{0, 1, -1, math.MaxInt, math.MinInt}
+
+func (o *RandOracle) Int(site string) int {
+	if o.rng.Intn(8) == 0 {
+		return edgeInts[o.rng.Intn(len(edgeInts))]
+	}
+	return o.rng.Intn(2001) - 1000
+}
+
+func (o *RandOracle) Bool(site string) bool {
+	return o.rng.Intn(2) == 1
+}
+
+func (o *RandOracle) Choose(site string, n int) int {
+	return o.rng.Intn(n)
+}
+
+// ReplayOracle repeats the choices of a recorded trace (see Trace).
+// Bools are recorded as 0 and 1.
+type ReplayOracle struct {
+	trace []int
+	next  int
+}
+
+func NewReplayOracle(trace []int) *ReplayOracle {
+	return &ReplayOracle{trace: trace}
+}
+
+func (o *ReplayOracle) Int(site string) int {
+	if o.next >= len(o.trace) {
+		panic(fmt.Sprintf("replay: trace exhausted at %s", site))
+	}
+	v := o.trace[o.next]
+	o.next++
+	return v
+}
+
+func (o *ReplayOracle) Bool(site string) bool {
+	return o.Int(site) != 0
+}
+
+func (o *ReplayOracle) Choose(site string, n int) int {
+	return o.Int(site)
+}
+
+// BytesOracle derives choices from raw bytes, which makes it a natural
+// fit for go test -fuzz. Exhausted input yields zeros.
+type BytesOracle struct {
+	data []byte
+}
+
+func NewBytesOracle(data []byte) *BytesOracle {
+	return &BytesOracle{data: data}
+}
+
+func (o *BytesOracle) Int(site string) int {
+	var v int64
+	for i := 0; i < 8; i++ {
+		v = v<<8 | int64(o.byte())
+	}
+	return int(v)
+}
+
+func (o *BytesOracle) Bool(site string) bool {
+	return o.byte()&1 == 1
+}
+
+func (o *BytesOracle) Choose(site string, n int) int {
+	return int(o.byte()) % n
+}
+
+func (o *BytesOracle) byte() byte {
+	if len(o.data) == 0 {
+		return 0
+	}
+	b := o.data[0]
+	o.data = o.data[1:]
+	return b
+}
+
+var (
+	oracle     Oracle
+	oracleSeed int64
+	trace      []int
+)
+
+// oracleMu serializes choices, so that executions running in parallel
+// (see codegen.StateContext) can share the oracle. Their choices are
+// then recorded interleaved, in the order they were made.
+var oracleMu sync.Mutex
+
+// SetOracle installs o and clears the recorded trace.
+func SetOracle(o Oracle) {
+	oracle = o
+	trace = nil
+}
+
+// Trace returns the choices made since the oracle was installed, in a
+// form ReplayOracle (or BOOGO_REPLAY) accepts.
+func Trace() []int {
+	oracleMu.Lock()
+	defer oracleMu.Unlock()
+	return append([]int(nil), trace...)
+}
+
+// currentOracle returns the installed oracle, installing the default
+// on first use: a replay of $BOOGO_REPLAY if set, otherwise a PRNG
+// seeded from $BOOGO_SEED or the clock.
+func currentOracle() Oracle {
+	if oracle != nil {
+		return oracle
+	}
+
+	if s := os.Getenv("BOOGO_REPLAY"); s != "" {
+		t, err := parseTrace(s)
+		if err != nil {
+			panic("BOOGO_REPLAY: " + err.Error())
+		}
+		SetOracle(NewReplayOracle(t))
+		return oracle
+	}
+
+	oracleSeed = time.Now().UnixNano()
+	if s := os.Getenv("BOOGO_SEED"); s != "" {
+		seed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			panic("BOOGO_SEED: " + err.Error())
+		}
+		oracleSeed = seed
+	}
+	SetOracle(NewRandOracle(oracleSeed))
+	return oracle
+}
+
+func HavocInt(site string) int {
+	oracleMu.Lock()
+	defer oracleMu.Unlock()
+	v := currentOracle().Int(site)
+	trace = append(trace, v)
+	return v
+}
+
+func HavocBigInt(site string) *big.Int {
+	return big.NewInt(int64(HavocInt(site)))
+}
+
+func HavocInt64(site string) int64 {
+	return int64(HavocInt(site))
+}
+
+// HavocRat draws an integral real; the oracle deals in ints.
+func HavocRat(site string) *big.Rat {
+	return new(big.Rat).SetInt64(int64(HavocInt(site)))
+}
+
+func HavocBits(site string, w uint) uint64 {
+	return uint64(HavocInt(site)) & bvMask(w)
+}
+
+func HavocBigBits(site string, w uint) *big.Int {
+	return bvBigMod(big.NewInt(int64(HavocInt(site))), w)
+}
+
+func HavocBool(site string) bool {
+	oracleMu.Lock()
+	defer oracleMu.Unlock()
+	v := currentOracle().Bool(site)
+	if v {
+		trace = append(trace, 1)
+	} else {
+		trace = append(trace, 0)
+	}
+	return v
+}
+
+// ChooseBranch picks one of the enabled branches of a nondeterministic
+// goto and returns its index, or -1 if none is enabled. The oracle is
+// only consulted when there is an actual choice to make.
+func ChooseBranch(site string, enabled ...bool) int {
+	var idx []int
+	for i, ok := range enabled {
+		if ok {
+			idx = append(idx, i)
+		}
+	}
+
+	switch len(idx) {
+	case 0:
+		return -1
+	case 1:
+		return idx[0]
+	}
+
+	oracleMu.Lock()
+	defer oracleMu.Unlock()
+	k := currentOracle().Choose(site, len(idx))
+	if k < 0 || k >= len(idx) {
+		panic(fmt.Sprintf("%s: oracle chose %d of %d branches", site, k, len(idx)))
+	}
+	trace = append(trace, k)
+	return idx[k]
+}
+
+func formatTrace(t []int) string {
+	parts := make([]string, len(t))
+	for i, v := range t {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseTrace(s string) ([]int, error) {
+	var t []int
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, v)
+	}
+	return t, nil
+}
+
+// reportChoices tells the user how to reproduce a failed run.
+func reportChoices() {
+	if len(trace) == 0 {
+		return
+	}
+	if _, ok := oracle.(*RandOracle); ok {
+		fmt.Fprintf(os.Stderr, "boogo: seed %d\n", oracleSeed)
+	}
+	fmt.Fprintf(os.Stderr, "boogo: reproduce with BOOGO_REPLAY=%s\n", formatTrace(trace))
+}
@@ -0,0 +1,44 @@
+package runtime
+
+import (
+	"math/big"
+)
+
+// Reals are exact rationals (see codegen/real.go).
+
+func RatLit(s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic("invalid real literal " + s)
+	}
+	return r
+}
+
+func RatAdd(a, b *big.Rat) *big.Rat {
+	return new(big.Rat).Add(a, b)
+}
+
+func RatSub(a, b *big.Rat) *big.Rat {
+	return new(big.Rat).Sub(a, b)
+}
+
+func RatMul(a, b *big.Rat) *big.Rat {
+	return new(big.Rat).Mul(a, b)
+}
+
+func RatQuo(a, b *big.Rat, pos, expr string) *big.Rat {
+	if b.Sign() == 0 {
+		panic(&DivisionByZeroError{Pos: pos, Expr: expr})
+	}
+	return new(big.Rat).Quo(a, b)
+}
+
+func RatNeg(a *big.Rat) *big.Rat {
+	return new(big.Rat).Neg(a)
+}
+
+// RatFloor is Boogie's int(r): the largest integer not above r.
+// Denominators are positive, so Euclidean division rounds down.
+func RatFloor(a *big.Rat) *big.Int {
+	return new(big.Int).Div(a.Num(), a.Denom())
+}
@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+)
+
+// The entry point of generated programs (see emitMainWrapper in
+// package boogo).
+
+// Exit statuses of a generated program, besides 0 (success) and the
+// status 2 a panic (failed checked assertion) exits with.
+const (
+	exitAssertCount    = 1 // assertions failed under the "count" mode
+	exitAssumeViolated = 3 // a guarded assumption did not hold
+	exitAssumeFiltered = 4 // a filtering assumption discarded the run
+	exitExploreFailed  = 5 // exploration found a failing path
+)
+
+// RunMain runs the Boogie entry point and maps its outcome to an exit
+// status, so callers can tell assumption failures from assertion
+// failures. Failed runs report the choices needed to reproduce them.
+// With BOOGO_EXPLORE set it explores every path instead.
+func RunMain(entry func()) {
+	if os.Getenv("BOOGO_EXPLORE") != "" {
+		if !exploreMain(entry) {
+			os.Exit(exitExploreFailed)
+		}
+		return
+	}
+
+	defer func() {
+		r := recover()
+		dumpHeap("exit")
+		for _, exit := range exitHooks {
+			exit()
+		}
+
+		switch r := r.(type) {
+		case nil:
+			if n := assertFailures.Load(); n > 0 {
+				fmt.Fprintf(os.Stderr, "%d assertion(s) failed\n", n)
+				reportChoices()
+				os.Exit(exitAssertCount)
+			}
+		case *AssumptionViolated:
+			fmt.Fprintln(os.Stderr, r.Error())
+			reportChoices()
+			os.Exit(exitAssumeViolated)
+		case pathFiltered:
+			os.Exit(exitAssumeFiltered)
+		default:
+			reportChoices()
+			panic(r)
+		}
+	}()
+
+	entry()
+}
+
+var exitHooks []func()
+
+// OnExit registers exit to run when the entry point RunMain runs ends,
+// however it ends. Explored paths do not run it.
+func OnExit(exit func()) {
+	exitHooks = append(exitHooks, exit)
+}
@@ -0,0 +1,277 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Snapshots copy the heap out of a running program, for debugging: to
+// compare two points of a run (DiffHeap) or to look at one as JSON or
+// as a Graphviz object graph. BOOGO_HEAPDUMP=json or dot dumps the heap
+// to stderr at each failed assertion and at exit.
+
+// HeapSnapshot maps each object in the heap to a copy of its fields.
+type HeapSnapshot map[int]*ObjectSnapshot
+
+// ObjectSnapshot is one object of a HeapSnapshot. Fields holding
+// references are in Refs (0 is null); the others are in Fields,
+// formatted as Boogie literals. Under the checked heap, fields never
+// written are left out.
+type ObjectSnapshot struct {
+	Allocated bool              `json:"allocated"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Refs      map[string]int    `json:"refs,omitempty"`
+}
+
+// snapshotHeap copies the heap of the program (see OnSnapshot).
+var snapshotHeap func() HeapSnapshot
+
+// OnSnapshot registers snapshot to copy the program's heap; the
+// generated heap registers itself.
+func OnSnapshot(snapshot func() HeapSnapshot) {
+	snapshotHeap = snapshot
+}
+
+// SnapshotHeap returns a copy of the heap as it is now. A program
+// without a heap has an empty one.
+func SnapshotHeap() HeapSnapshot {
+	if snapshotHeap == nil {
+		return HeapSnapshot{}
+	}
+	return snapshotHeap()
+}
+
+func (s HeapSnapshot) objects() []int {
+	objs := make([]int, 0, len(s))
+	for o := range s {
+		objs = append(objs, o)
+	}
+	slices.Sort(objs)
+	return objs
+}
+
+// values returns every field of obj, references formatted as by
+// formatRef, keyed by field name.
+func (obj *ObjectSnapshot) values() map[string]string {
+	vals := make(map[string]string, len(obj.Fields)+len(obj.Refs))
+	for f, v := range obj.Fields {
+		vals[f] = v
+	}
+	for f, r := range obj.Refs {
+		vals[f] = formatRef(r)
+	}
+	return vals
+}
+
+func formatRef(r int) string {
+	if r == 0 {
+		return "null"
+	}
+	return "#" + strconv.Itoa(r)
+}
+
+// JSON formats s as a JSON object keyed by reference.
+func (s HeapSnapshot) JSON() string {
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err) // strings, ints and bools always marshal
+	}
+	return string(out)
+}
+
+// DOT formats s as a Graphviz digraph: a node per object, listing its
+// fields, and an edge per non-null reference field.
+func (s HeapSnapshot) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph heap {\n")
+	b.WriteString("\tnode [shape=record];\n")
+	for _, o := range s.objects() {
+		obj := s[o]
+		label := []string{formatRef(o)}
+		if !obj.Allocated {
+			label[0] += " (unallocated)"
+		}
+		for _, f := range sortedKeys(obj.Fields) {
+			label = append(label, dotEscape(f+" = "+obj.Fields[f]))
+		}
+		fmt.Fprintf(&b, "\t\"o%d\" [label=\"{%s}\"];\n", o, strings.Join(label, "|"))
+	}
+	for _, o := range s.objects() {
+		obj := s[o]
+		for _, f := range sortedKeys(obj.Refs) {
+			if r := obj.Refs[f]; r != 0 {
+				fmt.Fprintf(&b, "\t\"o%d\" -> \"o%d\" [label=\"%s\"];\n", o, r, dotEscape(f))
+			}
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotEscape escapes the characters of a record label Graphviz would
+// otherwise read as structure.
+func dotEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`)
+	return r.Replace(s)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// HeapDiff is what changed from one snapshot to another.
+type HeapDiff struct {
+	Added   []int // objects only in the later snapshot
+	Removed []int // objects only in the earlier one
+	Changed []FieldChange
+}
+
+// FieldChange is a field of an object in both snapshots whose value
+// differs. A field missing from a snapshot is empty in it.
+type FieldChange struct {
+	Obj      int
+	Field    string
+	Old, New string
+}
+
+// DiffHeap compares two snapshots of the heap.
+func DiffHeap(before, after HeapSnapshot) HeapDiff {
+	var d HeapDiff
+	for _, o := range after.objects() {
+		if _, ok := before[o]; !ok {
+			d.Added = append(d.Added, o)
+		}
+	}
+	for _, o := range before.objects() {
+		obj, ok := after[o]
+		if !ok {
+			d.Removed = append(d.Removed, o)
+			continue
+		}
+
+		old, cur := before[o].values(), obj.values()
+		if before[o].Allocated != obj.Allocated {
+			old["alloc"], cur["alloc"] = strconv.FormatBool(before[o].Allocated), strconv.FormatBool(obj.Allocated)
+		}
+		fields := sortedKeys(old)
+		for _, f := range sortedKeys(cur) {
+			if _, ok := old[f]; !ok {
+				fields = append(fields, f)
+			}
+		}
+		slices.Sort(fields)
+		for _, f := range fields {
+			if old[f] != cur[f] {
+				d.Changed = append(d.Changed, FieldChange{Obj: o, Field: f, Old: old[f], New: cur[f]})
+			}
+		}
+	}
+	return d
+}
+
+// Empty reports whether nothing changed.
+func (d HeapDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String lists the changes a line each: + for an added object, - for a
+// removed one, and ~ for a changed field.
+func (d HeapDiff) String() string {
+	var b strings.Builder
+	for _, o := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", formatRef(o))
+	}
+	for _, o := range d.Removed {
+		fmt.Fprintf(&b, "- %s\n", formatRef(o))
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(&b, "~ %s.%s: %s -> %s\n", formatRef(c.Obj), c.Field, c.Old, c.New)
+	}
+	return b.String()
+}
+
+// dumpHeap writes the heap to stderr, as BOOGO_HEAPDUMP asks, saying
+// where the program is.
+func dumpHeap(where string) {
+	format := os.Getenv("BOOGO_HEAPDUMP")
+	if format == "" || snapshotHeap == nil {
+		return
+	}
+
+	s := SnapshotHeap()
+	var out string
+	switch format {
+	case "json":
+		out = s.JSON() + "\n"
+	case "dot":
+		out = s.DOT()
+	default:
+		panic("BOOGO_HEAPDUMP: want json or dot, got " + format)
+	}
+	fmt.Fprintf(os.Stderr, "boogo: heap at %s:\n%s", where, out)
+}
+
+// FormatValue formats a field value as a Boogie literal, for
+// snapshots. Bitvectors go through FormatBv instead, since their Go
+// type does not say their width.
+func FormatValue(v any) string {
+	switch v := v.(type) {
+	case *big.Int:
+		return v.String()
+	case *big.Rat:
+		return formatRat(v)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatRat formats r as a decimal when it has a finite one, as 2.5,
+// and as a fraction, as 1/3, otherwise.
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String() + ".0"
+	}
+
+	digits := 0
+	d := new(big.Int).Set(r.Denom())
+	for _, p := range []int64{2, 5} {
+		n := 0
+		q, m := new(big.Int), new(big.Int)
+		for {
+			q.QuoRem(d, big.NewInt(p), m)
+			if m.Sign() != 0 {
+				break
+			}
+			d.Set(q)
+			n++
+		}
+		digits = max(digits, n)
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return r.RatString()
+	}
+	return r.FloatString(digits)
+}
+
+// FormatBv formats a bitvector of width w up to 64 bits as a Boogie
+// literal, as 255bv8.
+func FormatBv(v uint64, w uint) string {
+	return strconv.FormatUint(v, 10) + "bv" + strconv.FormatUint(uint64(w), 10)
+}
+
+// FormatBigBv is FormatBv for bitvectors wider than 64 bits.
+func FormatBigBv(v *big.Int, w uint) string {
+	return v.String() + "bv" + strconv.FormatUint(uint64(w), 10)
+}
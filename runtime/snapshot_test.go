@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestDiffHeap(t *testing.T) {
+	before := HeapSnapshot{
+		1: {Allocated: true, Fields: map[string]string{"val": "1"}, Refs: map[string]int{"next": 0}},
+		2: {Allocated: true, Fields: map[string]string{"val": "2"}},
+	}
+	after := HeapSnapshot{
+		1: {Allocated: true, Fields: map[string]string{"val": "3", "seen": "true"}, Refs: map[string]int{"next": 3}},
+		3: {Allocated: true},
+	}
+
+	d := DiffHeap(before, after)
+	want := "+ #3\n- #2\n~ #1.next: null -> #3\n~ #1.seen:  -> true\n~ #1.val: 1 -> 3\n"
+	if got := d.String(); got != want {
+		t.Errorf("diff is\n%s\nwant\n%s", got, want)
+	}
+	if !DiffHeap(after, after).Empty() {
+		t.Error("a snapshot differs from itself")
+	}
+}
+
+func TestHeapDumps(t *testing.T) {
+	s := HeapSnapshot{
+		1: {Allocated: true, Fields: map[string]string{"m": "[1: true, default: false]"}, Refs: map[string]int{"next": 2}},
+		2: {Refs: map[string]int{"next": 0}},
+	}
+
+	dot := s.DOT()
+	for _, want := range []string{
+		`"o1" [label="{#1|m = [1: true, default: false]}"];`,
+		`"o2" [label="{#2 (unallocated)}"];`,
+		`"o1" -> "o2" [label="next"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("expected %s in\n%s", want, dot)
+		}
+	}
+	if strings.Contains(dot, `"o2" -> `) {
+		t.Errorf("null reference drawn as an edge:\n%s", dot)
+	}
+
+	if js := s.JSON(); !strings.Contains(js, `"2": {`+"\n"+`    "allocated": false,`) {
+		t.Errorf("unexpected JSON:\n%s", js)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{big.NewRat(5, 2), "2.5"},
+		{big.NewRat(4, 1), "4.0"},
+		{big.NewRat(1, 3), "1/3"},
+		{big.NewInt(-7), "-7"},
+		{true, "true"},
+		{MapNew[int, int](0).Set(2, 4).Set(1, 3), "[1: 3, 2: 4, default: 0]"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatValue(tt.v); got != tt.want {
+			t.Errorf("FormatValue(%v) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+	if got := FormatBv(255, 8); got != "255bv8" {
+		t.Errorf("FormatBv(255, 8) = %s", got)
+	}
+}
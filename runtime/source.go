@@ -0,0 +1,17 @@
+// Package runtime is the library programs generated by boogo run on:
+// unbounded integers, exact reals, persistent maps, bitvectors, the
+// checks erasure keeps, the oracle resolving nondeterminism, path
+// exploration and the entry point.
+//
+// Generated programs import it, unqualified, or carry a copy of its
+// source (see boogo.RuntimeInline), so the names they call are
+// exported.
+package runtime
+
+import "embed"
+
+// Source holds the runtime's own files, for inlining into standalone
+// programs. source.go and the tests are not part of the runtime proper.
+//
+//go:embed *.go
+var Source embed.FS
@@ -0,0 +1,33 @@
+package runtime
+
+import (
+	"math/big"
+)
+
+// Helpers for procedures with type parameters (see codegen/types.go).
+
+// ZeroOf is the Boogie zero of a type parameter's instantiation: Go's
+// zero value, except that ints, reals and wide bitvectors are pointers
+// and start out as 0 rather than nil.
+func ZeroOf[T any]() T {
+	var z T
+	switch p := any(&z).(type) {
+	case **big.Int:
+		*p = new(big.Int)
+	case **big.Rat:
+		*p = new(big.Rat)
+	}
+	return z
+}
+
+// ValueEq is == on values of a type parameter, comparing big integers
+// and rationals by value.
+func ValueEq(a, b any) bool {
+	switch x := a.(type) {
+	case *big.Int:
+		return x.Cmp(b.(*big.Int)) == 0
+	case *big.Rat:
+		return x.Cmp(b.(*big.Rat)) == 0
+	}
+	return a == b
+}
@@ -0,0 +1,61 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestAllocE2E(t *testing.T) {
+	src, err := os.ReadFile("alloc.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for name, ints := range intModes {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "alloc.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", name, err)
+		}
+
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("%s: allocation semantics diverged: exit %d\n%s", name, code, stderr)
+		}
+	}
+}
+
+func TestNullDereference(t *testing.T) {
+	const decls = "type Field _; const f: Field int; var H: <a>[ref, Field a]a;\n"
+	tests := []struct {
+		src, want string
+	}{
+		{
+			decls + "procedure main() { var x: int; x := H[null, f]; }",
+			"null.bpl:2:37: null dereference: Heap[null, f]",
+		},
+		{
+			decls + "procedure main() modifies H; { var o: ref; H[o, f] := 1; }",
+			"null.bpl:2:44: null dereference: Heap[o, f]",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := boogo.RunWithOptions([]byte(tt.src), boogo.Options{Filename: "null.bpl"})
+		if err != nil {
+			t.Fatalf("unexpected failure: %v", err)
+		}
+
+		stderr, code := runGenerated(t, out)
+		if code != 2 || !strings.Contains(stderr, tt.want) {
+			t.Fatalf("expected %q: exit %d\n%s", tt.want, code, stderr)
+		}
+	}
+}
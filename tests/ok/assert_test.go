@@ -0,0 +1,54 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestAssertE2E(t *testing.T) {
+	src, err := os.ReadFile("assert.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	out, err := boogo.Run(src)
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	// Only the explicitly logged assertion survives the default policy.
+	if strings.Contains(out, `AssertPanic("`) {
+		t.Fatalf("assertion not erased by default:\n%s", out)
+	}
+
+	if !strings.Contains(out, `AssertLog("10:3", "y >= 0")`) {
+		t.Fatalf("missing logged assertion:\n%s", out)
+	}
+}
+
+func TestAssertPanicE2E(t *testing.T) {
+	src, err := os.ReadFile("assert.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Filename: "assert.bpl",
+		Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	if !strings.Contains(out, `AssertPanic("assert.bpl:9:3", "y <= 10")`) {
+		t.Fatalf("missing checked assertion:\n%s", out)
+	}
+
+	if !strings.Contains(out, `AssertLog("assert.bpl:10:3", "y >= 0")`) {
+		t.Fatalf("attribute did not override policy:\n%s", out)
+	}
+}
@@ -0,0 +1,29 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+)
+
+func TestAssumeE2E(t *testing.T) {
+	src, err := os.ReadFile("assume.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	out, err := boogo.Run(src)
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	if !strings.Contains(out, `AssumeGuard("3:3", "x >= 0")`) {
+		t.Fatalf("missing guarded assumption:\n%s", out)
+	}
+
+	if !strings.Contains(out, `AssumeFilter("4:3", "x < 100")`) {
+		t.Fatalf("missing filtering assumption:\n%s", out)
+	}
+}
@@ -0,0 +1,155 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestBigIntE2E(t *testing.T) {
+	src, err := os.ReadFile("bigint.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Erase: ebs.Policy{Asserts: ebs.AssertPanic},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	if !strings.Contains(out, `BigLit("100000000000000000000000000000")`) {
+		t.Fatalf("large literal not emitted as big integer:\n%s", out)
+	}
+
+	if stderr, code := runGenerated(t, out); code != 0 {
+		t.Fatalf("big integer arithmetic diverged: exit %d\n%s", code, stderr)
+	}
+}
+
+func TestNativeIntOptIn(t *testing.T) {
+	src := []byte(`procedure main()
+{
+  var x: int;
+  x := 9223372036854775807;
+  x := x + 1;
+  assert x > 0;
+}`)
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Erase:   ebs.Policy{Asserts: ebs.AssertPanic},
+		Codegen: codegen.Options{Ints: codegen.IntNative},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	if !strings.Contains(out, "var x int\n") {
+		t.Fatalf("expected native int local:\n%s", out)
+	}
+
+	// Go int wraps around: the fast path trades exactness for speed.
+	stderr, code := runGenerated(t, out)
+	if code == 0 || !strings.Contains(stderr, "assertion failed: x > 0") {
+		t.Fatalf("expected wraparound to fail the assertion: exit %d\n%s", code, stderr)
+	}
+}
+
+func TestCheckedIntOverflow(t *testing.T) {
+	src := []byte(`procedure main()
+{
+  var x: int;
+  x := 9223372036854775807;
+  x := x + 1;
+}`)
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Filename: "overflow.bpl",
+		Codegen:  codegen.Options{Ints: codegen.IntChecked},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	if !strings.Contains(out, "var x int64\n") {
+		t.Fatalf("expected int64 local:\n%s", out)
+	}
+
+	// Checked mode is fast, but refuses to silently diverge from Boogie.
+	stderr, code := runGenerated(t, out)
+	if code == 0 || !strings.Contains(stderr, "overflow.bpl:5:8: integer overflow: x + 1") {
+		t.Fatalf("expected overflow to be reported: exit %d\n%s", code, stderr)
+	}
+}
+
+func TestCheckedIntMinLiteral(t *testing.T) {
+	// -9223372036854775808 is one literal, MinInt64, not the negation
+	// of a literal beyond MaxInt64.
+	src := []byte(`procedure main()
+{
+  var x: int;
+  x := -9223372036854775808;
+  assert x < 0 && x + 1 == -9223372036854775807;
+  assert -x - 1 == 9223372036854775806 - -1;
+}`)
+
+	for name, ints := range intModes {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "min.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", name, err)
+		}
+		if ints == codegen.IntChecked && strings.Contains(out, "CheckedNeg(9223372036854775808") {
+			t.Fatalf("%s: negated literal not folded:\n%s", name, out)
+		}
+
+		stderr, code := runGenerated(t, out)
+		if ints == codegen.IntChecked {
+			// -x overflows int64, as checked ints report.
+			if code == 0 || !strings.Contains(stderr, "min.bpl:6:10: integer overflow: -x") {
+				t.Fatalf("%s: expected -x to overflow: exit %d\n%s", name, code, stderr)
+			}
+		} else if code != 0 {
+			t.Fatalf("%s: exit %d\n%s", name, code, stderr)
+		}
+	}
+}
+
+func TestPredeclaredNames(t *testing.T) {
+	// Locals named after the Go types ints and bitvectors map to.
+	src := []byte(`procedure main()
+{
+  var int64: int;
+  var uint8: bv8;
+  var y: int;
+  var any, nil, string: int;
+  int64 := 1;
+  uint8 := 2bv8;
+  y := int64 + 1;
+  any := y;
+  nil := y;
+  string := y;
+  assert y == 2 && uint8 == 2bv8 && any + nil + string == 6;
+}`)
+
+	for _, ints := range []codegen.IntMode{codegen.IntChecked, codegen.IntRange} {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Erase:   ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen: codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("int mode %v: unexpected failure: %v", ints, err)
+		}
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("int mode %v: exit %d\n%s\n%s", ints, code, stderr, out)
+		}
+	}
+}
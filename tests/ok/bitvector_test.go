@@ -0,0 +1,40 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestBitvectorE2E(t *testing.T) {
+	src, err := os.ReadFile("bitvector.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Filename: "bitvector.bpl",
+		Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	for _, want := range []string{
+		"var a uint8\n",
+		"var b uint8\n", // bv5, masked to 5 bits
+		"h := new(big.Int)\n",
+		"a = (a * uint8(128))\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	if stderr, code := runGenerated(t, out); code != 0 {
+		t.Fatalf("bitvector semantics diverged: exit %d\n%s", code, stderr)
+	}
+}
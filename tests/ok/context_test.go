@@ -0,0 +1,151 @@
+package ok
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+// contextDriver runs list and peek from context.bpl in 16 goroutines,
+// each with a Context of its own, then, if shareable is set, in 16
+// sharing one.
+// Own heaps hand out the same references; a shared heap never hands one
+// out twice.
+const contextDriver = `
+func main() {
+	const n = 16
+	var wg sync.WaitGroup
+	heads := make([]int, n)
+	run := func(ctx func() *Context) {
+		for i := range heads {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				heads[i] = list(ctx())
+				peek(ctx(), 1000+i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	run(NewContext)
+	for _, h := range heads {
+		if h != 3 {
+			fmt.Fprintln(os.Stderr, "own contexts: head", h)
+			os.Exit(1)
+		}
+	}
+
+	if !shareable {
+		return
+	}
+	shared := NewContext()
+	run(func() *Context { return shared })
+	seen := map[int]bool{}
+	for _, h := range heads {
+		if h < 1 || h > 3*n || seen[h] {
+			fmt.Fprintln(os.Stderr, "shared context: head", h)
+			os.Exit(1)
+		}
+		seen[h] = true
+	}
+}
+`
+
+func TestContextConcurrent(t *testing.T) {
+	src, err := os.ReadFile("context.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for _, state := range []codegen.StateMode{codegen.StateContext, codegen.StateShared} {
+		for name, ints := range intModes {
+			out, err := boogo.RunWithOptions(src, boogo.Options{
+				Filename: "context.bpl",
+				Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+				Codegen:  codegen.Options{Ints: ints, State: state},
+			})
+			if err != nil {
+				t.Fatalf("%s: unexpected failure: %v", name, err)
+			}
+			if !strings.Contains(out, "func list(ctx *Context) (head int)") {
+				t.Fatalf("%s: expected list to take the Context:\n%s", name, out)
+			}
+
+			// The driver needs a few more imports than the procedures.
+			out = strings.Replace(out, "import (\n", "import (\n\t\"fmt\"\n\t\"os\"\n\t\"sync\"\n", 1)
+			shareable := fmt.Sprintf("\nconst shareable = %v\n", state == codegen.StateShared)
+			stderr, code := runGenerated(t, out+contextDriver+shareable)
+			if code != 0 {
+				t.Fatalf("%s, state %d: exit %d\n%s", name, state, code, stderr)
+			}
+		}
+	}
+}
+
+// TestContextMain runs an entry point in a Context, checking that the
+// runtime's heap statistics look at its heap.
+func TestContextMain(t *testing.T) {
+	src, err := os.ReadFile("heapcheck.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for _, state := range []codegen.StateMode{codegen.StateContext, codegen.StateShared} {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "heapcheck.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Heap: codegen.HeapChecked, State: state},
+		})
+		if err != nil {
+			t.Fatalf("state %d: unexpected failure: %v", state, err)
+		}
+
+		stderr, code := runGenerated(t, out, "BOOGO_HEAPSTATS=1")
+		if code != 0 {
+			t.Fatalf("state %d: exit %d\n%s", state, code, stderr)
+		}
+		want := "boogo: heap: 2 live object(s), 5 field write(s) (alloc: 1, next: 2, val: 2)"
+		if !strings.Contains(stderr, want) {
+			t.Fatalf("state %d: expected %q in\n%s", state, want, stderr)
+		}
+
+		// Explored paths each start from an empty heap.
+		stderr, code = runGenerated(t, out, "BOOGO_EXPLORE=1")
+		if code != 0 {
+			t.Fatalf("state %d: explore: exit %d\n%s", state, code, stderr)
+		}
+	}
+}
+
+// TestContextRace runs contextDriver under the race detector: contexts
+// of their own share nothing, and a shared one is locked.
+func TestContextRace(t *testing.T) {
+	src, err := os.ReadFile("context.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for _, state := range []codegen.StateMode{codegen.StateContext, codegen.StateShared} {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "context.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{State: state},
+		})
+		if err != nil {
+			t.Fatalf("state %d: unexpected failure: %v", state, err)
+		}
+
+		out = strings.Replace(out, "import (\n", "import (\n\t\"fmt\"\n\t\"os\"\n\t\"sync\"\n", 1)
+		shareable := fmt.Sprintf("\nconst shareable = %v\n", state == codegen.StateShared)
+		stderr, code := runGeneratedRace(t, out+contextDriver+shareable)
+		if code != 0 {
+			t.Fatalf("state %d: exit %d\n%s", state, code, stderr)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+var intModes = map[string]codegen.IntMode{
+	"big":     codegen.IntBig,
+	"native":  codegen.IntNative,
+	"checked": codegen.IntChecked,
+	"range":   codegen.IntRange,
+}
+
+func TestDivModE2E(t *testing.T) {
+	src, err := os.ReadFile("divmod.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for name, ints := range intModes {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "divmod.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", name, err)
+		}
+
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("%s: div/mod semantics diverged: exit %d\n%s", name, code, stderr)
+		}
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{
+			"procedure main() { var a: int; var b: int; a := 1; a := a div b; }",
+			"zero.bpl:1:57: division by zero: a div b",
+		},
+		{
+			"procedure main() { var a: int; var b: int; a := 1; a := a mod b; }",
+			"zero.bpl:1:57: division by zero: a mod b",
+		},
+		{
+			"procedure main() { var r: real; r := 1.0 / r; }",
+			"zero.bpl:1:38: division by zero: 1.0 / r",
+		},
+	}
+
+	// Every execution mode faults at the division, whatever the
+	// representation of the operands.
+	for name, ints := range intModes {
+		for _, tt := range tests {
+			out, err := boogo.RunWithOptions([]byte(tt.src), boogo.Options{
+				Filename: "zero.bpl",
+				Codegen:  codegen.Options{Ints: ints},
+			})
+			if err != nil {
+				t.Fatalf("%s: unexpected failure: %v", name, err)
+			}
+
+			stderr, code := runGenerated(t, out)
+			if code == 0 || !strings.Contains(stderr, tt.want) {
+				t.Fatalf("%s: expected %q: exit %d\n%s", name, tt.want, code, stderr)
+			}
+		}
+	}
+}
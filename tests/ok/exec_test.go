@@ -0,0 +1,128 @@
+package ok
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGenerated builds and runs a generated Go program with extra
+// environment variables, returning its stderr and exit status. The
+// program is built in a module that resolves the runtime package to
+// this tree.
+func runGenerated(t *testing.T, out string, env ...string) (string, int) {
+	t.Helper()
+	return runGeneratedWith(t, out, nil, env...)
+}
+
+// runGeneratedWith is runGenerated with more files in the module, such
+// as packages the program imports, keyed by their slash-separated
+// paths in it.
+func runGeneratedWith(t *testing.T, out string, files map[string]string, env ...string) (string, int) {
+	t.Helper()
+	return buildAndRun(t, generatedModule(t, files), out, env)
+}
+
+// buildGeneratedWith builds a generated Go program as runGeneratedWith
+// does, without running it, returning the compiler's complaints.
+func buildGeneratedWith(t *testing.T, out string, files map[string]string) error {
+	t.Helper()
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := generatedModule(t, files)
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(out), 0o644); err != nil {
+		t.Fatalf("write output: %v", err)
+	}
+	build := exec.Command("go", "build", "-o", filepath.Join(dir, "prog"), "main.go")
+	build.Dir = dir
+	if msg, err := build.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v\n%s", err, msg)
+	}
+	return nil
+}
+
+// generatedModule creates a module that resolves the runtime package
+// to this tree, holding files.
+func generatedModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatalf("locate module root: %v", err)
+	}
+	dir := t.TempDir()
+	mod := "module generated\n\ngo 1.22\n\n" +
+		"require github.com/ezrantn/boogo v0.0.0\n\n" +
+		"replace github.com/ezrantn/boogo => " + root + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(mod), 0o644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	for name, src := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// runStandalone is runGenerated for a program carrying its own
+// runtime, built outside any module.
+func runStandalone(t *testing.T, out string, env ...string) (string, int) {
+	t.Helper()
+	return buildAndRun(t, t.TempDir(), out, env)
+}
+
+// runGeneratedRace is runGenerated for a program built with the race
+// detector, which reports races by exiting with status 66.
+func runGeneratedRace(t *testing.T, out string, env ...string) (string, int) {
+	t.Helper()
+
+	cgo, err := exec.Command("go", "env", "CGO_ENABLED").Output()
+	if err != nil || strings.TrimSpace(string(cgo)) != "1" {
+		t.Skip("race detector not available")
+	}
+	return buildAndRun(t, generatedModule(t, nil), out, env, "-race")
+}
+
+func buildAndRun(t *testing.T, dir, out string, env []string, flags ...string) (string, int) {
+	t.Helper()
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte(out), 0o644); err != nil {
+		t.Fatalf("write output: %v", err)
+	}
+
+	bin := filepath.Join(dir, "prog")
+	build := exec.Command("go", append(append([]string{"build"}, flags...), "-o", bin, file)...)
+	build.Dir = dir
+	if msg, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not build: %v\n%s\n%s", err, msg, out)
+	}
+
+	cmd := exec.Command(bin)
+	cmd.Env = append(os.Environ(), env...)
+	stderr, err := cmd.CombinedOutput()
+
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return string(stderr), exit.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	return string(stderr), 0
+}
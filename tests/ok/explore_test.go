@@ -0,0 +1,62 @@
+package ok
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestExploreE2E(t *testing.T) {
+	src, err := os.ReadFile("explore.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Filename: "explore.bpl",
+		Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	// x and y range over -2..2 and b over both values; the 10 (x, y)
+	// pairs with x < 0 are infeasible, the other 15 fork on b.
+	stderr, code := runGenerated(t, out, "BOOGO_EXPLORE=10")
+	if code != 5 {
+		t.Fatalf("exploration exited with %d, want 5\n%s", code, stderr)
+	}
+
+	if !strings.Contains(stderr, "explored 30 path(s), 10 infeasible, 0 cut") {
+		t.Fatalf("unexpected path counts:\n%s", stderr)
+	}
+
+	if !strings.Contains(stderr, "explore.bpl:14:3: assertion failed: y < 3") {
+		t.Fatalf("failure not reported:\n%s", stderr)
+	}
+
+	m := regexp.MustCompile(`BOOGO_REPLAY=\S+`).FindString(stderr)
+	if m == "" {
+		t.Fatalf("no trace reported:\n%s", stderr)
+	}
+
+	stderr, code = runGenerated(t, out, m)
+	if code == 0 || !strings.Contains(stderr, "assertion failed: y < 3") {
+		t.Fatalf("%s does not reproduce the failure: exit %d\n%s", m, code, stderr)
+	}
+
+	// Restricting x and y to 0..1 leaves no failing path.
+	if stderr, code := runGenerated(t, out, "BOOGO_EXPLORE=10", "BOOGO_EXPLORE_INTS=0..1"); code != 0 {
+		t.Fatalf("bounded exploration exited with %d\n%s", code, stderr)
+	}
+
+	// A depth of 2 cuts every path before b is chosen.
+	stderr, _ = runGenerated(t, out, "BOOGO_EXPLORE=2")
+	if !strings.Contains(stderr, "explored 0 path(s), 10 infeasible, 15 cut at depth 2") {
+		t.Fatalf("depth bound not applied:\n%s", stderr)
+	}
+}
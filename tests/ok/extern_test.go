@@ -0,0 +1,94 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+// externPackage implements the functions extern.bpl binds to
+// generated/ext, on the *big.Int ints are under IntBig.
+const externPackage = `package ext
+
+import "math/big"
+
+func Gcd(a, b *big.Int) *big.Int {
+	return new(big.Int).GCD(nil, nil, a, b)
+}
+
+func Fib(n *big.Int) *big.Int {
+	a, b := big.NewInt(0), big.NewInt(1)
+	for i := int64(0); i < n.Int64(); i++ {
+		a, b = b, new(big.Int).Add(a, b)
+	}
+	return a
+}
+
+// Collatz counts the steps n takes to reach 1.
+func Collatz(n *big.Int) *big.Int {
+	steps := int64(0)
+	for m := n.Int64(); m != 1; steps++ {
+		if m%2 == 0 {
+			m /= 2
+		} else {
+			m = 3*m + 1
+		}
+	}
+	return big.NewInt(steps)
+}
+`
+
+func TestExternE2E(t *testing.T) {
+	src, err := os.ReadFile("extern.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Filename: "extern.bpl",
+		Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+		Externs:  map[string]string{"collatz": "generated/ext.Collatz"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+	for _, want := range []string{
+		`ext_math_2f_bits_ "math/bits"`,
+		`ext_generated_2f_ext_ "generated/ext"`,
+		"// rev8 is bound to math/bits.Reverse8.\nfunc rev8(x0 uint8) uint8 {",
+		"return memo_fib.Do(",
+		"return ext_generated_2f_ext_.Collatz(n)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	files := map[string]string{"ext/ext.go": externPackage}
+	stderr, code := runGeneratedWith(t, out, files)
+	if code != 0 {
+		t.Fatalf("exit %d\n%s", code, stderr)
+	}
+
+}
+
+func TestExternNative(t *testing.T) {
+	// Under IntNative, ints are Go ints, as math/bits counts in.
+	src := []byte(`function {:extern "math/bits.OnesCount8"} ones(bv8) returns (int);
+procedure main() { assert ones(7bv8) == 3; }`)
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Erase:   ebs.Policy{Asserts: ebs.AssertPanic},
+		Codegen: codegen.Options{Ints: codegen.IntNative},
+		Runtime: boogo.RuntimeInline,
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+	if stderr, code := runStandalone(t, out); code != 0 {
+		t.Fatalf("exit %d\n%s", code, stderr)
+	}
+}
@@ -0,0 +1,84 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+// svcPackage implements the procedures externproc.bpl binds to
+// generated/svc, on the *big.Int ints are under IntBig.
+const svcPackage = `package svc
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+)
+
+var input = []int64{17, 5}
+
+func Read() (*big.Int, bool) {
+	if len(input) == 0 {
+		return new(big.Int), false
+	}
+	x := input[0]
+	input = input[1:]
+	return big.NewInt(x), true
+}
+
+func Log(x *big.Int, big bool) {
+	fmt.Fprintf(os.Stderr, "log: %v %v\n", x, big)
+}
+
+func DivMod(a, b *big.Int) (*big.Int, *big.Int) {
+	return new(big.Int).DivMod(a, b, new(big.Int))
+}
+
+func Store(x *big.Int) {
+	fmt.Fprintf(os.Stderr, "stored: %v\n", x)
+}
+`
+
+func TestExternProcE2E(t *testing.T) {
+	src, err := os.ReadFile("externproc.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for _, state := range []codegen.StateMode{codegen.StateGlobal, codegen.StateContext} {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "externproc.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{State: state},
+			Externs:  map[string]string{"Store": "generated/svc.Store"},
+		})
+		if err != nil {
+			t.Fatalf("state %d: unexpected failure: %v", state, err)
+		}
+		if want := "var _ func(*big.Int, *big.Int) (*big.Int, *big.Int) = ext_generated_2f_svc_.DivMod"; !strings.Contains(out, want) {
+			t.Fatalf("state %d: expected %q in output:\n%s", state, want, out)
+		}
+
+		files := map[string]string{"svc/svc.go": svcPackage}
+		stderr, code := runGeneratedWith(t, out, files)
+		if code != 0 {
+			t.Fatalf("state %d: exit %d\n%s", state, code, stderr)
+		}
+		if want := "log: 3 true\nlog: 2 false\nstored: 17\n"; stderr != want {
+			t.Fatalf("state %d: output is\n%s\nwant\n%s", state, stderr, want)
+		}
+
+		// A binding of another signature does not build, even one the
+		// call would convert to.
+		wrong := strings.Replace(svcPackage, "func Log(x *big.Int, big bool)", "func Log(x *big.Int, big bool, more ...int)", 1)
+		err = buildGeneratedWith(t, out, map[string]string{"svc/svc.go": wrong})
+		if err == nil || !strings.Contains(err.Error(), "ext_generated_2f_svc_.Log") {
+			t.Fatalf("state %d: a binding of the wrong signature built: %v", state, err)
+		}
+	}
+}
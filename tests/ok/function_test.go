@@ -0,0 +1,46 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestFunctionE2E(t *testing.T) {
+	src, err := os.ReadFile("function.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for name, ints := range intModes {
+		for _, state := range []codegen.StateMode{codegen.StateGlobal, codegen.StateContext} {
+			out, err := boogo.RunWithOptions(src, boogo.Options{
+				Filename: "function.bpl",
+				Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+				Codegen:  codegen.Options{Ints: ints, State: state},
+			})
+			if err != nil {
+				t.Fatalf("%s: unexpected failure: %v", name, err)
+			}
+
+			// Functions are pure, so they take no Context.
+			for _, want := range []string{"func inc(x ", "return memo_sq.Do(", "func max_(a ", "func pick[T_T any](c bool, "} {
+				if !strings.Contains(out, want) {
+					t.Fatalf("%s: expected %q in output:\n%s", name, want, out)
+				}
+			}
+			if strings.Contains(out, "func add8") {
+				t.Fatalf("%s: {:bvbuiltin} function emitted:\n%s", name, out)
+			}
+
+			stderr, code := runGenerated(t, out)
+			if code != 0 {
+				t.Fatalf("%s, state %d: exit %d\n%s", name, state, code, stderr)
+			}
+		}
+	}
+}
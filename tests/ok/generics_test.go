@@ -0,0 +1,66 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestGenericsE2E(t *testing.T) {
+	src, err := os.ReadFile("generics.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for name, ints := range intModes {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "generics.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", name, err)
+		}
+
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("%s: generic code diverged: exit %d\n%s", name, code, stderr)
+		}
+	}
+}
+
+func TestGenericsEmitGoGenerics(t *testing.T) {
+	src := []byte(`type Field _;
+procedure get<T>(h: <a>[Field a]a, f: Field T) returns (v: T)
+{
+  v := h[f];
+}
+procedure main()
+{
+  var h: <a>[Field a]a;
+  var f: Field int;
+  var x: int;
+  call x := get(h, f);
+}`)
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Codegen: codegen.Options{Ints: codegen.IntNative},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	for _, want := range []string{
+		"type Field[_ any] int\n",
+		"func get[T_T any](h Map[any, any], f Field[T_T]) (v T_T) {\n",
+		"v = PolyGet[T_T](h, f, ZeroOf[T_T]())\n",
+		"x = get[int](h, f)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ok
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/boogie"
+	"github.com/ezrantn/boogo/boogie/cfg"
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+// goto A, B, C;  A: x := 1;  B: assume false;  C: x := 3;  then assert x < 3
+func TestNondeterministicGotoE2E(t *testing.T) {
+	x := boogie.Var{Name: "x", Ty: boogie.IntType{}}
+	xe := &boogie.VarExpr{V: x}
+
+	set := func(v int) []boogie.Stmt {
+		return []boogie.Stmt{
+			&boogie.Assign{Lhs: xe, Rhs: &boogie.IntLit{Value: v}},
+			&boogie.Assert{
+				Cond: &boogie.BinOp{Op: boogie.Lt, Left: xe, Right: &boogie.IntLit{Value: 3}, Ty: boogie.BoolType{}},
+				Pos:  boogie.Pos{Line: 9, Col: 1},
+			},
+		}
+	}
+
+	blocks := []*cfg.Block{
+		{ID: 0, Term: &cfg.Goto{Targets: []cfg.BlockID{1, 2, 3}, Pos: boogie.Pos{Line: 2, Col: 3}}},
+		{ID: 1, Stmts: set(1), Term: &cfg.Return{}},
+		{ID: 2, Stmts: []boogie.Stmt{&boogie.Assume{Cond: &boogie.BoolLit{Value: false}}}, Term: &cfg.Return{}},
+		{ID: 3, Stmts: set(3), Term: &cfg.Return{}},
+	}
+
+	body, err := cfg.Structure(cfg.BuildCFG(blocks, 0))
+	if err != nil {
+		t.Fatalf("structure: %v", err)
+	}
+
+	prog := &boogie.Program{
+		Procs: []*boogie.Procedure{{Name: "main", Locals: []boogie.Var{x}, Body: body}},
+	}
+
+	if err := ebs.Check(prog); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	out := boogo.EmitProgram(ebs.EraseWith(prog, ebs.Policy{Asserts: ebs.AssertPanic}))
+
+	if !strings.Contains(out, `switch ChooseBranch("2:3 goto", true, false, true) {`) {
+		t.Fatalf("missing runtime choice:\n%s", out)
+	}
+
+	// The blocked target B is never offered: choice 1 is the second
+	// enabled target, C.
+	if _, code := runGenerated(t, out, "BOOGO_REPLAY=0"); code != 0 {
+		t.Fatalf("choosing A exited with %d", code)
+	}
+
+	stderr, code := runGenerated(t, out, "BOOGO_REPLAY=1")
+	if code == 0 || !strings.Contains(stderr, "9:1: assertion failed: x < 3") {
+		t.Fatalf("choosing C: exit %d\n%s", code, stderr)
+	}
+}
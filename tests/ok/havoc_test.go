@@ -0,0 +1,46 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestHavocReplayE2E(t *testing.T) {
+	src, err := os.ReadFile("havoc.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Filename: "havoc.bpl",
+		Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	if !strings.Contains(out, `x = HavocBigInt("havoc.bpl:5:3 x")`) {
+		t.Fatalf("missing havoc of x:\n%s", out)
+	}
+
+	if _, code := runGenerated(t, out, "BOOGO_REPLAY=3,1"); code != 0 {
+		t.Fatalf("replayed passing run exited with %d", code)
+	}
+
+	if _, code := runGenerated(t, out, "BOOGO_REPLAY=-3,1"); code != 4 {
+		t.Fatalf("replayed filtered run exited with %d, want 4", code)
+	}
+
+	stderr, code := runGenerated(t, out, "BOOGO_REPLAY=700,1")
+	if code == 0 || !strings.Contains(stderr, "havoc.bpl:8:5: assertion failed: x < 500") {
+		t.Fatalf("replayed failing run: exit %d\n%s", code, stderr)
+	}
+
+	if !strings.Contains(stderr, "BOOGO_REPLAY=700,1") {
+		t.Fatalf("failure does not report its choices:\n%s", stderr)
+	}
+}
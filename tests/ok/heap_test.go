@@ -1,41 +1,135 @@
 package ok
 
 import (
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/ezrantn/boogo/boogie"
 	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
 	"github.com/ezrantn/boogo/ebs"
 )
 
+// TestHeap builds a program without field declarations: the type of
+// field f comes from the value written to it.
 func TestHeap(t *testing.T) {
+	o := boogie.Var{Name: "o", Ty: boogie.RefType{}}
+	x := boogie.Var{Name: "x", Ty: boogie.IntType{}}
+	read := &boogie.HeapRead{
+		Obj:   &boogie.VarExpr{V: o},
+		Field: "f",
+	}
+
 	prog := &boogie.Program{
 		Procs: []*boogie.Procedure{
 			{
-				Name: "heapTest",
-				Params: []boogie.Var{
-					{Name: "o", Ty: boogie.RefType{}},
-				},
+				Name:   "heapTest",
+				Params: []boogie.Var{o},
+				Locals: []boogie.Var{x},
 				Body: []boogie.Stmt{
 					&boogie.HeapWrite{
-						Obj:   &boogie.VarExpr{V: boogie.Var{Name: "o"}},
+						Obj:   &boogie.VarExpr{V: o},
 						Field: "f",
 						Value: &boogie.IntLit{Value: 42},
 					},
 					&boogie.Assign{
-						Lhs: &boogie.VarExpr{V: boogie.Var{Name: "x"}},
-						Rhs: &boogie.HeapRead{
-							Obj:   &boogie.VarExpr{V: boogie.Var{Name: "o"}},
-							Field: "f",
-						},
+						Lhs: &boogie.VarExpr{V: x},
+						Rhs: read,
+					},
+					&boogie.Check{
+						Cond: &boogie.BinOp{Op: boogie.Eq, Left: &boogie.VarExpr{V: x}, Right: &boogie.IntLit{Value: 42}, Ty: boogie.BoolType{}},
 					},
 					&boogie.Return{},
 				},
 			},
+			{
+				Name:   "main",
+				Locals: []boogie.Var{o},
+				Body: []boogie.Stmt{
+					&boogie.Alloc{V: o},
+					&boogie.Call{Name: "heapTest", Args: []boogie.Expr{&boogie.VarExpr{V: o}}},
+				},
+			},
 		},
 	}
 
-	ebs.Check(prog)
-	ep := ebs.Erase(prog)
-	_ = boogo.EmitProgram(ep)
+	if err := ebs.Check(prog); err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+	if _, ok := read.Ty.(boogie.IntType); !ok {
+		t.Fatalf("heap read typed %v, want int", read.Ty)
+	}
+
+	out := boogo.EmitProgram(ebs.Erase(prog))
+	for _, want := range []string{
+		"\tf *big.Int\n",
+		"func heapRead_f(o int, pos, expr string) *big.Int {\n",
+		"x = heapRead_f(o, \"-\", \"Heap[o, f]\")\n",
+		"o = heapAlloc()\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if stderr, code := runGenerated(t, out); code != 0 {
+		t.Fatalf("heap program failed: exit %d\n%s", code, stderr)
+	}
+}
+
+func TestHeapE2E(t *testing.T) {
+	src, err := os.ReadFile("heap.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for name, ints := range intModes {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "heap.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", name, err)
+		}
+
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("%s: heap semantics diverged: exit %d\n%s", name, code, stderr)
+		}
+	}
+}
+
+// TestHeapFieldNames checks that heap fields named like the bookkeeping
+// heapObject keeps next to them are escaped, under both heap modes.
+func TestHeapFieldNames(t *testing.T) {
+	src := []byte(`
+type Field _;
+const unique allocated: Field int;
+const unique written: Field bool;
+var Heap: <a>[ref, Field a]a;
+
+procedure main()
+  modifies Heap;
+{
+  var o: ref;
+  call o := New();
+  Heap[o, allocated] := 3;
+  Heap[o, written] := true;
+  assert Heap[o, allocated] == 3 && Heap[o, written];
+}
+`)
+
+	for _, heap := range []codegen.HeapMode{codegen.HeapTotal, codegen.HeapChecked} {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Erase:   ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen: codegen.Options{Heap: heap},
+		})
+		if err != nil {
+			t.Fatalf("heap mode %v: unexpected failure: %v", heap, err)
+		}
+
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("heap mode %v: program failed: exit %d\n%s", heap, code, stderr)
+		}
+	}
 }
@@ -0,0 +1,91 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestHeapCheckedE2E(t *testing.T) {
+	src, err := os.ReadFile("heapcheck.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for name, ints := range intModes {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "heapcheck.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Ints: ints, Heap: codegen.HeapChecked},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", name, err)
+		}
+
+		stderr, code := runGenerated(t, out, "BOOGO_HEAPSTATS=1")
+		if code != 0 {
+			t.Fatalf("%s: checked heap rejected a safe program: exit %d\n%s", name, code, stderr)
+		}
+		want := "boogo: heap: 2 live object(s), 5 field write(s) (alloc: 1, next: 2, val: 2)"
+		if !strings.Contains(stderr, want) {
+			t.Fatalf("%s: expected %q in\n%s", name, want, stderr)
+		}
+	}
+}
+
+func TestHeapCheckedFaults(t *testing.T) {
+	const decls = "type Field _; const alloc: Field bool; const f: Field int; var H: <a>[ref, Field a]a;\n"
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"read of an unallocated object",
+			decls + "procedure main() { var o: ref; var x: int; havoc o; if (o != null) { x := H[o, f]; } }",
+			": read of unallocated object: Heap[o, f]",
+		},
+		{
+			"write to an unallocated object",
+			decls + "procedure main() modifies H; { var o: ref; havoc o; if (o != null) { H[o, f] := 1; } }",
+			": write to unallocated object: Heap[o, f]",
+		},
+		{
+			"read of a field never written",
+			decls + "procedure main() { var o: ref; var x: int; call o := New(); x := H[o, f]; }",
+			"check.bpl:2:66: read of uninitialised field: Heap[o, f]",
+		},
+		{
+			"read after deallocation",
+			decls + "procedure main() modifies H; { var o: ref; var x: int; call o := New(); H[o, f] := 1; H[o, alloc] := false; x := H[o, f]; }",
+			": read of unallocated object: Heap[o, f]",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := boogo.RunWithOptions([]byte(tt.src), boogo.Options{
+			Filename: "check.bpl",
+			Codegen:  codegen.Options{Heap: codegen.HeapChecked},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", tt.name, err)
+		}
+
+		// Havocked references replay as 7, which New never returned.
+		stderr, code := runGenerated(t, out, "BOOGO_REPLAY=7")
+		if code != 2 || !strings.Contains(stderr, tt.want) {
+			t.Fatalf("%s: expected %q: exit %d\n%s", tt.name, tt.want, code, stderr)
+		}
+	}
+
+	// The total heap accepts all of these.
+	out, err := boogo.Run([]byte(tests[2].src))
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+	if stderr, code := runGenerated(t, out); code != 0 {
+		t.Fatalf("total heap faulted: exit %d\n%s", code, stderr)
+	}
+}
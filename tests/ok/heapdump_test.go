@@ -0,0 +1,79 @@
+package ok
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+const dumpSrc = `type Field _;
+const next: Field ref;
+const val: Field int;
+const bits: Field bv8;
+var H: <a>[ref, Field a]a;
+procedure main()
+  modifies H;
+{
+  var a, b: ref;
+  call a := New();
+  call b := New();
+  H[a, next] := b;
+  H[a, val] := 42;
+  H[b, bits] := 3bv8;
+  assert H[a, val] == 1;
+}`
+
+// TestHeapDump dumps the heap at a failed assertion, as BOOGO_HEAPDUMP
+// asks, under both heaps: the checked one leaves out fields never
+// written.
+func TestHeapDump(t *testing.T) {
+	tests := []struct {
+		heap   codegen.HeapMode
+		format string
+		want   []string
+	}{
+		{codegen.HeapTotal, "dot", []string{
+			"boogo: heap at assertion dump.bpl:15:3:\ndigraph heap {\n",
+			`"o1" [label="{#1|bits = 0bv8|val = 42}"];`,
+			`"o2" [label="{#2|bits = 3bv8|val = 0}"];`,
+			`"o1" -> "o2" [label="next"];`,
+		}},
+		{codegen.HeapChecked, "dot", []string{
+			`"o1" [label="{#1|val = 42}"];`,
+			`"o2" [label="{#2|bits = 3bv8}"];`,
+			`"o1" -> "o2" [label="next"];`,
+		}},
+		{codegen.HeapTotal, "json", []string{
+			"boogo: heap at assertion dump.bpl:15:3:\n{\n",
+			`"val": "42"`,
+			`"next": 2`,
+		}},
+	}
+
+	for _, tt := range tests {
+		out, err := boogo.RunWithOptions([]byte(dumpSrc), boogo.Options{
+			Filename: "dump.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertCount},
+			Codegen:  codegen.Options{Heap: tt.heap},
+		})
+		if err != nil {
+			t.Fatalf("unexpected failure: %v", err)
+		}
+
+		stderr, code := runGenerated(t, out, "BOOGO_HEAPDUMP="+tt.format)
+		if code != 1 {
+			t.Fatalf("expected the assertion to fail: exit %d\n%s", code, stderr)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(stderr, want) {
+				t.Errorf("heap %d, %s: expected %q in\n%s", tt.heap, tt.format, want, stderr)
+			}
+		}
+		if !strings.Contains(stderr, "boogo: heap at exit:") {
+			t.Errorf("heap %d, %s: no dump at exit:\n%s", tt.heap, tt.format, stderr)
+		}
+	}
+}
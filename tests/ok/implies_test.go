@@ -0,0 +1,32 @@
+package ok
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestImpliesE2E(t *testing.T) {
+	src, err := os.ReadFile("implies.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for name, ints := range intModes {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "implies.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", name, err)
+		}
+
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("%s: implication semantics diverged: exit %d\n%s", name, code, stderr)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestMapsE2E(t *testing.T) {
+	src, err := os.ReadFile("maps.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for name, ints := range intModes {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "maps.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", name, err)
+		}
+
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("%s: map semantics diverged: exit %d\n%s", name, code, stderr)
+		}
+	}
+}
+
+func TestMapsEmitPersistentUpdates(t *testing.T) {
+	src := []byte(`procedure main()
+{
+  var m: [int]bool;
+  var n: [int]bool;
+  m[1] := true;
+  n := m[2 := true];
+}`)
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Codegen: codegen.Options{Ints: codegen.IntNative},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	for _, want := range []string{
+		"m := MapNew[int, bool](false)\n",
+		"m = m.Set(1, true)\n",
+		"n = m.Set(2, true)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
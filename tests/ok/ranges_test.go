@@ -0,0 +1,62 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/boogie/frontend"
+	"github.com/ezrantn/boogo/boogie/ranges"
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestRangeIntsE2E(t *testing.T) {
+	src, err := os.ReadFile("ranges.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	out, err := boogo.RunWithOptions(src, boogo.Options{
+		Filename: "ranges.bpl",
+		Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+		Codegen:  codegen.Options{Ints: codegen.IntRange},
+	})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	for _, want := range []string{
+		"var i int64\n",
+		"var n int64\n",
+		"h := new(big.Int)\n",
+		"n = (i * 1000)\n",
+		"h = BigAdd(BigMul(h, big.NewInt(n)), big.NewInt(1))\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	if stderr, code := runGenerated(t, out); code != 0 {
+		t.Fatalf("mixed int64/big program failed: exit %d\n%s", code, stderr)
+	}
+}
+
+func TestRangeReport(t *testing.T) {
+	src, err := os.ReadFile("ranges.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	prog, err := frontend.ParseFile("ranges.bpl", src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	want := "main: h needs big integers: havoc at ranges.bpl:12:3 may pick any integer\n"
+	if got := ranges.Report(prog); got != want {
+		t.Fatalf("unexpected report:\ngot:  %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,37 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+func TestRealE2E(t *testing.T) {
+	src, err := os.ReadFile("real.bpl")
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	for _, ints := range []codegen.IntMode{codegen.IntBig, codegen.IntNative, codegen.IntChecked, codegen.IntRange} {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Filename: "real.bpl",
+			Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen:  codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("mode %v: unexpected failure: %v", ints, err)
+		}
+
+		if !strings.Contains(out, "r := new(big.Rat)\n") {
+			t.Fatalf("mode %v: expected reals as *big.Rat:\n%s", ints, out)
+		}
+
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("mode %v: real semantics diverged: exit %d\n%s", ints, code, stderr)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package ok
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+// TestRuntimeImport checks that generated code imports the runtime
+// rather than carrying it, and imports math/big only when it uses it.
+func TestRuntimeImport(t *testing.T) {
+	src := []byte("procedure main() { var x: int; x := 1; assert x > 0; }")
+
+	out, err := boogo.Run(src)
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+	if !strings.Contains(out, "\t\"math/big\"\n\n\t. \"github.com/ezrantn/boogo/runtime\"\n") {
+		t.Errorf("expected math/big and the runtime imported:\n%s", out)
+	}
+	if strings.Contains(out, "func BigAdd(") {
+		t.Errorf("runtime copied into imported output:\n%s", out)
+	}
+
+	out, err = boogo.RunWithOptions(src, boogo.Options{Codegen: codegen.Options{Ints: codegen.IntNative}})
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+	if strings.Contains(out, "math/big") {
+		t.Errorf("native ints import math/big:\n%s", out)
+	}
+	if _, code := runGenerated(t, out); code != 0 {
+		t.Fatalf("native program failed: exit %d", code)
+	}
+}
+
+// TestRuntimeInline builds programs carrying their own runtime outside
+// any module, so they need nothing but the standard library.
+func TestRuntimeInline(t *testing.T) {
+	for _, file := range []string{"heap.bpl", "maps.bpl"} {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read input: %v", err)
+		}
+
+		for name, ints := range intModes {
+			out, err := boogo.RunWithOptions(src, boogo.Options{
+				Filename: file,
+				Erase:    ebs.Policy{Asserts: ebs.AssertPanic},
+				Codegen:  codegen.Options{Ints: ints},
+				Runtime:  boogo.RuntimeInline,
+			})
+			if err != nil {
+				t.Fatalf("%s, %s: unexpected failure: %v", file, name, err)
+			}
+			if strings.Contains(out, "boogo/runtime") || !strings.Contains(out, "func RunMain(") {
+				t.Fatalf("%s, %s: runtime not inlined:\n%s", file, name, out)
+			}
+
+			if stderr, code := runStandalone(t, out); code != 0 {
+				t.Fatalf("%s, %s: standalone program failed: exit %d\n%s", file, name, code, stderr)
+			}
+		}
+	}
+}
+
+// TestRuntimeNames checks that Boogie names clashing with the runtime's
+// package-level identifiers, or with generated declarations, are
+// escaped whether the runtime is imported or inlined.
+func TestRuntimeNames(t *testing.T) {
+	src := []byte(`
+procedure Trace(x: int) returns (y: int) { y := x + 1; }
+procedure trace() {}
+procedure heapAlloc() {}
+procedure main() {
+  var oracle: int;
+  var Map: int;
+  call oracle := Trace(1);
+  call trace();
+  call heapAlloc();
+  Map := oracle;
+  assert Map == 2;
+}
+`)
+
+	for _, mode := range []boogo.Runtime{boogo.RuntimeImport, boogo.RuntimeInline} {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Erase:   ebs.Policy{Asserts: ebs.AssertPanic},
+			Runtime: mode,
+		})
+		if err != nil {
+			t.Fatalf("runtime mode %v: unexpected failure: %v", mode, err)
+		}
+
+		var stderr string
+		var code int
+		if mode == boogo.RuntimeInline {
+			stderr, code = runStandalone(t, out)
+		} else {
+			stderr, code = runGenerated(t, out)
+		}
+		if code != 0 {
+			t.Fatalf("runtime mode %v: program failed: exit %d\n%s\n%s", mode, code, stderr, out)
+		}
+	}
+}
+
+// TestImportedNames checks that Boogie names clashing with the packages
+// generated code imports, or with the aliases of extern packages, are
+// escaped, in every int mode and whether the runtime is imported or
+// inlined.
+func TestImportedNames(t *testing.T) {
+	src := []byte(`
+function {:extern "math/bits.Reverse8"} rev8(bv8) returns (bv8);
+
+procedure fmt(sync: int) returns (os: int) { var big: int; big := 1; os := sync + big; }
+
+procedure main() {
+  var strconv: int;
+  var ext_math_bits: int;
+  ext_math_bits := 2;
+  call strconv := fmt(ext_math_bits);
+  assert strconv == 3;
+  assert rev8(1bv8) == 128bv8;
+}
+`)
+
+	for name, ints := range intModes {
+		for _, mode := range []boogo.Runtime{boogo.RuntimeImport, boogo.RuntimeInline} {
+			out, err := boogo.RunWithOptions(src, boogo.Options{
+				Erase:   ebs.Policy{Asserts: ebs.AssertPanic},
+				Codegen: codegen.Options{Ints: ints},
+				Runtime: mode,
+			})
+			if err != nil {
+				t.Fatalf("%s, runtime mode %v: unexpected failure: %v", name, mode, err)
+			}
+
+			var stderr string
+			var code int
+			if mode == boogo.RuntimeInline {
+				stderr, code = runStandalone(t, out)
+			} else {
+				stderr, code = runGenerated(t, out)
+			}
+			if code != 0 {
+				t.Fatalf("%s, runtime mode %v: program failed: exit %d\n%s\n%s", name, mode, code, stderr, out)
+			}
+		}
+	}
+}
+
+// TestEscapedNames checks that Boogie names Go cannot keep as they are
+// stay distinct from each other and from the names Go can keep, in
+// every int mode.
+func TestEscapedNames(t *testing.T) {
+	src := []byte(`
+procedure len(x: int) returns (y: int) { y := x + 1; }
+procedure len_(x: int) returns (y: int) { y := x + 2; }
+
+procedure main() {
+  var a.b, a_b, a_2e_b_, a$b, x', x_, x__: int;
+  a.b := 1;
+  a_b := 2;
+  a_2e_b_ := 3;
+  a$b := 4;
+  call x' := len(10);
+  call x_ := len_(10);
+  x__ := 5;
+  assert a.b == 1 && a_b == 2 && a_2e_b_ == 3 && a$b == 4;
+  assert x' == 11 && x_ == 12 && x__ == 5;
+}
+`)
+
+	for name, ints := range intModes {
+		out, err := boogo.RunWithOptions(src, boogo.Options{
+			Erase:   ebs.Policy{Asserts: ebs.AssertPanic},
+			Codegen: codegen.Options{Ints: ints},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", name, err)
+		}
+		if stderr, code := runGenerated(t, out); code != 0 {
+			t.Fatalf("%s: program failed: exit %d\n%s\n%s", name, code, stderr, out)
+		}
+	}
+}
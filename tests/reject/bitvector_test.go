@@ -0,0 +1,52 @@
+package reject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+)
+
+func TestRejectBitvectors(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"width mismatch",
+			"procedure p() { var a: bv8; a := 1bv16; }",
+			"type mismatch",
+		},
+		{
+			"literal too wide",
+			"procedure p() { var a: bv8; a := 256bv8; }",
+			"does not fit in bv8",
+		},
+		{
+			"extraction out of range",
+			"procedure p() { var a: bv8; var b: bv4; b := a[9:5]; }",
+			"out of range",
+		},
+		{
+			"uninterpreted function",
+			"function f(bv8) returns (bv8);",
+			"{:bvbuiltin}",
+		},
+		{
+			"unknown builtin",
+			`function {:bvbuiltin "bvfrob"} f(bv8) returns (bv8);`,
+			"unsupported bitvector builtin",
+		},
+		{
+			"builtin signature",
+			`function {:bvbuiltin "bvule"} f(bv8, bv8) returns (bv8);`,
+			"returns bool",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.Run([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package reject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+)
+
+func TestRejectFunctions(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"body of another type",
+			"function f(x: int): bool { x + 1 }",
+			"body has type int, but the function returns bool",
+		},
+		{
+			"heap read",
+			"type Field _; const unique val: Field int; var Heap: <a>[ref, Field a]a; function f(o: ref): int { Heap[o, val] }",
+			"function bodies must be pure, but this one reads the heap",
+		},
+		{
+			"recursion",
+			"function f(x: int): int { if x <= 0 then 0 else f(x - 1) }",
+			"recursive application is not allowed",
+		},
+		{
+			"type parameter only in the result",
+			"function f<T>(x: int): bool { x == 0 }",
+			"type parameter T does not occur in the parameters, so no application could instantiate it",
+		},
+		{
+			"generic memoized",
+			"function {:memoize} f<T>(x: T): T { x }",
+			"{:memoize} functions cannot have type parameters, since Go has no generic variables to hold their cache",
+		},
+		{
+			"generic instantiated with a map",
+			"function id<T>(x: T): T { x } procedure p() { var m: [int]int; m := id(m); }",
+			"type parameter T of id cannot be instantiated with the map type [int]int",
+		},
+		{
+			"generic arguments of two types",
+			"function same<T>(x: T, y: T): bool { x == y } procedure p() { var b: bool; b := same(1, true); }",
+			"argument 1 of same: expected int, got bool",
+		},
+		{
+			"builtin with a body",
+			`function {:bvbuiltin "bvadd"} f(x: bv8, y: bv8): bv8 { x }`,
+			"a {:bvbuiltin} function cannot have a body",
+		},
+		{
+			"memoized without a body",
+			`function {:memoize} {:bvbuiltin "bvadd"} f(x: bv8, y: bv8): bv8;`,
+			"{:memoize} needs a function with a body",
+		},
+		{
+			"memoize with arguments",
+			`function {:memoize 10} f(x: int): int { x }`,
+			"{:memoize} takes no arguments",
+		},
+		{
+			"argument of another type",
+			"function f(x: int): int { x } procedure p() { var b: bool; assert f(b) == 0; }",
+			"argument 0 of f: expected int, got bool",
+		},
+		{
+			"procedure of the same name",
+			"function f(x: int): int { x } procedure f() {}",
+			"procedure f: a function has the same name",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.Run([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRejectExterns(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+		externs         map[string]string
+	}{
+		{
+			"no binding",
+			"function f(x: int): int;",
+			"uninterpreted function has no binding", nil,
+		},
+		{
+			"not a package function",
+			`function {:extern "Func"} f(x: int): int;`,
+			`extern "Func" is not of the form "import/path.Func"`, nil,
+		},
+		{
+			"unexported",
+			`function {:extern "math/bits.len8"} f(x: bv8): int;`,
+			"does not name an exported function", nil,
+		},
+		{
+			"no argument",
+			`function {:extern} f(x: int): int;`,
+			"{:extern} takes exactly one Go function", nil,
+		},
+		{
+			"with a body",
+			`function {:extern "math/bits.Len8"} f(x: int): int { x }`,
+			"a function with a body cannot be {:extern}", nil,
+		},
+		{
+			"generic",
+			`function {:extern "pkg.Id"} f<T>(x: T): T;`,
+			"{:extern} functions cannot have type parameters", nil,
+		},
+		{
+			"bound to a builtin too",
+			`function {:bvbuiltin "bvadd"} {:extern "pkg.Add"} f(x: bv8, y: bv8): bv8;`,
+			"both {:extern} and {:bvbuiltin}", nil,
+		},
+		{
+			"registered for no function",
+			"function f(x: int): int { x }",
+			"extern binding for g: no such function",
+			map[string]string{"g": "pkg.G"},
+		},
+		{
+			"registered badly",
+			"function f(x: int): int;",
+			`extern "pkg" is not of the form`,
+			map[string]string{"f": "pkg"},
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.RunWithOptions([]byte(tt.src), boogo.Options{Externs: tt.externs})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRejectExternProcs(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"no binding",
+			"procedure Read() returns (x: int);",
+			"procedure Read: procedure has no body; bind it to Go with {:extern",
+		},
+		{
+			"with a body",
+			`procedure {:extern "example.com/io.Read"} Read() returns (x: int) { x := 1; }`,
+			"a procedure with a body cannot be {:extern}",
+		},
+		{
+			"malformed",
+			`procedure {:extern "io"} Read() returns (x: int);`,
+			`extern "io" is not of the form "import/path.Func"`,
+		},
+		{
+			"generic",
+			`procedure {:extern "example.com/io.Id"} Id<T>(x: T) returns (y: T);`,
+			"{:extern} procedures cannot have type parameters",
+		},
+		{
+			"called with the wrong arguments",
+			`procedure {:extern "example.com/io.Log"} Log(x: int); procedure p() { call Log(true); }`,
+			"type mismatch in call to Log",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.Run([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
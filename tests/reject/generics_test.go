@@ -0,0 +1,97 @@
+package reject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+)
+
+func TestRejectGenerics(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"conflicting instantiation",
+			"procedure id<T>(a: T, b: T) {} procedure p() { call id(1, true); }",
+			"argument 1 type mismatch in call to id",
+		},
+		{
+			"result of another type",
+			"procedure id<T>(x: T) returns (y: T) { y := x; } procedure p() { var b: bool; call b := id(1); }",
+			"result 0 type mismatch in call to id: b has type bool",
+		},
+		{
+			"type parameter only in locals",
+			"procedure p<T>() { var x: T; }",
+			"type parameter T does not occur in the parameters or results",
+		},
+		{
+			"generic entry point",
+			"procedure main<T>(x: T) {}",
+			"the entry point cannot have type parameters",
+		},
+		{
+			"instantiated with a map",
+			"procedure id<T>(x: T) {} procedure p() { var m: [int]int; call id(m); }",
+			"type parameter T of id cannot be instantiated with the map type [int]int",
+		},
+		{
+			"havoc of a type parameter",
+			"procedure p<T>(x: T) { havoc x; }",
+			"cannot havoc x of type parameter T",
+		},
+		{
+			"map keyed by a type parameter",
+			"procedure p<T>(x: T) { var m: [T]int; }",
+			"type parameter T cannot be a map argument",
+		},
+		{
+			"polymorphic map keyed by its parameter",
+			"procedure p() { var m: <a>[a]a; }",
+			"type parameter a cannot be a map argument",
+		},
+		{
+			"unused map type parameter",
+			"type Field _; procedure p() { var m: <a,b>[Field a]b; }",
+			"type parameter b does not occur in the argument types",
+		},
+		{
+			"wrong instantiation of a polymorphic map",
+			"type Field _; procedure p() { var m: <a>[Field a]a; var f: Field int; m[f] := true; }",
+			"cannot store bool in <a>[Field a]a",
+		},
+		{
+			"ordering type parameters",
+			"procedure p<T>(a: T, b: T) { assert a < b; }",
+			"expected int or real expression, got T",
+		},
+		{
+			"generic bvbuiltin",
+			`function {:bvbuiltin "bvadd"} add<T>(x: T, y: T) returns (T);`,
+			"{:bvbuiltin} functions cannot have type parameters",
+		},
+		{
+			"wrong number of type arguments",
+			"type Field _; procedure p(x: Field) {}",
+			"expected type, got )",
+		},
+		{
+			"type synonym",
+			"type T = int;",
+			"type synonyms are not supported",
+		},
+		{
+			"redeclared type",
+			"type int;",
+			"type int redeclared",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.Run([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package reject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/boogie"
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/ebs"
+)
+
+const heapDecls = "type Field _; type Other _; const f: Field int; const g: Other int; var H: <a>[ref, Field a]a; "
+
+func TestRejectHeap(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"wrong value type",
+			heapDecls + "procedure p(o: ref) { H[o, f] := true; }",
+			"heap field f holds int, cannot store bool",
+		},
+		{
+			"undeclared field",
+			heapDecls + "procedure p(o: ref) { H[o, h] := 1; }",
+			"heap field must be a declared constant, got h",
+		},
+		{
+			"field of another type",
+			heapDecls + "procedure p(o: ref) { assert H[o, g] == 0; }",
+			"g has type Other int, not a field of H",
+		},
+		{
+			"heap update expression",
+			heapDecls + "procedure p(o: ref) { assert H[o, f := 1][o, f] == 1; }",
+			"heap update expressions are not supported",
+		},
+		{
+			"heap as a value",
+			heapDecls + "procedure p(o: ref) { call p(H); }",
+			"the heap H can only be used as H[o, f]",
+		},
+		{
+			"non-field constant",
+			"const n: int;",
+			"only heap fields, such as const f: Field int, can be constants",
+		},
+		{
+			"other global",
+			"var x: int;",
+			"the only global variable supported is the heap",
+		},
+		{
+			"second heap",
+			heapDecls + "var H2: <a>[ref, Field a]a;",
+			"the heap is already declared as H",
+		},
+		{
+			"modifies of an unknown global",
+			heapDecls + "procedure p() modifies X; {}",
+			"undeclared global X",
+		},
+		{
+			"New with arguments",
+			"procedure p(o: ref) { call o := New(1); }",
+			"New takes no arguments and returns one reference",
+		},
+		{
+			"New into an int",
+			"procedure p() { var x: int; call x := New(); }",
+			"New returns a ref, but x has type int",
+		},
+		{
+			"procedure named New",
+			"procedure New() returns (o: ref) {}",
+			"New is the built-in allocator",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.Run([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestRejectHeapFieldConflict writes values of two types to a field
+// without a declaration, so no single type can be inferred for it.
+func TestRejectHeapFieldConflict(t *testing.T) {
+	o := &boogie.VarExpr{V: boogie.Var{Name: "o", Ty: boogie.RefType{}}}
+	prog := &boogie.Program{
+		Procs: []*boogie.Procedure{
+			{
+				Name:   "p",
+				Params: []boogie.Var{o.V},
+				Body: []boogie.Stmt{
+					&boogie.HeapWrite{Obj: o, Field: "f", Value: &boogie.IntLit{Value: 1}},
+					&boogie.HeapWrite{Obj: o, Field: "f", Value: &boogie.BoolLit{Value: true}},
+				},
+			},
+		},
+	}
+
+	err := ebs.Check(prog)
+	if err == nil || !strings.Contains(err.Error(), "heap field f holds int, cannot store bool") {
+		t.Fatalf("got error %v, want a conflicting field type", err)
+	}
+}
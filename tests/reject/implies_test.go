@@ -0,0 +1,42 @@
+package reject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+)
+
+func TestRejectImplications(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"int antecedent",
+			"procedure p() { var x: int; assert x ==> true; }",
+			"expected bool expression, got int",
+		},
+		{
+			"int condition",
+			"procedure p() { var x: int; x := if x then 1 else 2; }",
+			"expected bool expression",
+		},
+		{
+			"branch types differ",
+			"procedure p() { var x: int; x := if true then 1 else false; }",
+			"branches of if-then-else have different types int and bool",
+		},
+		{
+			"missing else",
+			"procedure p() { var x: int; x := if true then 1; }",
+			"expected else",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.Run([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package reject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+	"github.com/ezrantn/boogo/codegen"
+)
+
+func TestRejectCheckedIntLits(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"beyond MaxInt64",
+			"procedure p() { var x: int;\n  x := 9223372036854775808; }",
+			"big.bpl:2:8: integer literal 9223372036854775808 is out of the int64 range",
+		},
+		{
+			"beyond MinInt64",
+			"procedure p() { var x: int;\n  x := 1 + -9223372036854775809; }",
+			"big.bpl:2:12: integer literal -9223372036854775809 is out of the int64 range",
+		},
+		{
+			"double negation of MinInt64",
+			"procedure p() { var x: int;\n  x := - -9223372036854775808; }",
+			"big.bpl:2:8: integer literal 9223372036854775808 is out of the int64 range",
+		},
+		{
+			"in a function body",
+			"function f(x: int): int { x * 100000000000000000000 }",
+			"big.bpl:1:31: integer literal 100000000000000000000 is out of the int64 range",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.RunWithOptions([]byte(tt.src), boogo.Options{
+			Filename: "big.bpl",
+			Codegen:  codegen.Options{Ints: codegen.IntChecked},
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+
+		// Unbounded ints take any literal.
+		if _, err := boogo.RunWithOptions([]byte(tt.src), boogo.Options{Filename: "big.bpl"}); err != nil {
+			t.Errorf("%s: unexpected failure with big ints: %v", tt.name, err)
+		}
+	}
+}
+
+func TestRejectNativeIntLits(t *testing.T) {
+	src := "procedure p() { var x: int;\n  x := 1;\n  x := x * -100000000000000000000; }"
+
+	_, err := boogo.RunWithOptions([]byte(src), boogo.Options{
+		Filename: "big.bpl",
+		Codegen:  codegen.Options{Ints: codegen.IntNative},
+	})
+	want := "big.bpl:3:12: integer literal -100000000000000000000 is out of the int range of native ints"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want it to mention %q", err, want)
+	}
+}
@@ -0,0 +1,52 @@
+package reject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+)
+
+func TestRejectMaps(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"wrong key type",
+			"procedure p() { var m: [int]bool; assert m[true]; }",
+			"argument 0 of [int]bool: expected int, got bool",
+		},
+		{
+			"wrong arity",
+			"procedure p() { var m: [int,int]bool; assert m[1]; }",
+			"[int,int]bool takes 2 arguments, got 1",
+		},
+		{
+			"wrong value type",
+			"procedure p() { var m: [int]bool; m[1] := 2; }",
+			"cannot store int in [int]bool",
+		},
+		{
+			"map equality",
+			"procedure p() { var m: [int]bool; var n: [int]bool; assert m == n; }",
+			"maps cannot be compared with ==",
+		},
+		{
+			"map keyed by map",
+			"procedure p() { var m: [[int]bool]int; }",
+			"maps cannot be map arguments",
+		},
+		{
+			"assign map to scalar",
+			"procedure p() { var m: [int]int; var x: int; x := m; }",
+			"type mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.Run([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package reject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+)
+
+func TestRejectReals(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"int literal as real",
+			"procedure p() { var r: real; r := 1; }",
+			"type mismatch",
+		},
+		{
+			"mixed arithmetic",
+			"procedure p() { var r: real; r := r + 1; }",
+			"got real and int",
+		},
+		{
+			"int division",
+			"procedure p() { var i: int; i := i / 2; }",
+			"expected real expression, got int",
+		},
+		{
+			"real of real",
+			"procedure p() { var r: real; r := real(r); }",
+			"expected int expression, got real",
+		},
+		{
+			"int of int",
+			"procedure p() { var i: int; i := int(i); }",
+			"expected real expression, got int",
+		},
+		{
+			"negated map",
+			"procedure p() { var r: real; var m: [int]real; r := -m; }",
+			"expected int or real expression, got [int]real",
+		},
+		{
+			"negated bool as real",
+			"procedure p() { var r: real; r := -true; }",
+			"expected int or real expression, got bool",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.Run([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package reject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ezrantn/boogo/cmd/boogo"
+)
+
+func TestRejectUnary(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"not of an int",
+			"procedure p() { var b: bool; var x: int; b := !x; }",
+			"expected bool expression, got int",
+		},
+		{
+			"negation of a bool",
+			"procedure p() { var b: bool; b := -b; }",
+			"expected int or real expression, got bool",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := boogo.Run([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}